@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+	"github.com/tidwall/gjson"
+)
+
+//周报和月报中评论数排行的人数
+const aggregateTop = 20
+
+// AggregateOption 周报和月报的配置
+type AggregateOption struct {
+	schedules map[string]*Schedule //生成报告的时间，键为周期，week 或 month
+	window    string               //合并的数据总结对应的统计时段
+	isPost    bool                 //是否发布报告的动态
+	tmpl      *template.Template   //报告文字的模板
+}
+
+//读取周报和月报的配置，window 为没有配置统计时段时使用的统计时段
+func readAggregateOption(aggregate gjson.Result, loc *time.Location, window string) (AggregateOption, error) {
+	opt := AggregateOption{
+		schedules: make(map[string]*Schedule),
+		window:    window,
+		isPost:    aggregate.Get("isPost").Bool(),
+	}
+	for _, period := range []string{report.PeriodWeek, report.PeriodMonth} {
+		expr := aggregate.Get(period).String()
+		if expr == "" {
+			continue
+		}
+		schedule, err := ParseSchedule(expr, loc)
+		if err != nil {
+			return opt, err
+		}
+		opt.schedules[period] = schedule
+	}
+	if w := aggregate.Get("window"); w.Exists() {
+		opt.window = w.String()
+	}
+	var tmplText string
+	if tmplFile := aggregate.Get("template").String(); tmplFile != "" {
+		data, err := os.ReadFile(tmplFile)
+		if err != nil {
+			return opt, err
+		}
+		tmplText = string(data)
+	}
+	var err error
+	opt.tmpl, err = report.ParseAggregateTemplate(tmplText)
+	return opt, err
+}
+
+//查询和 [from, to) 有重叠的数据总结，按开始时间升序
+func (d *DB) summariesBetween(oid uint64, window string, from, to int64) ([]report.Summary, error) {
+	rows, err := d.conn.Query(`select data from summary
+where oid = ? and window_name = ? and end_time > ? and start_time < ? order by start_time, id`,
+		oid, window, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var summaries []report.Summary
+	for rows.Next() {
+		var (
+			data    string
+			summary report.Summary
+		)
+		if err = rows.Scan(&data); err != nil {
+			return summaries, err
+		}
+		if err = json.Unmarshal([]byte(data), &summary); err != nil {
+			return summaries, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+//生成 [start, end) 内的报告，不包含上一个周期的数据
+func (d *DB) aggregate(oid, uid uint64, window, period string, start, end time.Time) (*report.Aggregate, error) {
+	from, to := start.Unix(), end.Unix()
+	summaries, err := d.summariesBetween(oid, window, from, to)
+	if err != nil {
+		return nil, err
+	}
+	a := report.NewAggregate(period, start, end, summaries)
+	if a.People, err = d.CountCommenters(oid, from, to-1); err != nil {
+		return nil, err
+	}
+	top, err := d.TopCommenters(oid, from, to-1, aggregateTop)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range top {
+		a.Top = append(a.Top, report.RankedCommenter{Uid: c.uid, Name: c.uname, Count: c.count})
+	}
+	followers, err := d.FollowerHistory(uid, from, to-1)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range followers {
+		a.Fans = append(a.Fans, report.FansPoint{Time: time.Unix(r.ctime, 0), Fans: r.fans})
+	}
+	if n := len(a.Fans); n > 0 {
+		a.Followers = report.Change{Start: a.Fans[0].Fans, End: a.Fans[n-1].Fans}
+	}
+	return a, nil
+}
+
+// Aggregate 生成 t 之前最近一个完整周期的周报或月报，合并统计时段 window 的数据总结，
+//同时生成上一个周期的数据用于比较，上一个周期没有数据时不比较
+func (d *DB) Aggregate(oid, uid uint64, window, period string, t time.Time) (*report.Aggregate, error) {
+	start, end, err := report.PeriodRange(period, t)
+	if err != nil {
+		return nil, err
+	}
+	a, err := d.aggregate(oid, uid, window, period, start, end)
+	if err != nil {
+		return nil, err
+	}
+	prevStart, _, _ := report.PeriodRange(period, start.Add(-time.Second))
+	prev, err := d.aggregate(oid, uid, window, period, prevStart, start)
+	if err != nil {
+		return nil, err
+	}
+	if prev.Summaries > 0 || prev.People > 0 {
+		a.Previous = prev
+	}
+	return a, nil
+}
+
+//绘制周报或月报的图表并保存到 dir 目录中，返回保存的文件名
+func saveAggregateCharts(a *report.Aggregate, opt report.ChartOption, formats []string, dir string) ([]string, error) {
+	r, err := report.NewChartRenderer(opt)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, chart := range report.AggregateCharts(a, opt) {
+		for _, format := range formats {
+			name, err := r.Save(dir, chart, format)
+			if err != nil {
+				return files, err
+			}
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+//生成周报或月报的文字和图表，开启 isPost 时发布动态
+func (b *Bot) reportAggregate(period string, t time.Time) {
+	var text string
+	a, err := db.Aggregate(b.board.oid, b.monitor.uid, b.aggregate.window, period, t)
+	if err == nil {
+		//周期内没有数据总结时，使用当前的评论区名称和用户名
+		if a.Summaries == 0 {
+			a.BoardName, a.AccountName = b.board.name, b.monitor.uname
+		}
+		text, err = a.Text(b.aggregate.tmpl)
+	}
+	if err != nil {
+		b.logger.Error("生成%s报失败，%v", periodName(period), err)
+		pushAndLog(b.logger, "生成%s报失败，%v", periodName(period), err)
+		return
+	}
+	b.logger.Info("%s", text)
+	files, err := saveAggregateCharts(a, b.chart, b.chartFormats, filepath.Join(chartDir, period))
+	if err != nil {
+		b.logger.Error("绘制图表失败，%v", err)
+		pushAndLog(b.logger, "绘制图表失败，%v", err)
+		return
+	}
+	b.logger.Info("保存图表：%v", files)
+	if !b.aggregate.isPost {
+		return
+	}
+	images := make([]string, 0, len(files))
+	for _, f := range files {
+		if filepath.Ext(f) == ".png" {
+			images = append(images, f)
+		}
+	}
+	go b.postSummary(text, images)
+}
+
+// RunAggregates 每分钟检查一次，到达生成时间时生成周报或月报
+func (b *Bot) RunAggregates() {
+	if len(b.aggregate.schedules) == 0 {
+		return
+	}
+	next := make(map[string]time.Time)
+	now := time.Now()
+	for period, schedule := range b.aggregate.schedules {
+		next[period] = schedule.Next(now)
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case now := <-ticker.C:
+			for period, schedule := range b.aggregate.schedules {
+				if next[period].IsZero() || now.Before(next[period]) {
+					continue
+				}
+				b.reportAggregate(period, now.In(schedule.Location()))
+				next[period] = schedule.Next(now)
+			}
+		}
+	}
+}
+
+//周期的名称，用于日志
+func periodName(period string) string {
+	if period == report.PeriodMonth {
+		return "月"
+	}
+	return "周"
+}
+
+//生成周报或月报
+func aggregateCmd(args []string) int {
+	fs := flag.NewFlagSet("aggregate", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名")
+	oid := fs.Uint64("board", 0, "评论区的oid")
+	uid := fs.Uint64("uid", settingValue("account.uid").Uint(), "监控的账号uid，默认读取 setting.json")
+	period := fs.String("period", report.PeriodWeek, "周期，week 或 month")
+	date := fs.String("date", "", "生成该时间之前最近一个完整周期的报告，例如：2022-07-04，默认为现在")
+	window := fs.String("window", "", "合并的数据总结对应的统计时段，默认读取 setting.json")
+	dir := fs.String("o", "", "图片的保存目录，默认为 "+chartDir+"/<period>")
+	format := fs.String("format", "", "图片格式，多个格式使用逗号分隔，默认读取 setting.json")
+	_ = fs.Parse(args)
+	if *oid == 0 {
+		mainLogger.Error("需要指定评论区的oid")
+		return 2
+	}
+	t := time.Now()
+	if *date != "" {
+		ts, err := parseTime(*date, false)
+		if err != nil {
+			mainLogger.Error("错误的时间：%s", *date)
+			return 2
+		}
+		t = time.Unix(ts, 0)
+	}
+
+	config := settingValue("config")
+	loc, err := readLocation(config)
+	if err != nil {
+		mainLogger.Error("%v", err)
+		return 1
+	}
+	windows, err := readWindows(config)
+	if err != nil {
+		mainLogger.Error("读取统计时段失败，%v", err)
+		return 1
+	}
+	aggOpt, err := readAggregateOption(settingValue("report.aggregate"), loc, windows[0].name)
+	if err != nil {
+		mainLogger.Error("读取报告配置失败，%v", err)
+		return 1
+	}
+	if *window == "" {
+		*window = aggOpt.window
+	}
+	chartOpt, formats, err := readChartOption(settingValue("report.chart"))
+	if err != nil {
+		mainLogger.Error("读取图表配置失败，%v", err)
+		return 1
+	}
+	if *format != "" {
+		formats = strings.Split(*format, ",")
+	}
+	if *dir == "" {
+		*dir = filepath.Join(chartDir, *period)
+	}
+
+	d := NewDB(*dbname, DBOption{})
+	if d == nil {
+		return 1
+	}
+	defer d.Close()
+	a, err := d.Aggregate(*oid, *uid, *window, *period, t.In(loc))
+	if err != nil {
+		mainLogger.Error("生成报告失败，%v", err)
+		return 1
+	}
+	text, err := a.Text(aggOpt.tmpl)
+	if err != nil {
+		mainLogger.Error("生成报告失败，%v", err)
+		return 1
+	}
+	fmt.Println(text)
+	files, err := saveAggregateCharts(a, chartOpt, formats, *dir)
+	if err != nil {
+		mainLogger.Error("绘制图表失败，%v", err)
+		return 1
+	}
+	for _, f := range files {
+		mainLogger.Info("保存图表：%s", f)
+	}
+	return 0
+}
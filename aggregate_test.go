@@ -0,0 +1,103 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+	"github.com/tidwall/gjson"
+)
+
+func TestReadAggregateOption(t *testing.T) {
+	opt, err := readAggregateOption(gjson.Parse(`{"week": "0 9 * * 1", "isPost": true}`), time.UTC, "daily")
+	if err != nil || opt.window != "daily" || !opt.isPost || opt.tmpl == nil || len(opt.schedules) != 1 ||
+		opt.schedules[report.PeriodWeek].Location() != time.UTC {
+		t.Fatalf("got %+v, %v", opt, err)
+	}
+	opt, err = readAggregateOption(gjson.Parse(`{"month": "@monthly", "window": ""}`), time.UTC, "daily")
+	if err != nil || opt.window != "" || opt.schedules[report.PeriodMonth] == nil {
+		t.Fatalf("got %+v, %v", opt, err)
+	}
+	for _, config := range []string{
+		`{"week": "* *"}`,
+		`{"template": "not-exist.tmpl"}`,
+	} {
+		if _, err = readAggregateOption(gjson.Parse(config), time.UTC, ""); err == nil {
+			t.Errorf("%s: want error", config)
+		}
+	}
+}
+
+func TestDB_Aggregate(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	at := func(day, hour int) int64 {
+		return time.Date(2022, 7, day, hour, 0, 0, 0, time.Local).Unix()
+	}
+	for _, s := range []struct {
+		window string
+		day    int
+		hot    []int
+	}{
+		{"daily", 4, []int{1, 2}},
+		{"daily", 6, []int{3}},
+		//其他统计时段和上一周的数据总结
+		{"", 5, []int{100}},
+		{"daily", 1, []int{4}},
+	} {
+		summary := report.Summary{Start: at(s.day, 12), End: at(s.day+1, 12), Window: s.window}
+		summary.Board.Oid = 10
+		summary.Board.Name = "啵版"
+		summary.Board.Hot = s.hot
+		d.InsertSummary(summary, "", false)
+	}
+	for i, c := range []struct {
+		uid  uint64
+		name string
+		day  int
+	}{
+		{1, "a", 4}, {1, "a", 5}, {1, "a2", 6}, {2, "b", 6}, {3, "c", 1}, {4, "d", 11},
+	} {
+		comment := Comment{Account: Account{uid: c.uid, uname: c.name}, oid: 10, replyId: uint64(i + 1),
+			ctime: uint64(at(c.day, 8))}
+		d.InsertComment(CommentRecord{Comment: comment, likeTime: int64(comment.ctime)})
+	}
+	for _, f := range []struct {
+		day, fans int
+	}{{3, 90}, {4, 100}, {8, 130}, {11, 150}} {
+		d.InsertFollower(1, at(f.day, 9), f.fans)
+	}
+	d.Flush()
+
+	a, err := d.Aggregate(10, 1, "daily", report.PeriodWeek, time.Date(2022, 7, 12, 9, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Summaries != 2 || a.Comments != 6 || a.BoardName != "啵版" || a.People != 2 {
+		t.Errorf("got summaries %d, comments %d, board %q, people %d", a.Summaries, a.Comments, a.BoardName, a.People)
+	}
+	if len(a.Top) != 2 || a.Top[0] != (report.RankedCommenter{Uid: 1, Name: "a2", Count: 3}) {
+		t.Errorf("top: got %+v", a.Top)
+	}
+	if len(a.Fans) != 2 || a.Followers.Start != 100 || a.Followers.End != 130 {
+		t.Errorf("fans: got %+v, %+v", a.Fans, a.Followers)
+	}
+	if prev := a.Previous; prev == nil || prev.Summaries != 1 || prev.Comments != 4 || prev.People != 1 {
+		t.Errorf("previous: got %+v", prev)
+	}
+
+	//上一个周期没有数据时不比较
+	a, err = d.Aggregate(10, 1, "daily", report.PeriodWeek, time.Date(2022, 6, 29, 0, 0, 0, 0, time.Local))
+	if err != nil || a.Previous != nil || a.Summaries != 0 {
+		t.Errorf("got %+v, %v", a, err)
+	}
+	//查询失败时返回错误，不生成不完整的报告
+	_ = d.conn.Close()
+	if a, err = d.Aggregate(10, 1, "daily", report.PeriodWeek, time.Date(2022, 7, 12, 9, 0, 0, 0, time.Local)); err == nil {
+		t.Errorf("want error, got %+v", a)
+	}
+}
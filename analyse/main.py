@@ -92,9 +92,9 @@ def post_dynamic(msg: str, pics: list):
 
 
 # 上传图片
-def upload_img(img_path):
+def upload_img(img_path, content_type='image/jpeg'):
     data = {
-        "file_up": (os.path.basename(img_path), open(img_path, 'rb'), 'image/jpeg'),
+        "file_up": (os.path.basename(img_path), open(img_path, 'rb'), content_type),
         "biz": "new_dyn",
         "category": "daily",
         "csrf": cookie['bili_jct']
@@ -202,7 +202,11 @@ def main():
     file_name = sys.argv[1]
     with open(file_name, encoding='utf-8') as f:
         data = json.load(f)
-    draw(data)
+    # 程序传入数据总结的文字时，图表也已经由程序绘制为png图片
+    img_ext, img_type = "png", "image/png"
+    if len(sys.argv) <= 3:
+        draw(data)
+        img_ext, img_type = "jpg", "image/jpeg"
     board = data['board']
     account = data['account']
     start_all_count = board['startAllCount']
@@ -248,6 +252,9 @@ def main():
               time.strftime("%m-%d %H:%M", time.localtime(max_hot_time)), max_hot,
               len(people), max_num
           )
+    # 使用程序根据模板生成的文字
+    if len(sys.argv) > 3:
+        msg = sys.argv[3]
     logger.log(msg)
     logger.log("记录的评论数：%d" % count)
     logger.log("最佳人之初：uid:%d" % max_uid)
@@ -255,22 +262,22 @@ def main():
         return
     # 发布动态
     images = []
-    hot_img = upload_img("./report/img/hot.jpg")
+    hot_img = upload_img("./report/img/hot.%s" % img_ext, img_type)
     if hot_img is None:
         logger.log("上传图片：hot失败")
         return
     images.append(hot_img)
-    fans_img = upload_img("./report/img/fans.jpg")
+    fans_img = upload_img("./report/img/fans.%s" % img_ext, img_type)
     if fans_img is None:
         logger.log("上传图片：fans失败")
         return
     images.append(fans_img)
-    delay_mean_img = upload_img("./report/img/delay_mean.jpg")
+    delay_mean_img = upload_img("./report/img/delay_mean.%s" % img_ext, img_type)
     if delay_mean_img is None:
         logger.log("上传图片，delay_mean失败")
         return
     images.append(delay_mean_img)
-    delay_median_img = upload_img("./report/img/delay_median.jpg")
+    delay_median_img = upload_img("./report/img/delay_median.%s" % img_ext, img_type)
     if delay_median_img is None:
         logger.log("上传图片：delay_median失败")
         return
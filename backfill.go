@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"time"
+)
+
+//楼中楼每页的评论数
+const replyPageSize = 20
+
+// BackfillCursor 补全历史评论的进度，每获取一页评论保存一次，中断后可以从上次的位置继续
+type BackfillCursor struct {
+	oid      uint64
+	typeCode int
+	next     int64 //下一页的游标，为0时从第一页开始
+	done     bool  //是否已经获取完所有评论
+	count    int   //已获取的评论数，包含楼中楼
+}
+
+// BackfillCursor 读取评论区补全历史评论的进度，没有记录时从头开始
+func (d *DB) BackfillCursor(oid uint64) (BackfillCursor, error) {
+	cursor := BackfillCursor{oid: oid}
+	err := d.conn.QueryRow("select type_code, next, done, count from backfill_cursor where oid = ?", oid).
+		Scan(&cursor.typeCode, &cursor.next, &cursor.done, &cursor.count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cursor, nil
+	}
+	return cursor, err
+}
+
+// SaveBackfillCursor 保存补全历史评论的进度，和评论使用同一个写入协程，保证保存的进度之前的评论都已经写入
+func (d *DB) SaveBackfillCursor(cursor BackfillCursor) {
+	d.writer.add("backfill_cursor", cursor.oid, cursor.typeCode, cursor.next, cursor.done, cursor.count,
+		time.Now().Unix())
+}
+
+// InsertBackfill 插入补全历史评论时获取到的评论，root 为楼中楼对应的楼主评论id，不是楼中楼时为0。
+//这些评论的 source 为 backfill，不会计入运行时的统计数据
+func (d *DB) InsertBackfill(comment Comment, root uint64) {
+	d.writer.add("backfill_comment", comment.oid, comment.typeCode, comment.replyId, comment.ctime, comment.msg,
+		comment.uid, comment.uname, comment.location, comment.like, comment.rcount, root)
+}
+
+// BackfillOption 补全历史评论的配置
+type BackfillOption struct {
+	interval time.Duration //两次请求的最小间隔
+	replies  bool          //是否获取楼中楼
+	pages    int           //本次最多获取的页数，为0时不限制
+}
+
+// Backfill 从 cursor 的位置开始，按时间倒序分页获取评论区中的所有评论并保存到数据库中，
+//返回最新的进度，ctx 取消时在当前页处理完后停止
+func (b *BiliBili) Backfill(ctx context.Context, d *DB, board Board, cursor BackfillCursor,
+	opt BackfillOption) (BackfillCursor, error) {
+	limiter := time.NewTicker(opt.interval)
+	defer limiter.Stop()
+	//等待下一次请求，返回 false 表示已取消
+	wait := func() bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-limiter.C:
+			return true
+		}
+	}
+	cursor.typeCode = board.typeCode
+	for pages := 0; !cursor.done && (opt.pages == 0 || pages < opt.pages); pages++ {
+		if !wait() {
+			return cursor, nil
+		}
+		comments, next, end, err := b.CommentPage(board, cursor.next)
+		if err != nil {
+			return cursor, err
+		}
+		for _, comment := range comments {
+			d.InsertBackfill(comment, 0)
+			cursor.count++
+			if !opt.replies || comment.rcount == 0 {
+				continue
+			}
+			for pn := 1; ; pn++ {
+				//楼中楼获取到一半时取消，会在下次继续时重新获取这一页
+				if !wait() {
+					return cursor, nil
+				}
+				replies, err := b.ReplyPage(board, comment.replyId, pn)
+				if err != nil {
+					return cursor, err
+				}
+				for _, reply := range replies {
+					d.InsertBackfill(reply, comment.replyId)
+				}
+				cursor.count += len(replies)
+				if len(replies) < replyPageSize {
+					break
+				}
+			}
+		}
+		cursor.next, cursor.done = next, end
+		d.SaveBackfillCursor(cursor)
+		if len(comments) > 0 {
+			b.logger.Info("已获取 %d 条评论，当前页最早的评论：%s", cursor.count,
+				time.Unix(int64(comments[len(comments)-1].ctime), 0).Format("2006-01-02 15:04:05"))
+		}
+	}
+	return cursor, nil
+}
+
+//补全评论区的历史评论
+func backfillCmd(args []string) int {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名")
+	dId := fs.Uint64("dynamic", 0, "动态id，默认使用 setting.json 中的评论区")
+	bvID := fs.String("bv", "", "视频的bv号，默认使用 setting.json 中的评论区")
+	interval := fs.Int("interval", 1000, "两次请求的最小间隔，单位：毫秒")
+	replies := fs.Bool("replies", false, "同时获取楼中楼")
+	pages := fs.Int("pages", 0, "本次最多获取的页数，为0时不限制")
+	restart := fs.Bool("restart", false, "忽略之前的进度，从头开始获取")
+	_ = fs.Parse(args)
+	if *interval <= 0 {
+		mainLogger.Error("错误的请求间隔：%d", *interval)
+		return 2
+	}
+
+	botAccount, _, board, _ := readSetting()
+	if *dId != 0 || *bvID != "" {
+		board = Board{dId: *dId, bvID: *bvID}
+	}
+	bili := BiliBiliLogin(botAccount)
+	if bili == nil {
+		mainLogger.Error("登录失败！")
+		return 1
+	}
+	if !bili.BoardDetail(&board) {
+		mainLogger.Error("获取评论区信息失败！")
+		return 1
+	}
+	d := NewDB(*dbname, DBOption{})
+	if d == nil {
+		return 1
+	}
+	defer d.Close()
+
+	cursor, err := d.BackfillCursor(board.oid)
+	if err != nil {
+		mainLogger.Error("读取进度失败，%v", err)
+		return 1
+	}
+	if *restart {
+		cursor = BackfillCursor{oid: board.oid}
+	}
+	if cursor.done {
+		mainLogger.Info("评论区已经补全，共 %d 条评论，使用 -restart 重新获取", cursor.count)
+		return 0
+	}
+	if cursor.next != 0 {
+		mainLogger.Info("从上次的进度继续：已获取 %d 条评论", cursor.count)
+	}
+	mainLogger.Info("开始补全历史评论：oid=%d, type=%d", board.oid, board.typeCode)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cursor, err = bili.Backfill(ctx, d, board, cursor, BackfillOption{
+		interval: time.Duration(*interval) * time.Millisecond,
+		replies:  *replies,
+		pages:    *pages,
+	})
+	if err != nil {
+		mainLogger.Error("补全历史评论失败，已保存进度，%v", err)
+		return 1
+	}
+	if cursor.done {
+		mainLogger.Info("补全完成，共获取 %d 条评论", cursor.count)
+	} else {
+		mainLogger.Info("已停止，共获取 %d 条评论，再次运行时从当前进度继续", cursor.count)
+	}
+	return 0
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/tidwall/gjson"
 	"math"
 	"strconv"
+	"strings"
 )
 
 //用于身份授权的 cookie 的键名
@@ -54,6 +55,8 @@ type Comment struct {
 	typeCode int    //评论区类型码
 	oid      uint64 //评论区的id
 	location string //ip归属地
+	like     int    //点赞数
+	rcount   int    //回复数（楼中楼数量）
 }
 
 // Board 评论区，或者叫版聊区
@@ -67,6 +70,17 @@ type Board struct {
 	bvID     string //视频的bv号，针对视频的评论区
 }
 
+// CommentURL 评论的链接，rpid 为评论id
+func (b Board) CommentURL(rpid uint64) string {
+	if b.dId != 0 {
+		return fmt.Sprintf("https://t.bilibili.com/%d#reply%d", b.dId, rpid)
+	}
+	if b.bvID != "" {
+		return fmt.Sprintf("https://www.bilibili.com/video/%s#reply%d", b.bvID, rpid)
+	}
+	return ""
+}
+
 // BiliBili 与b站后台接口交互的对象
 type BiliBili struct {
 	user   BotAccount
@@ -207,16 +221,24 @@ func (b *BiliBili) GetComments(board Board) []Comment {
 		return nil
 	}
 	//获取评论，默认获取20条
-	replies := data.Get("replies").Array()
-	repliesLen := len(replies)
-	comments := make([]Comment, repliesLen)
-	for i := repliesLen - 1; i >= 0; i-- {
-		reply := replies[i]
+	comments := parseReplies(data.Get("replies"), board)
+	for _, comment := range comments {
+		b.logger.Debug("获取到评论：%#v", comment)
+	}
+	b.logger.Debug("获取评论成功：oid: %d, 获取评论数：%d", board.oid, len(comments))
+	return comments
+}
+
+//解析接口返回的评论列表
+func parseReplies(replies gjson.Result, board Board) []Comment {
+	array := replies.Array()
+	comments := make([]Comment, len(array))
+	for i, reply := range array {
 		location := []rune(reply.Get("reply_control.location").String())
 		if len(location) > 5 {
 			location = location[5:]
 		}
-		comment := Comment{
+		comments[i] = Comment{
 			Account: Account{
 				uid:   reply.Get("mid").Uint(),
 				uname: reply.Get("member.uname").String(),
@@ -227,14 +249,63 @@ func (b *BiliBili) GetComments(board Board) []Comment {
 			typeCode: board.typeCode,
 			oid:      board.oid,
 			location: string(location),
+			like:     int(reply.Get("like").Int()),
+			rcount:   int(reply.Get("rcount").Int()),
 		}
-		comments[i] = comment
-		b.logger.Debug("获取到评论：%#v", comment)
 	}
-	b.logger.Debug("获取评论成功：oid: %d, 获取评论数：%d", board.oid, len(comments))
 	return comments
 }
 
+// CommentPage 按时间倒序分页获取评论，next 为上一页返回的游标，为0时获取第一页。
+//返回的 next 为下一页的游标，end 为 true 表示已经是最后一页
+func (b *BiliBili) CommentPage(board Board, next int64) (comments []Comment, nextCursor int64, end bool, err error) {
+	urlStr := "https://api.bilibili.com/x/v2/reply/main"
+	params := map[string]interface{}{
+		"oid":  board.oid,
+		"type": board.typeCode,
+		"ps":   30,
+		"mode": 2, //按时间排序
+		"next": next,
+	}
+	data, err := checkResp(b.client.GetWithRetry(urlStr, params, nil, 3))
+	if err != nil {
+		b.logger.Error("获取评论失败：oid: %d, next: %d, %v", board.oid, next, err)
+		return nil, next, false, err
+	}
+	if data == nil {
+		return nil, next, true, nil
+	}
+	comments = parseReplies(data.Get("replies"), board)
+	cursor := data.Get("cursor")
+	nextCursor = cursor.Get("next").Int()
+	end = cursor.Get("is_end").Bool() || len(comments) == 0
+	b.logger.Debug("获取评论成功：oid: %d, next: %d, 获取评论数：%d", board.oid, next, len(comments))
+	return comments, nextCursor, end, nil
+}
+
+// ReplyPage 获取评论的楼中楼，root 为楼主评论的id，pn 为页码，从1开始，每页20条
+func (b *BiliBili) ReplyPage(board Board, root uint64, pn int) ([]Comment, error) {
+	urlStr := "https://api.bilibili.com/x/v2/reply/reply"
+	params := map[string]interface{}{
+		"oid":  board.oid,
+		"type": board.typeCode,
+		"root": root,
+		"ps":   20,
+		"pn":   pn,
+	}
+	data, err := checkResp(b.client.GetWithRetry(urlStr, params, nil, 3))
+	if err != nil {
+		b.logger.Error("获取楼中楼失败：oid: %d, root: %d, pn: %d, %v", board.oid, root, pn, err)
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+	comments := parseReplies(data.Get("replies"), board)
+	b.logger.Debug("获取楼中楼成功：oid: %d, root: %d, 获取评论数：%d", board.oid, root, len(comments))
+	return comments, nil
+}
+
 // PostComment 发评论，board 为对应的评论区，comment 不为空则表示评论区中回复对应的评论
 func (b *BiliBili) PostComment(board Board, comment *Comment, msg string) bool {
 	urlStr := "https://api.bilibili.com/x/v2/reply/add"
@@ -300,6 +371,84 @@ func (b *BiliBili) dynamicCommentDetail(board *Board) bool {
 	return true
 }
 
+// VideoStat 视频的统计数据
+type VideoStat struct {
+	aid      uint64 //av号
+	bvID     string //bv号
+	view     int    //播放数
+	danmaku  int    //弹幕数
+	reply    int    //评论数
+	favorite int    //收藏数
+	coin     int    //投币数
+	share    int    //分享数
+	like     int    //点赞数
+	nowRank  int    //当前全站排行榜排名，0表示不在排行榜中
+	hisRank  int    //历史全站排行榜最高排名
+}
+
+// Metrics 以 map 的形式返回各项数据，键为数据项名称
+func (v VideoStat) Metrics() map[string]int {
+	return map[string]int{
+		"view":     v.view,
+		"danmaku":  v.danmaku,
+		"reply":    v.reply,
+		"favorite": v.favorite,
+		"coin":     v.coin,
+		"share":    v.share,
+		"like":     v.like,
+	}
+}
+
+// VideoStat 获取视频的统计数据
+func (b *BiliBili) VideoStat(bvID string) (VideoStat, bool) {
+	urlStr := "https://api.bilibili.com/x/web-interface/archive/stat"
+	data, err := checkResp(b.client.Get(urlStr, map[string]interface{}{"bvid": bvID}, nil))
+	if err != nil {
+		b.logger.Error("获取视频数据失败：bv: %s, err: %v", bvID, err)
+		return VideoStat{}, false
+	}
+	stat := VideoStat{
+		aid:      data.Get("aid").Uint(),
+		bvID:     bvID,
+		view:     int(data.Get("view").Int()),
+		danmaku:  int(data.Get("danmaku").Int()),
+		reply:    int(data.Get("reply").Int()),
+		favorite: int(data.Get("favorite").Int()),
+		coin:     int(data.Get("coin").Int()),
+		share:    int(data.Get("share").Int()),
+		like:     int(data.Get("like").Int()),
+		nowRank:  int(data.Get("now_rank").Int()),
+		hisRank:  int(data.Get("his_rank").Int()),
+	}
+	b.logger.Debug("获取视频数据：%#v", stat)
+	return stat, true
+}
+
+// IsPopular 判断视频是否在热门列表中，只查找前 pages 页，每页20个视频
+func (b *BiliBili) IsPopular(aid uint64, pages int) (bool, error) {
+	urlStr := "https://api.bilibili.com/x/web-interface/popular"
+	for pn := 1; pn <= pages; pn++ {
+		params := map[string]interface{}{
+			"ps": 20,
+			"pn": pn,
+		}
+		data, err := checkResp(b.client.Get(urlStr, params, nil))
+		if err != nil {
+			b.logger.Error("获取热门列表失败：pn: %d, err: %v", pn, err)
+			return false, err
+		}
+		for _, item := range data.Get("list").Array() {
+			if item.Get("aid").Uint() == aid {
+				return true, nil
+			}
+		}
+		if data.Get("no_more").Bool() {
+			break
+		}
+	}
+	return false, nil
+}
+
 func (b *BiliBili) videoCommentDetail(board *Board) bool {
 	bv := board.bvID
 	if len(bv) != 12 || (bv[0] != 'B' || bv[1] != 'V') {
@@ -352,6 +501,94 @@ func (b *BiliBili) AccountStat(account *MonitorAccount) bool {
 	return true
 }
 
+//账号的统计数据项
+const (
+	StatFollower  = "follower"  //粉丝数
+	StatFollowing = "following" //关注数
+	StatView      = "view"      //视频总播放量
+	StatLikes     = "likes"     //获赞数
+	StatVideo     = "video"     //视频投稿数
+	StatDynamic   = "dynamic"   //动态数
+)
+
+// AllStats 所有支持的统计数据项
+var AllStats = []string{StatFollower, StatFollowing, StatView, StatLikes, StatVideo, StatDynamic}
+
+//检查统计数据项是否支持，并且没有在 stats 中重复
+func checkStat(stats []string, stat string) error {
+	for _, s := range stats {
+		if s == stat {
+			return fmt.Errorf("重复的数据项：%s", stat)
+		}
+	}
+	for _, s := range AllStats {
+		if s == stat {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的数据项：%s，可选：%s", stat, strings.Join(AllStats, ", "))
+}
+
+// AccountStats 获取账号的统计数据，metrics 为需要获取的数据项，
+//返回的 map 中只包含获取成功的数据项，键为数据项名称
+func (b *BiliBili) AccountStats(uid uint64, metrics []string) map[string]int {
+	need := make(map[string]bool, len(metrics))
+	for _, m := range metrics {
+		need[m] = true
+	}
+	stats := make(map[string]int)
+	//关注数和粉丝数
+	if need[StatFollower] || need[StatFollowing] {
+		urlStr := "https://api.bilibili.com/x/relation/stat"
+		data, err := checkResp(b.client.Get(urlStr, map[string]interface{}{"vmid": uid}, nil))
+		if err != nil {
+			b.logger.Error("获取关注数失败：uid：%d, err: %v", uid, err)
+		} else {
+			stats[StatFollower] = int(data.Get("follower").Int())
+			stats[StatFollowing] = int(data.Get("following").Int())
+		}
+	}
+	//播放量和获赞数，需要登录
+	if need[StatView] || need[StatLikes] {
+		urlStr := "https://api.bilibili.com/x/space/upstat"
+		data, err := checkResp(b.client.Get(urlStr, map[string]interface{}{"mid": uid}, nil))
+		if err != nil {
+			b.logger.Error("获取播放量失败：uid：%d, err: %v", uid, err)
+		} else {
+			stats[StatView] = int(data.Get("archive.view").Int())
+			stats[StatLikes] = int(data.Get("likes").Int())
+		}
+	}
+	//视频投稿数
+	if need[StatVideo] {
+		urlStr := "https://api.bilibili.com/x/space/navnum"
+		data, err := checkResp(b.client.Get(urlStr, map[string]interface{}{"mid": uid}, nil))
+		if err != nil {
+			b.logger.Error("获取投稿数失败：uid：%d, err: %v", uid, err)
+		} else {
+			stats[StatVideo] = int(data.Get("video").Int())
+		}
+	}
+	//动态数
+	if need[StatDynamic] {
+		urlStr := "https://api.vc.bilibili.com/dynamic_svr/v1/dynamic_svr/space_num"
+		data, err := checkResp(b.client.Get(urlStr, map[string]interface{}{"uid": uid}, nil))
+		if err != nil {
+			b.logger.Error("获取动态数失败：uid：%d, err: %v", uid, err)
+		} else {
+			stats[StatDynamic] = int(data.Get("dyn_num").Int())
+		}
+	}
+	//只保留需要的数据项
+	for k := range stats {
+		if !need[k] {
+			delete(stats, k)
+		}
+	}
+	b.logger.Debug("获取统计数据：uid: %d, stats: %v", uid, stats)
+	return stats
+}
+
 // AccountInfo 获取详细信息：用户昵称，头像，签名
 func (b *BiliBili) AccountInfo(account *MonitorAccount) bool {
 	urlStr := "https://api.bilibili.com/x/space/acc/info"
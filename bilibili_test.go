@@ -21,3 +21,21 @@ func TestBv2av(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckStat(t *testing.T) {
+	tests := []struct {
+		stats   []string
+		stat    string
+		wantErr bool
+	}{
+		{nil, StatFollower, false},
+		{[]string{StatFollower}, StatView, false},
+		{[]string{StatFollower}, StatFollower, true},
+		{nil, "fans", true},
+	}
+	for _, tt := range tests {
+		if err := checkStat(tt.stats, tt.stat); (err != nil) != tt.wantErr {
+			t.Errorf("%v %s: want error %v, got %v", tt.stats, tt.stat, tt.wantErr, err)
+		}
+	}
+}
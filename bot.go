@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/Hami-Lemon/bobo-bot/logger"
+	"github.com/Hami-Lemon/bobo-bot/push"
+	"github.com/Hami-Lemon/bobo-bot/report"
 	"github.com/Hami-Lemon/bobo-bot/set"
 	"github.com/Hami-Lemon/bobo-bot/util"
 )
@@ -19,12 +22,27 @@ const (
 )
 
 type Counter struct {
+	window       string         //所属统计时段的名称
 	todayComment int            //统计时段内记录到的评论数
 	peopleCount  map[uint64]int //参与评论的用户，记录不同用户的发评数量
 
-	hotCount  []int //统计时间段中，每一分钟内的评论数，数组索引表示距离统计开始时间的偏移量，单位分钟
-	awlCount  []int //每一分钟内的延迟统计
-	fansCount []int //粉丝数变化
+	hotCount []int               //统计时间段中，每一分钟内的评论数，数组索引表示距离统计开始时间的偏移量，单位分钟
+	awlCount []int               //每一分钟内的最大延迟
+	latency  []report.Latency    //每一分钟内的延迟分布
+	total    report.Latency      //统计时段内的延迟分布
+	stats    report.CommentStats //评论的其他统计数据
+	spam     report.SpamStats    //被标记为刷屏的评论，不计入其他统计数据
+	//每分钟、每小时内发送评论的用户，用于统计 stats 中的人数，键为距离统计开始时间的偏移量
+	minuteUsers map[int]*set.HashSet[uint64]
+	hourUsers   map[int]*set.HashSet[uint64]
+	fansCount   []int                //粉丝数变化
+	statCount   map[string][]int     //账号的各项统计数据变化，键为数据项名称
+	statTimes   []int64              //各项统计数据的记录时间，和 statCount 中的每一项对应
+	video       *report.VideoSummary //视频数据，只有监控视频评论区时不为nil
+
+	startAllCount  int //开始时的总评论数，包含楼中楼
+	startCount     int //开始时的评论数，不含楼中楼
+	startFollowers int //开始时的粉丝数
 
 	startTime time.Time  //统计的开始时间点
 	lock      sync.Mutex //互斥锁
@@ -43,16 +61,38 @@ type BotOption struct {
 	likeCD  float32 //点赞cd，单位：秒
 	isLike  bool    //是否开启点赞
 	isPost  bool    //是否发布数据总结动态
+
+	watchlist []Watch    //关注列表，列表中的用户发送评论时推送提醒
+	fans      FansOption //粉丝数提醒
+	spam      SpamOption //刷屏检测
+
+	statInterval int      //统计数据的记录间隔，单位：分钟
+	stats        []string //需要记录的统计数据项
+
+	video VideoOption //视频数据监控
+
+	checkpoint int //保存检查点的间隔，单位：分钟，为0时不保存
+
+	windows []WindowOption //数据总结的统计时段
+	catchUp int            //每个统计时段最多补全的数据总结数量，为0时不补全
+
+	reportTmpl   *template.Template //数据总结文字的模板
+	chart        report.ChartOption //数据总结的图表
+	chartFormats []string           //图表保存的图片格式
+	aggregate    AggregateOption    //周报和月报
 }
 
 type Bot struct {
 	board     Board          //监控的评论区
 	monitor   MonitorAccount //监控的账户
 	bili      *BiliBili
-	counter   *Counter //统计器
+	counter   *Counter         //第一个统计时段的统计器
+	windows   []*SummaryWindow //所有的统计时段
 	logger    *logger.Logger
 	stop      chan struct{} //退出信号
 	likeQueue chan Comment  //点赞评论的任务队列
+	watchlist *Watchlist    //关注列表
+	spam      *SpamDetector //刷屏检测，只在获取评论的协程中使用
 	BotOption
 	report *Reporter
 }
@@ -71,34 +111,27 @@ func NewBot(bili *BiliBili, board Board,
 	if !bili.GetCommentsPage(&board) {
 		mainLogger.Error("获取评论数量失败！")
 	}
-	now := time.Now()
-	counter := Counter{
-		peopleCount: make(map[uint64]int),
-		hotCount:    make([]int, 0, CountCap),
-		awlCount:    make([]int, 0, CountCap),
-		fansCount:   make([]int, 1),
-		startTime:   now,
-	}
-	counter.fansCount[0] = monitor.follower
-
-	return &Bot{
+	bot := &Bot{
 		board:     board,
 		monitor:   monitor,
 		bili:      bili,
-		counter:   &counter,
 		logger:    logger.New(fmt.Sprintf("Bot-%s", board.name), logLevel, logDst),
 		stop:      make(chan struct{}, 1),
 		likeQueue: make(chan Comment, 32),
+		watchlist: NewWatchlist(opt.watchlist),
+		spam:      NewSpamDetector(opt.spam),
 		BotOption: opt,
 		report: &Reporter{
 			offset:   opt.freshCD,
 			interval: 60 * 3, //三分钟内只触发一次
 		},
 	}
+	bot.initWindows(time.Now())
+	return bot
 }
 
 // RecoverBot 使用上一次中断程序后保存的数据恢复
-func RecoverBot(bili *BiliBili, opt BotOption, summary Summary) *Bot {
+func RecoverBot(bili *BiliBili, opt BotOption, summary report.Summary) *Bot {
 	if strings.Compare(summary.Version, Version) != 0 {
 		mainLogger.Warn("当前版本：%s，恢复信息版本：%s", Version, summary.Version)
 	}
@@ -122,31 +155,73 @@ func RecoverBot(bili *BiliBili, opt BotOption, summary Summary) *Bot {
 		follower: summary.Account.StartFollowers,
 	}
 
-	counter := &Counter{
-		todayComment: summary.Board.Count,
-		peopleCount:  summary.Board.People,
-		hotCount:     summary.Board.Hot,
-		awlCount:     summary.Board.Awl,
-		fansCount:    summary.Account.FansCount,
-		startTime:    time.Unix(summary.Start, 0),
-	}
 	bot := &Bot{
 		board:     board,
 		monitor:   monitor,
 		bili:      bili,
-		counter:   counter,
 		logger:    logger.New(fmt.Sprintf("Bot-%s", board.name), logLevel, logDst),
 		stop:      make(chan struct{}, 1),
 		likeQueue: make(chan Comment, 32),
+		watchlist: NewWatchlist(opt.watchlist),
+		spam:      NewSpamDetector(opt.spam),
 		BotOption: opt,
 		report: &Reporter{
 			offset:   opt.freshCD,
 			interval: 60 * 3,
 		},
 	}
+	//其他统计时段从现在开始统计，开始时的评论数和粉丝数使用恢复信息中的数据
+	bot.initWindows(time.Now())
+	w := bot.window(summary.Window)
+	if w == nil {
+		mainLogger.Warn("统计时段不存在：%q，恢复到第一个统计时段", summary.Window)
+		w = bot.windows[0]
+	}
+	w.counter = recoverCounter(summary, w.name, board.oid)
+	bot.counter = bot.windows[0].counter
 	return bot
 }
 
+//使用数据总结恢复统计器
+func recoverCounter(summary report.Summary, window string, oid uint64) *Counter {
+	counter := &Counter{
+		window:         window,
+		startAllCount:  summary.Board.StartAllCount,
+		startCount:     summary.Board.StartCount,
+		startFollowers: summary.Account.StartFollowers,
+		todayComment:   summary.Board.Count,
+		peopleCount:    summary.Board.People,
+		hotCount:       summary.Board.Hot,
+		awlCount:       summary.Board.Awl,
+		latency:        summary.Board.Latency,
+		total:          summary.Board.TotalLatency,
+		stats:          summary.Board.Stats,
+		spam:           summary.Board.Spam,
+		minuteUsers:    make(map[int]*set.HashSet[uint64]),
+		hourUsers:      make(map[int]*set.HashSet[uint64]),
+		fansCount:      summary.Account.FansCount,
+		statCount:      summary.Account.Stats,
+		statTimes:      summary.Account.StatTimes,
+		startTime:      time.Unix(summary.Start, 0),
+	}
+	//旧版本的数据总结中没有 stats 字段
+	if counter.statCount == nil {
+		counter.statCount = make(map[string][]int)
+	}
+	//旧版本的数据总结中没有评论的统计数据。每分钟、每小时内的用户没有保存，
+	//恢复后的人数在保存的人数上继续累加，同一用户在中断前后的同一时间段内都发送评论时会被多统计一次
+	if counter.stats.Locations == nil {
+		counter.stats = report.NewCommentStats()
+	}
+	//旧版本的数据总结中没有 words 和 bigrams 字段
+	if counter.stats.Words == nil {
+		counter.stats.Words = make(map[string]int)
+		counter.stats.Bigrams = make(map[string]int)
+	}
+	counter.video = summary.Video
+	return counter
+}
+
 func setAddComments(s *set.HashSet[uint64], c []Comment) {
 	for i := range c {
 		s.Add(c[i].replyId)
@@ -185,8 +260,20 @@ loop:
 				if lastComments.Contains(comment.replyId) {
 					continue
 				}
-				b.work(comment, now)
-				b.counter.Count(comment, now)
+				spam := b.spam.Check(comment)
+				b.work(comment, spam, now)
+				//每条评论只查询一次发评记录，所有统计时段共用
+				var history commenterHistory
+				if spam == "" {
+					history = b.commenterHistory(comment.uid)
+				}
+				for _, w := range b.windows {
+					if spam == "" {
+						w.counter.Count(comment, now, history)
+					} else {
+						w.counter.CountSpam(comment, spam)
+					}
+				}
 				//TODO 监控个人资料修改 #3
 			}
 			if comments == nil {
@@ -201,35 +288,56 @@ loop:
 	b.logger.Info("停止监控")
 }
 
-// MonitorFans 监控粉丝数变化，十分钟更新一次
-func (b *Bot) MonitorFans() {
-	ticker := time.NewTicker(10 * time.Minute)
+// MonitorStats 监控账号的统计数据变化，每隔 statInterval 分钟更新一次
+func (b *Bot) MonitorStats() {
+	ticker := time.NewTicker(time.Duration(b.statInterval) * time.Minute)
 	defer ticker.Stop()
 
-	account := &MonitorAccount{
-		Account: Account{
-			uid: b.monitor.uid,
-		},
-		follower: b.monitor.follower,
+	uid := b.monitor.uid
+	now := time.Now()
+	//开始时的数据
+	if stats := b.bili.AccountStats(uid, b.stats); hasStats(stats, b.stats) {
+		b.eachCounter(func(c *Counter) {
+			if len(c.statTimes) == 0 && len(c.statCount) == 0 {
+				c.addStats(b.stats, stats, now.Unix())
+			}
+		})
 	}
-	fansChange := func(c *Counter, fans int) {
-		c.lock.Lock()
-		defer c.lock.Unlock()
-		c.fansCount = append(c.fansCount, fans)
+	history, err := db.FollowerHistory(uid, now.Add(-time.Duration(b.fans.window)*time.Hour).Unix(), now.Unix())
+	if err != nil {
+		b.logger.Error("查询粉丝数记录失败，%v", err)
 	}
-	counter := b.counter
-	//db.InsertFollower(account.uid, counter.startTime.Unix(), account.follower)
+	alert := NewFansAlert(b.fans, history)
 	for {
 		select {
 		case <-b.stop:
 			return
 		case now := <-ticker.C:
-			if b.bili.AccountStat(account) {
-				b.logger.Info("获取粉丝数，uid=%d, fans=%d", account.uid, account.follower)
-				db.InsertFollower(account.uid, now.Unix(), account.follower)
-				fansChange(counter, account.follower)
+			stats := b.bili.AccountStats(uid, b.stats)
+			if len(stats) == 0 {
+				b.logger.Error("获取统计数据失败，uid=%d", uid)
+				continue
+			}
+			b.logger.Info("获取统计数据，uid=%d, stats=%v", uid, stats)
+			db.InsertAccountStat(uid, now.Unix(), stats)
+			//只获取到部分数据时不计入数据总结，避免各项数据的变化错位，数据库中仍然会保存获取到的数据
+			if hasStats(stats, b.stats) {
+				b.eachCounter(func(c *Counter) {
+					c.fansCount = append(c.fansCount, stats[StatFollower])
+					c.addStats(b.stats, stats, now.Unix())
+				})
 			} else {
-				b.logger.Error("获取粉丝数失败，uid=%d", account.uid)
+				b.logger.Warn("只获取到部分统计数据，uid=%d, stats=%v", uid, stats)
+			}
+			fans, ok := stats[StatFollower]
+			if !ok {
+				continue
+			}
+			db.InsertFollower(uid, now.Unix(), fans)
+			record := FollowerRecord{uid: uid, ctime: now.Unix(), fans: fans}
+			for _, event := range alert.Check(record) {
+				b.logger.Info("粉丝数提醒：milestone=%d, delta=%d", event.milestone, event.Delta())
+				pushMessage(b.logger, defaultChannel, event.Message(b.monitor.alias))
 			}
 		}
 	}
@@ -252,13 +360,22 @@ func (b *Bot) likeComment() {
 	}
 }
 
-//处理评论，now为获取到该评论的时间
-func (b *Bot) work(comment Comment, now time.Time) {
+//处理评论，spam为评论被标记为刷屏的原因，now为获取到该评论的时间
+func (b *Bot) work(comment Comment, spam string, now time.Time) {
+	channel, alias, watched := b.commentAlert(comment)
 	//插入到数据库中
-	db.InsertComment(comment, now.Unix())
+	db.InsertComment(CommentRecord{
+		Comment:  comment,
+		likeTime: now.Unix(),
+		watched:  watched,
+		spam:     spam,
+	})
 	bili := b.bili
 	//点赞该评论
-	if b.isLike {
+	if b.isLike && spam != "" && b.spam.skipLike {
+		b.logger.Info("刷屏的评论，不点赞：reason=%s, msg=%s, uname=%s, uid=%d",
+			spam, comment.msg, comment.uname, comment.uid)
+	} else if b.isLike {
 		select {
 		case b.likeQueue <- comment:
 			break
@@ -288,10 +405,35 @@ func (b *Bot) work(comment Comment, now time.Time) {
 		}
 	}
 	//嘿嘿嘿...33的评论...小小的...香香的...
+	if watched {
+		b.logger.Info("关注的用户发送评论：alias=%s, uid=%d, msg=%s", alias, comment.uid, comment.msg)
+		pushMessage(b.logger, channel, commentMessage(b.board, comment, alias))
+	}
+}
+
+//评论需要推送的提醒，ok 为 false 表示不需要提醒。关注列表中的设置优先，
+//监控的账号也在关注列表中时，同样只有满足关注列表中的关键词才会提醒，并推送到对应的渠道
+func (b *Bot) commentAlert(comment Comment) (channel, alias string, ok bool) {
+	if watch, listed := b.watchlist.Get(comment.uid); listed {
+		return watch.channel, watch.alias, watch.Match(comment)
+	}
 	if comment.uid == b.monitor.uid {
-		pushAndLog(b.logger, "[%s]\n%s的评论：%s",
-			time.Unix(int64(comment.ctime), 0).Format("01-02 15:04:05"),
-			b.monitor.alias, comment.msg)
+		return defaultChannel, b.monitor.alias, true
+	}
+	return "", "", false
+}
+
+//评论提醒的推送消息，点击消息可以直接跳转到对应评论
+func commentMessage(board Board, comment Comment, alias string) push.Message {
+	ctime := time.Unix(int64(comment.ctime), 0).Format("01-02 15:04:05")
+	title := fmt.Sprintf("%s的评论", alias)
+	return push.Message{
+		Title: title,
+		Text:  fmt.Sprintf("[%s]\n%s：%s", ctime, title, comment.msg),
+		Markdown: fmt.Sprintf("#### %s\n\n> %s\n\n###### %s 发布于 %s",
+			title, comment.msg, comment.uname, ctime),
+		Link:  board.CommentURL(comment.replyId),
+		AtAll: true,
 	}
 }
 
@@ -331,32 +473,117 @@ func (r *Reporter) Report(comment Comment, nowTime time.Time) string {
 	return delayMsg
 }
 
-// Count 评论数据计数，nowTime为获取到该评论的时间
-func (c *Counter) Count(comment Comment, nowTime time.Time) {
+//用户在评论区中的发评记录，用于区分新用户和老用户
+type commenterHistory struct {
+	checked bool  //是否查询了发评记录，为 false 时不统计新用户和老用户
+	first   int64 //第一条评论的发布时间，时间戳形式，单位秒，没有评论时为0
+}
+
+//用户在 t 之前是否发送过评论
+func (h commenterHistory) before(t int64) bool {
+	return h.first != 0 && h.first < t
+}
+
+// Count 评论数据计数，nowTime为获取到该评论的时间，history 为发评论的用户的发评记录，
+//需要在调用之前查询，避免持有锁时查询数据库
+func (c *Counter) Count(comment Comment, nowTime time.Time, history commenterHistory) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	first := c.peopleCount[comment.uid] == 0
 	c.peopleCount[comment.uid]++
 	c.todayComment++
 
 	ctime := int64(comment.ctime)
 	index := int(ctime - c.startTime.Unix())
 	if index >= 0 {
+		c.stats.MinuteUsers = countUser(c.minuteUsers, c.stats.MinuteUsers, index/60, comment.uid)
+		c.stats.HourUsers = countUser(c.hourUsers, c.stats.HourUsers, index/3600, comment.uid)
 		index /= 60
 		var hot int
 		c.hotCount, hot = util.SliceGet(c.hotCount, index)
 		c.hotCount = util.SliceSet(c.hotCount, index, hot+1)
 	}
+	if first && history.checked {
+		if history.before(c.startTime.Unix()) {
+			c.stats.ReturningUsers++
+		} else {
+			c.stats.NewUsers++
+		}
+	}
+	c.stats.AddComment(comment.msg, comment.location)
 
 	now := nowTime.Unix()
 	delay := int(now - ctime)
 	index = int(now-c.startTime.Unix()) / 60
 	var d int
 	c.awlCount, d = util.SliceGet(c.awlCount, index)
-	//只记录最大延迟时间，单位：秒
+	//最大延迟时间，单位：秒
 	if delay > d {
 		c.awlCount = util.SliceSet(c.awlCount, index, delay)
 	}
+	var l report.Latency
+	c.latency, l = util.SliceGet(c.latency, index)
+	l.Add(delay)
+	c.latency = util.SliceSet(c.latency, index, l)
+	c.total.Add(delay)
+}
+
+// CountSpam 记录被标记为刷屏的评论，reason 为标记的原因，只计入 spam，不计入其他统计数据
+func (c *Counter) CountSpam(comment Comment, reason string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	minute := -1
+	if index := int64(comment.ctime) - c.startTime.Unix(); index >= 0 {
+		minute = int(index / 60)
+	}
+	c.spam.Add(reason, minute, comment.uid)
+}
+
+//记录用户在第 index 个时间段内发送了评论，返回更新后的每个时间段内的人数
+func countUser(users map[int]*set.HashSet[uint64], counts []int, index int, uid uint64) []int {
+	s, ok := users[index]
+	if !ok {
+		s = set.New[uint64]()
+		users[index] = s
+	}
+	counts, count := util.SliceGet(counts, index)
+	if !s.Contains(uid) {
+		s.Add(uid)
+		count++
+	}
+	return util.SliceSet(counts, index, count)
+}
+
+//查询用户在评论区中的发评记录，没有连接数据库或者查询失败时不统计新用户和老用户
+func (b *Bot) commenterHistory(uid uint64) commenterHistory {
+	if db == nil {
+		return commenterHistory{}
+	}
+	first, err := db.FirstComment(b.board.oid, uid)
+	if err != nil {
+		b.logger.Error("查询评论失败，uid=%d, %v", uid, err)
+		return commenterHistory{}
+	}
+	return commenterHistory{checked: true, first: first}
+}
+
+//是否获取到了所有需要记录的数据项
+func hasStats(stats map[string]int, metrics []string) bool {
+	for _, metric := range metrics {
+		if _, ok := stats[metric]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+//记录一次账号的各项统计数据，ctime 为记录时间，调用时需要持有 counter 的锁
+func (c *Counter) addStats(metrics []string, stats map[string]int, ctime int64) {
+	for _, metric := range metrics {
+		c.statCount[metric] = append(c.statCount[metric], stats[metric])
+	}
+	c.statTimes = append(c.statTimes, ctime)
 }
 
 //重置
@@ -366,41 +593,66 @@ func (c *Counter) reset() {
 	c.peopleCount = make(map[uint64]int)
 	c.hotCount = make([]int, 0, CountCap)
 	c.awlCount = make([]int, 0, CountCap)
+	c.latency = make([]report.Latency, 0, CountCap)
+	c.total = report.Latency{}
+	c.stats = report.NewCommentStats()
+	c.spam = report.SpamStats{}
+	c.minuteUsers = make(map[int]*set.HashSet[uint64])
+	c.hourUsers = make(map[int]*set.HashSet[uint64])
 	c.fansCount = make([]int, 0)
 	c.startTime = time.Now()
+	//上一个统计时段最后一次记录的统计数据作为开始时的数据
+	statCount := make(map[string][]int, len(c.statCount))
+	for metric, values := range c.statCount {
+		if len(values) > 0 {
+			statCount[metric] = []int{values[len(values)-1]}
+		}
+	}
+	c.statCount, c.statTimes = statCount, nil
+	if len(statCount) > 0 {
+		c.statTimes = []int64{c.startTime.Unix()}
+	}
+	if c.video != nil {
+		c.video = c.video.Next()
+	}
 }
 
-type Summary struct {
-	Version string `json:"version"` //对应程序的版本号
-	Start   int64  `json:"start"`   //统计的开始时间
-	End     int64  `json:"end"`     //统计结束时间
-	Board   struct {
-		Name          string         `json:"name"`          //版聊区名称
-		DynamicId     uint64         `json:"dynamicId"`     //对应的动态id
-		BvID          string         `json:"bvID"`          //如果是视频评论区，则是对应视频的bv号，否则为空
-		Oid           uint64         `json:"oid"`           //oid
-		Hot           []int          `json:"hot"`           //每分钟内的评论数
-		Awl           []int          `json:"awl"`           //每分钟内的最大延迟
-		People        map[uint64]int `json:"people"`        //参与评论的用户，键为uid, 值为发送的评论数
-		Count         int            `json:"count"`         //记录到的评论数，不含楼中楼
-		StartAllCount int            `json:"startAllCount"` //开始时的总评论数，包含楼中楼
-		StartCount    int            `json:"startCount"`    //开始时的评论数，不含楼中楼
-		EndAllCount   int            `json:"endAllCount"`   //结束时的总评论数，包含楼中楼
-		EndCount      int            `json:"endCount"`      //结束时的评论数，不含楼中楼
-	} `json:"board"`
-	Account struct {
-		Name           string `json:"name"`           //用户名
-		Alias          string `json:"alias"`          //别名
-		Uid            uint64 `json:"uid"`            //uid
-		StartFollowers int    `json:"startFollowers"` //粉丝数
-		EndFollowers   int    `json:"endFollowers"`
-		FansCount      []int  `json:"fansCount"` //粉丝数变化
-	} `json:"account"`
+//统计器当前的数据，不包含结束时评论区和账号的数据，调用时需要持有 counter 的锁
+func (b *Bot) snapshot(counter *Counter) report.Summary {
+	summary := report.Summary{Version: Version, Window: counter.window}
+	summary.Board.Name = b.board.name
+	summary.Board.DynamicId = b.board.dId
+	summary.Board.BvID = b.board.bvID
+	summary.Board.Oid = b.board.oid
+	summary.Start = counter.startTime.Unix()
+	summary.End = time.Now().Unix()
+	summary.Board.Hot = counter.hotCount
+	summary.Board.Awl = counter.awlCount
+	summary.Board.Latency = counter.latency
+	summary.Board.TotalLatency = counter.total
+	summary.Board.Stats = counter.stats.TrimWords(report.WordsLimit)
+	summary.Board.Spam = counter.spam
+	summary.Board.People = counter.peopleCount
+	summary.Board.Count = counter.todayComment
+	summary.Board.StartAllCount = counter.startAllCount
+	summary.Board.StartCount = counter.startCount
+
+	summary.Account.Name = b.monitor.uname
+	summary.Account.Uid = b.monitor.uid
+	summary.Account.Alias = b.monitor.alias
+	summary.Account.StartFollowers = counter.startFollowers
+	summary.Account.FansCount = counter.fansCount
+	summary.Account.StatInterval = b.statInterval
+	summary.Account.Stats = counter.statCount
+	summary.Account.StatTimes = counter.statTimes
+	summary.Video = counter.video
+	return summary
 }
 
-// Summarize 总结评论数据
-func (b *Bot) Summarize() string {
-	counter := b.counter
+// Summarize 总结统计时段的评论数据，保存为json文件并写入数据库，然后重新开始统计，
+//interrupted 表示是否为程序中断时生成。返回json文件名和数据总结，未统计到数据时文件名为空
+func (b *Bot) Summarize(w *SummaryWindow, interrupted bool) (string, report.Summary) {
+	counter := w.counter
 	counter.lock.Lock()
 	defer counter.lock.Unlock()
 	board := &Board{
@@ -416,83 +668,76 @@ func (b *Bot) Summarize() string {
 	//未统计到数据
 	if len(counter.hotCount) == 0 && len(counter.fansCount) == 1 {
 		b.logger.Warn("未统计到数据")
-		return ""
+		return "", report.Summary{}
 	}
 	b.bili.GetCommentsPage(board)
 	b.bili.AccountInfo(account)
 	b.bili.AccountStat(account)
 
-	report := Summary{Version: Version}
-	report.Board.Name = b.board.name
-	report.Board.DynamicId = b.board.dId
-	report.Board.BvID = b.board.bvID
-	report.Board.Oid = b.board.oid
-	report.Start = counter.startTime.Unix()
-	report.End = time.Now().Unix()
-	report.Board.Hot = counter.hotCount
-	report.Board.Awl = counter.awlCount
-	report.Board.People = counter.peopleCount
-	report.Board.Count = counter.todayComment
-	report.Board.StartAllCount = b.board.allCount
-	report.Board.StartCount = b.board.count
-	report.Board.EndAllCount = board.allCount
-	report.Board.EndCount = board.count
-
-	report.Account.Name = account.uname
-	report.Account.Uid = b.monitor.uid
-	report.Account.Alias = b.monitor.alias
-	report.Account.StartFollowers = b.monitor.follower
-	report.Account.EndFollowers = account.follower
-	report.Account.FansCount = counter.fansCount
-
-	reportJson, _ := json.Marshal(report)
+	summary := b.snapshot(counter)
+	summary.Board.EndAllCount = board.allCount
+	summary.Board.EndCount = board.count
+	summary.Account.Name = account.uname
+	summary.Account.EndFollowers = account.follower
+	db.addTrending(&summary)
+
+	reportJson, _ := json.Marshal(summary)
 	now := time.Now()
-	fileName := fmt.Sprintf("./report/%s.json", now.Format("200601021504"))
+	fileName := w.summaryFile(now)
 	jsonFile, err := os.Create(fileName)
 	if err != nil && os.IsNotExist(err) {
 		err = os.Mkdir("./report", os.ModePerm)
 		if util.IsError(err, "creat dir report fail!") {
 			_, _ = os.Stdout.Write(reportJson)
-			return ""
+			return "", summary
 		}
 		jsonFile, _ = os.Create(fileName)
 	}
 	_, _ = jsonFile.Write(reportJson)
 	_ = jsonFile.Close()
+	db.InsertSummary(summary, fileName, interrupted)
 	b.monitor.follower = account.follower
 	b.monitor.uname = account.uname
 	b.board.allCount = board.allCount
 	b.board.count = board.count
 	counter.reset()
+	counter.startAllCount = board.allCount
+	counter.startCount = board.count
+	counter.startFollowers = account.follower
 	b.logger.Info("数据保存为：%s", fileName)
-	return fileName
+	return fileName, summary
 }
 
-func (b *Bot) ReportSummarize(fileName string) {
-	//调用python脚本，处理数据并发布动态
-	var cmd *exec.Cmd
-	if b.isPost {
-		cmd = exec.Command("python", "./analyse/main.py", fileName, "post")
-	} else {
-		cmd = exec.Command("python", "./analyse/main.py", fileName)
+// ReportSummarize 生成数据总结的文字和图表，开启 isPost 时发布数据总结的动态
+func (b *Bot) ReportSummarize(summary report.Summary) {
+	r := report.New(summary)
+	text, err := r.Text(b.reportTmpl)
+	if err != nil {
+		b.logger.Error("生成数据总结失败，%v", err)
+		pushAndLog(b.logger, "生成数据总结失败，%v", err)
+		return
 	}
-	b.logger.Info("run python command: %s", cmd.String())
-	cmd.Stdout = logDst
-	cmd.Stderr = logDst
-	err := cmd.Start()
+	b.logger.Info("%s", text)
+	b.logger.Info("记录的评论数：%d", r.Recorded)
+	b.logger.Info("最佳人之初：uid:%d", r.Top().Uid)
+	files, err := saveCharts(summary, b.chart, b.chartFormats, chartDir)
 	if err != nil {
-		b.logger.Error("run python error: %v", err)
-		pushAndLog(b.logger, "运行python脚本出现错误，%v", err)
+		b.logger.Error("绘制图表失败，%v", err)
+		pushAndLog(b.logger, "绘制图表失败，%v", err)
+		return
+	}
+	b.logger.Info("保存图表：%v", files)
+	if !b.isPost {
 		return
 	}
-	go func() {
-		//等待子进程结束并释放资源
-		err = cmd.Wait()
-		if err != nil {
-			b.logger.Error("脚本运行出现错误，%v", err)
-			pushAndLog(b.logger, "脚本运行出现错误，%v", err)
+	//动态中只能使用png图片
+	images := make([]string, 0, len(files))
+	for _, f := range files {
+		if filepath.Ext(f) == ".png" {
+			images = append(images, f)
 		}
-	}()
+	}
+	go b.postSummary(text, images)
 }
 
 // MonitorDynamic 动态监控 TODO
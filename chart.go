@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+	"github.com/tidwall/gjson"
+)
+
+//图表的保存目录
+const chartDir = "./report/img"
+
+//读取图表的配置，chart 为 setting.json 中的 report.chart，返回绘制的配置和保存的图片格式
+func readChartOption(chart gjson.Result) (report.ChartOption, []string, error) {
+	opt := report.ChartOption{
+		Bucket: int(chart.Get("bucket").Int()), //聚合数据的时长，单位：分钟
+		Width:  int(chart.Get("width").Int()),  //图片的宽度
+		Height: int(chart.Get("height").Int()), //图片的高度
+		Titles: make(map[string]string),        //图表的标题
+	}
+	chart.Get("titles").ForEach(func(key, value gjson.Result) bool {
+		opt.Titles[key.String()] = value.String()
+		return true
+	})
+	//字体文件，为空时使用内置字体
+	if fontFile := chart.Get("font").String(); fontFile != "" {
+		data, err := os.ReadFile(fontFile)
+		if err != nil {
+			return opt, nil, err
+		}
+		opt.Font = data
+	}
+	//保存的图片格式，默认同时保存为png和svg
+	var formats []string
+	for _, f := range chart.Get("formats").Array() {
+		formats = append(formats, f.String())
+	}
+	if !chart.Get("formats").Exists() {
+		formats = []string{"png", "svg"}
+	}
+	//检查字体是否可用
+	_, err := report.NewChartRenderer(opt)
+	return opt, formats, err
+}
+
+//绘制数据总结的图表并保存到 dir 目录中，返回保存的文件名
+func saveCharts(summary report.Summary, opt report.ChartOption, formats []string, dir string) ([]string, error) {
+	r, err := report.NewChartRenderer(opt)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, chart := range report.Charts(summary, opt) {
+		for _, format := range formats {
+			name, err := r.Save(dir, chart, format)
+			if err != nil {
+				return files, err
+			}
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+//根据数据总结绘制图表
+func chartCmd(args []string) int {
+	fs := flag.NewFlagSet("chart", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名，从数据库中读取数据总结时使用")
+	name := fs.String("r", "", "数据总结，json文件名或 db:latest，db:<id>")
+	dir := fs.String("o", chartDir, "图片的保存目录")
+	format := fs.String("format", "", "图片格式，多个格式使用逗号分隔，默认读取 setting.json")
+	_ = fs.Parse(args)
+	if *name == "" {
+		mainLogger.Error("需要指定数据总结")
+		return 2
+	}
+
+	opt, formats, err := readChartOption(settingValue("report.chart"))
+	if err != nil {
+		mainLogger.Error("读取图表配置失败，%v", err)
+		return 1
+	}
+	if *format != "" {
+		formats = strings.Split(*format, ",")
+	}
+	var summary report.Summary
+	id, ok, err := parseRecoverID(*name)
+	if err == nil && ok {
+		d := NewDB(*dbname, DBOption{})
+		if d == nil {
+			return 1
+		}
+		summary, err = d.LoadSummary(id)
+		d.Close()
+	} else if err == nil {
+		summary, err = loadSummary(*name, 0, "")
+	}
+	if err != nil {
+		mainLogger.Error("读取数据总结失败，%v", err)
+		return 1
+	}
+	files, err := saveCharts(summary, opt, formats, *dir)
+	if err != nil {
+		mainLogger.Error("绘制图表失败，%v", err)
+		return 1
+	}
+	for _, f := range files {
+		mainLogger.Info("保存图表：%s", f)
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+)
+
+//没有名称的统计时段的检查点文件，格式和数据总结相同
+const checkpointFile = "./report/checkpoint.json"
+
+//写入文件，先写入临时文件再重命名，保证程序崩溃时文件内容是完整的
+func writeFileAtomic(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+// Checkpoint 保存所有统计时段的数据，程序崩溃后重新启动时可以从检查点恢复
+func (b *Bot) Checkpoint() {
+	for _, w := range b.windows {
+		b.checkpointWindow(w)
+	}
+}
+
+//保存统计时段的检查点
+func (b *Bot) checkpointWindow(w *SummaryWindow) {
+	if b.checkpoint <= 0 {
+		return
+	}
+	w.counter.lock.Lock()
+	data, err := json.Marshal(b.snapshot(w.counter))
+	w.counter.lock.Unlock()
+	file := w.checkpointFile()
+	if err == nil {
+		err = writeFileAtomic(file, data)
+	}
+	if err != nil {
+		b.logger.Error("保存检查点失败，%v", err)
+		return
+	}
+	b.logger.Debug("保存检查点：%s", file)
+}
+
+// MonitorCheckpoint 每隔 checkpoint 分钟保存一次检查点
+func (b *Bot) MonitorCheckpoint() {
+	if b.checkpoint <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(b.checkpoint) * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.Checkpoint()
+		}
+	}
+}
+
+// ResumeCheckpoints 从检查点恢复每个统计时段的数据
+func (b *Bot) ResumeCheckpoints(now time.Time) {
+	if b.checkpoint <= 0 {
+		return
+	}
+	for _, w := range b.windows {
+		summary, ok := resumeCheckpoint(b.board, w.WindowOption, now)
+		if !ok {
+			continue
+		}
+		w.counter = recoverCounter(summary, w.name, b.board.oid)
+		b.logger.Info("从检查点恢复：window=%s, start=%s", w.name,
+			w.counter.startTime.Format("01-02 15:04:05"))
+	}
+	b.counter = b.windows[0].counter
+}
+
+//读取检查点，只有属于同一个评论区和同一个统计时段时才能恢复，统计时段的开始时间为 now 之前最近一次触发时间。
+//不能恢复的检查点会作为中断时的数据总结保存到数据库中，然后删除。
+//程序正常退出时已经保存了中断时的数据总结，此时不重复保存
+func resumeCheckpoint(board Board, w WindowOption, now time.Time) (report.Summary, bool) {
+	var summary report.Summary
+	file := w.checkpointFile()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			mainLogger.Warn("读取检查点失败，%v", err)
+		}
+		return summary, false
+	}
+	if err = json.Unmarshal(data, &summary); err != nil {
+		mainLogger.Warn("解析检查点失败，%v", err)
+		return summary, false
+	}
+	if summary.Board.DynamicId != board.dId || summary.Board.BvID != board.bvID {
+		mainLogger.Info("检查点属于其他评论区，不恢复：%s", summary.Board.Name)
+		return summary, false
+	}
+	if start := w.schedule.Prev(now); summary.Start < start.Unix() {
+		mainLogger.Info("检查点属于之前的统计时段，不恢复：start=%s",
+			time.Unix(summary.Start, 0).Format("01-02 15:04:05"))
+		exists, err := db.hasSummary(summary.Board.Oid, summary.Window, summary.Start)
+		switch {
+		case err != nil:
+			mainLogger.Error("查询数据总结失败，%v", err)
+			return summary, false
+		case !exists:
+			db.InsertSummary(summary, "", true)
+		}
+		_ = os.Remove(file)
+		return summary, false
+	}
+	return summary, true
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+)
+
+func TestWindowStart(t *testing.T) {
+	at := func(day, h, m int) time.Time {
+		return time.Date(2022, 7, day, h, m, 0, 0, time.Local)
+	}
+	tests := []struct {
+		now  time.Time
+		h, m int
+		want time.Time
+	}{
+		{at(2, 8, 0), 7, 33, at(2, 7, 33)},
+		{at(2, 7, 33), 7, 33, at(2, 7, 33)},
+		{at(2, 7, 32), 7, 33, at(1, 7, 33)},
+		{at(2, 8, 40), -1, 30, at(2, 8, 30)},
+		{at(2, 8, 10), -1, 30, at(2, 7, 30)},
+	}
+	//统计时段的开始时间为之前最近一次生成数据总结的时间
+	for _, tt := range tests {
+		schedule, err := legacySchedule(tt.h, tt.m, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := schedule.Prev(tt.now); !got.Equal(tt.want) {
+			t.Errorf("Prev(%v), h=%d, m=%d = %v, want %v", tt.now, tt.h, tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "report", "checkpoint.json")
+	for _, data := range []string{"first", "second"} {
+		if err := writeFileAtomic(name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(name); string(got) != data {
+			t.Errorf("want %s, got %s", data, got)
+		}
+	}
+	//不残留临时文件
+	if entries, _ := os.ReadDir(filepath.Dir(name)); len(entries) != 1 {
+		t.Errorf("want 1 file, got %d", len(entries))
+	}
+}
+
+func TestRecoverCounter(t *testing.T) {
+	start := time.Date(2022, 7, 2, 7, 33, 0, 0, time.Local)
+	var summary report.Summary
+	summary.Start = start.Unix()
+	summary.Board.People = map[uint64]int{1: 2, 2: 1, 3: 1}
+	summary.Board.Stats = report.NewCommentStats()
+	summary.Board.Stats.MinuteUsers = []int{2, 1}
+	summary.Board.Stats.HourUsers = []int{3}
+	counter := recoverCounter(summary, "", 10)
+
+	//恢复后在保存的人数上继续累加，同一时间段内的同一用户只统计一次
+	ctime := uint64(start.Unix() + 70)
+	for _, uid := range []uint64{4, 4, 5} {
+		counter.Count(Comment{Account: Account{uid: uid}, ctime: ctime, msg: "晚安"}, start.Add(2*time.Minute), commenterHistory{})
+	}
+	if got := counter.stats.MinuteUsers; !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("minute users: want [2 3], got %v", got)
+	}
+	if got := counter.stats.HourUsers; !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("hour users: want [5], got %v", got)
+	}
+}
+
+func TestCounter_addStats(t *testing.T) {
+	c := newCounter("", time.Unix(1000, 0))
+	metrics := []string{StatFollower, StatView}
+	c.addStats(metrics, map[string]int{StatFollower: 100, StatView: 5, StatFollowing: 3}, 1000)
+	c.addStats(metrics, map[string]int{StatFollower: 110, StatView: 8}, 1600)
+	want := map[string][]int{StatFollower: {100, 110}, StatView: {5, 8}}
+	if !reflect.DeepEqual(c.statCount, want) || !reflect.DeepEqual(c.statTimes, []int64{1000, 1600}) {
+		t.Errorf("want %v, got %v, times=%v", want, c.statCount, c.statTimes)
+	}
+	if hasStats(map[string]int{StatFollower: 120}, metrics) {
+		t.Error("hasStats: want false for partial stats")
+	}
+
+	//新的统计时段以最后一次记录的数据开始
+	c.reset()
+	want = map[string][]int{StatFollower: {110}, StatView: {8}}
+	if !reflect.DeepEqual(c.statCount, want) || len(c.statTimes) != 1 || c.statTimes[0] != c.startTime.Unix() {
+		t.Errorf("reset: want %v, got %v, times=%v", want, c.statCount, c.statTimes)
+	}
+}
+
+func TestResumeCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	oldDB := db
+	defer func() {
+		db = oldDB
+		_ = os.Chdir(wd)
+	}()
+	db = NewDB(filepath.Join(dir, "test.db"), DBOption{})
+	if db == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer db.Close()
+
+	schedule, err := legacySchedule(7, 33, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := WindowOption{schedule: schedule}
+	board := Board{oid: 10, dId: 1}
+	now := time.Date(2022, 7, 3, 8, 0, 0, 0, time.Local)
+	write := func(start time.Time) {
+		var summary report.Summary
+		summary.Board.Oid, summary.Board.DynamicId, summary.Start = 10, 1, start.Unix()
+		data, _ := json.Marshal(summary)
+		if err := writeFileAtomic(w.checkpointFile(), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		db.Flush()
+		var n int
+		if err := db.conn.QueryRow("select count(*) from summary").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	//当前统计时段的检查点可以恢复
+	write(time.Date(2022, 7, 3, 7, 33, 0, 0, time.Local))
+	if _, ok := resumeCheckpoint(board, w, now); !ok || count() != 0 {
+		t.Fatalf("want resumed without summary, got %v, %d", ok, count())
+	}
+	//之前的统计时段的检查点保存为中断时的数据总结，已经保存过时不重复保存
+	prev := time.Date(2022, 7, 2, 7, 33, 0, 0, time.Local)
+	for i := 0; i < 2; i++ {
+		write(prev)
+		if _, ok := resumeCheckpoint(board, w, now); ok || count() != 1 {
+			t.Errorf("%d: want 1 summary, got %v, %d", i, ok, count())
+		}
+		if _, err = os.Stat(w.checkpointFile()); !os.IsNotExist(err) {
+			t.Errorf("%d: checkpoint not removed, %v", i, err)
+		}
+	}
+}
+
+func TestCounter_CountHistory(t *testing.T) {
+	start := time.Date(2022, 7, 2, 7, 33, 0, 0, time.Local)
+	counter := newCounter("", start)
+	ctime := uint64(start.Unix() + 70)
+	tests := []struct {
+		uid     uint64
+		history commenterHistory
+	}{
+		{1, commenterHistory{checked: true, first: start.Unix() - 100}},
+		{2, commenterHistory{checked: true, first: int64(ctime)}},
+		{3, commenterHistory{checked: true}},
+		//没有查询发评记录时不统计
+		{4, commenterHistory{}},
+		//同一用户只统计一次
+		{1, commenterHistory{checked: true, first: start.Unix() - 100}},
+	}
+	for _, tt := range tests {
+		counter.Count(Comment{Account: Account{uid: tt.uid}, ctime: ctime, msg: "晚安"}, start.Add(2*time.Minute), tt.history)
+	}
+	if counter.stats.ReturningUsers != 1 || counter.stats.NewUsers != 2 {
+		t.Errorf("want 1 returning and 2 new users, got %d, %d", counter.stats.ReturningUsers, counter.stats.NewUsers)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/logger"
+	"github.com/tidwall/gjson"
+)
+
+// command 子命令，例如：bobo-bot dedup -db database.db
+type command struct {
+	usage string                  //命令说明
+	run   func(args []string) int //执行命令，返回值为程序的退出码
+}
+
+var commands = map[string]command{
+	"aggregate": {"生成周报或月报", aggregateCmd},
+	"backfill":  {"补全评论区的历史评论", backfillCmd},
+	"chart":     {"根据数据总结绘制图表", chartCmd},
+	"dedup":     {"删除数据库中重复的评论", dedupCmd},
+	"export":    {"导出数据库中的数据", exportCmd},
+	"rebuild":   {"使用数据库中的数据重新生成数据总结", rebuildCmd},
+	"search":    {"全文搜索评论", searchCmd},
+	"summaries": {"查看和比较数据总结", summariesCmd},
+}
+
+//执行子命令，ok 为 false 表示不是子命令
+func runCommand(args []string) (code int, ok bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	if args[0] == "help" {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("usage: bobo-bot [-r file] | bobo-bot <command> [options]")
+		for _, name := range names {
+			fmt.Printf("  %-10s %s\n", name, commands[name].usage)
+		}
+		return 0, true
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return 0, false
+	}
+	//子命令的结果输出到标准输出，日志输出到标准错误，避免混入导出的数据、数据总结和报告中
+	logDst = logger.NewStderrAppender()
+	mainLogger = logger.New("main", logLevel, logDst)
+	return cmd.run(args[1:]), true
+}
+
+//从 setting.json 中读取配置项，读取失败时返回空值
+func settingValue(path string) gjson.Result {
+	data, err := os.ReadFile("setting.json")
+	if err != nil {
+		return gjson.Result{}
+	}
+	return gjson.GetBytes(data, path)
+}
+
+//从 setting.json 中读取数据库文件名，读取失败时使用默认的文件名
+func settingDBName() string {
+	if name := settingValue("config.dbname").String(); name != "" {
+		return name
+	}
+	return "database.db"
+}
+
+//解析命令行参数中的时间，支持日期、日期+时间和时间戳，使用本地时区，空字符串返回0。
+//end 为 true 且只有日期时，返回该日期的最后一秒
+func parseTime(s string, end bool) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ts, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		return t.Unix(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("无法解析时间：%s", s)
+}
+
+//删除重复的评论，用于旧版本程序创建的数据库
+func dedupCmd(args []string) int {
+	fs := flag.NewFlagSet("dedup", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名")
+	dryRun := fs.Bool("dry-run", false, "只统计重复的评论，不删除")
+	_ = fs.Parse(args)
+
+	conn := openDB(*dbname, false)
+	if conn == nil {
+		return 1
+	}
+	defer conn.Close()
+	duplicates, err := countDuplicates(conn)
+	if err != nil {
+		mainLogger.Error("统计重复的评论失败，%v", err)
+		return 1
+	}
+	mainLogger.Info("有 %d 条评论存在重复记录", duplicates)
+	if duplicates == 0 || *dryRun {
+		return 0
+	}
+	if err = backupDB(conn, *dbname, "dedup"); err != nil {
+		mainLogger.Error("%v", err)
+		return 1
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		mainLogger.Error("开启事务失败，%v", err)
+		return 1
+	}
+	deleted, err := dedupComments(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		mainLogger.Error("删除重复的评论失败，%v", err)
+		return 1
+	}
+	if err = tx.Commit(); err != nil {
+		mainLogger.Error("提交事务失败，%v", err)
+		return 1
+	}
+	mainLogger.Info("删除了 %d 条重复的记录", deleted)
+	return 0
+}
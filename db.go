@@ -2,7 +2,9 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/Hami-Lemon/bobo-bot/logger"
 	_ "github.com/mattn/go-sqlite3"
@@ -10,28 +12,23 @@ import (
 
 type DB struct {
 	conn   *sql.DB
+	writer *dbWriter //写入协程，所有写入操作都由该协程完成
+	fts    bool      //是否可以使用全文索引
 	logger *logger.Logger
 }
 
-// NewDB 连接数据库
-func NewDB(dbname string) *DB {
-	_, err := os.Open(dbname)
-	isNotExist := err != nil && os.IsNotExist(err)
-	sqliteDB, err := sql.Open("sqlite3", dbname)
-	if err != nil {
-		mainLogger.Error("连接数据库失败！%v", err)
-		return nil
-	}
-	err = sqliteDB.Ping()
-	if err != nil {
-		mainLogger.Error("连接数据库失败！name=%s, err=%v", dbname, err)
-		return nil
-	}
-	mainLogger.Debug("连接 sqlite3 数据库 %s 成功", dbname)
-	if isNotExist {
-		mainLogger.Debug("创建 comment 表")
-		//建表
-		_, err = sqliteDB.Exec(`create table comment
+// migration 数据库的结构变更，version 从1开始递增
+type migration struct {
+	version int                 //变更后的版本号
+	desc    string              //变更说明
+	up      func(*sql.Tx) error //执行变更
+}
+
+//所有的结构变更，按版本号升序，只能在末尾添加新的变更，不能修改已有的变更
+var migrations = []migration{
+	{1, "创建 comment 表和 follower 表", func(tx *sql.Tx) error {
+		//旧版本创建的数据库中已经有这两个表
+		_, err := tx.Exec(`create table if not exists comment
 (
     id integer primary key autoincrement ,
     oid       integer, -- 评论区oid
@@ -45,21 +42,188 @@ func NewDB(dbname string) *DB {
     location  text 	   --ip归属地
 );`)
 		if err != nil {
-			mainLogger.Error("建立 comment 表失败，%v", err)
-			return nil
+			return err
 		}
-		mainLogger.Debug("创建 follower 表")
-		_, err = sqliteDB.Exec(`create table follower
+		_, err = tx.Exec(`create table if not exists follower
 (
     id    integer primary key autoincrement,
     uid   integer, -- 账号对应的uid
     ctime integer, -- 对应的时间点,时间戳形式单位秒
     fans  integer  -- 粉丝数
 );`)
+		return err
+	}},
+	{2, "comment 表添加 watched 列", func(tx *sql.Tx) error {
+		//是否为关注列表中的用户发送
+		return addColumn(tx, "comment", "watched", "integer default 0")
+	}},
+	{3, "创建 account_stat 表", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`create table if not exists account_stat
+(
+    id     integer primary key autoincrement,
+    uid    integer, -- 账号对应的uid
+    ctime  integer, -- 对应的时间点,时间戳形式单位秒
+    metric text,    -- 数据项名称，例如 follower, view
+    value  integer  -- 数据项的值
+);`)
+		return err
+	}},
+	{4, "创建 video_stat 表", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`create table if not exists video_stat
+(
+    id       integer primary key autoincrement,
+    aid      integer, -- 视频的av号
+    bvid     text,    -- 视频的bv号
+    ctime    integer, -- 对应的时间点,时间戳形式单位秒
+    view     integer, -- 播放数
+    danmaku  integer, -- 弹幕数
+    reply    integer, -- 评论数
+    favorite integer, -- 收藏数
+    coin     integer, -- 投币数
+    share    integer, -- 分享数
+    like     integer  -- 点赞数
+);`)
+		return err
+	}},
+	{5, "comment 表添加 (oid, rpid) 唯一索引", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "comment", "like_count", "integer default 0"); err != nil { //点赞数
+			return err
+		}
+		if err := addColumn(tx, "comment", "reply_count", "integer default 0"); err != nil { //回复数
+			return err
+		}
+		duplicates, err := countDuplicates(tx)
 		if err != nil {
-			mainLogger.Error("创建 follower 表失败，%v", err)
-			return nil
+			return err
+		}
+		if duplicates > 0 {
+			return duplicatesError(duplicates)
+		}
+		_, err = tx.Exec("create unique index if not exists comment_oid_rpid on comment (oid, rpid)")
+		return err
+	}},
+	{6, "创建 summary 表", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`create table if not exists summary
+(
+    id              integer primary key autoincrement,
+    oid             integer, -- 评论区oid
+    board_name      text,    -- 评论区名称
+    uid             integer, -- 监控的账号uid
+    start_time      integer, -- 统计的开始时间
+    end_time        integer, -- 统计的结束时间
+    comment_count   integer, -- 记录到的评论数
+    people_count    integer, -- 参与评论的人数
+    peak_hot        integer, -- 每分钟评论数的最大值
+    peak_time       integer, -- 评论数最多的一分钟的开始时间
+    max_delay       integer, -- 最大延迟,单位秒
+    start_followers integer, -- 开始时的粉丝数
+    end_followers   integer, -- 结束时的粉丝数
+    start_all_count integer, -- 开始时的总评论数,包含楼中楼
+    end_all_count   integer, -- 结束时的总评论数,包含楼中楼
+    interrupted     integer, -- 是否为程序中断时生成
+    file            text,    -- 对应的json文件
+    data            text     -- 完整的数据总结,json格式
+);
+create index if not exists summary_end_time on summary (end_time);`)
+		return err
+	}},
+	{7, "comment 表添加 source 列和 root 列，创建 backfill_cursor 表", func(tx *sql.Tx) error {
+		//评论的来源，live：运行时获取，backfill：补全历史评论时获取，统计运行时的数据时只使用 live
+		if err := addColumn(tx, "comment", "source", "text default 'live'"); err != nil {
+			return err
 		}
+		//楼中楼对应的楼主评论id，不是楼中楼时为0
+		if err := addColumn(tx, "comment", "root", "integer default 0"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`create table if not exists backfill_cursor
+(
+    oid        integer primary key, -- 评论区oid
+    type_code  integer,             -- 评论区type
+    next       integer,             -- 下一页的游标
+    done       integer,             -- 是否已经获取完所有评论
+    count      integer,             -- 已获取的评论数,包含楼中楼
+    updated_at integer              -- 更新时间,时间戳形式单位秒
+);`)
+		return err
+	}},
+	{8, "comment 表创建 oid, uid 索引", func(tx *sql.Tx) error {
+		//用于查询用户之前是否在评论区发送过评论
+		_, err := tx.Exec("create index if not exists comment_oid_uid on comment (oid, uid, ctime)")
+		return err
+	}},
+	{9, "summary 表添加 window_name 列", func(tx *sql.Tx) error {
+		//数据总结对应的统计时段名称，只有一个统计时段时为空
+		return addColumn(tx, "summary", "window_name", "text default ''")
+	}},
+	{10, "comment 表添加 spam 列", func(tx *sql.Tx) error {
+		//评论被标记为刷屏的原因，正常的评论为空
+		return addColumn(tx, "comment", "spam", "text default ''")
+	}},
+}
+
+// SchemaVersion 程序支持的数据库版本
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// NewDB 连接数据库，并将数据库结构更新到最新版本
+func NewDB(dbname string, opt DBOption) *DB {
+	sqliteDB := openDB(dbname, false)
+	if sqliteDB == nil {
+		return nil
+	}
+	if err := migrate(sqliteDB, dbname); err != nil {
+		mainLogger.Error("更新数据库结构失败，%v", err)
+		_ = sqliteDB.Close()
+		return nil
+	}
+	fts, err := ensureFTS(sqliteDB)
+	if err != nil {
+		mainLogger.Error("创建全文索引失败，%v", err)
+		_ = sqliteDB.Close()
+		return nil
+	}
+	if !fts {
+		mainLogger.Debug("sqlite 未启用 FTS5，不能使用全文搜索")
+	}
+	dbLogger := logger.New("db", logLevel, logDst)
+	writer, err := newDBWriter(sqliteDB, opt, dbLogger)
+	if err != nil {
+		mainLogger.Error("创建写入协程失败，%v", err)
+		_ = sqliteDB.Close()
+		return nil
+	}
+	return &DB{
+		conn:   sqliteDB,
+		writer: writer,
+		fts:    fts,
+		logger: dbLogger,
+	}
+}
+
+// NewReadOnlyDB 以只读方式连接数据库，不更新数据库结构，也不能写入数据。
+//数据库版本和程序支持的版本不一致时返回nil，需要先运行一次程序更新数据库结构
+func NewReadOnlyDB(dbname string) *DB {
+	//sqlite 连接不存在的数据库时会创建一个空的数据库文件
+	if _, err := os.Stat(dbname); err != nil {
+		mainLogger.Error("连接数据库失败！%v", err)
+		return nil
+	}
+	sqliteDB := openDB(dbname, true)
+	if sqliteDB == nil {
+		return nil
+	}
+	version, err := readVersion(sqliteDB)
+	if err != nil {
+		mainLogger.Error("获取数据库版本失败，%v", err)
+		_ = sqliteDB.Close()
+		return nil
+	}
+	if latest := SchemaVersion(); version != latest {
+		mainLogger.Error("数据库版本(%d)和程序支持的版本(%d)不一致，请先运行一次程序更新数据库结构", version, latest)
+		_ = sqliteDB.Close()
+		return nil
 	}
 	return &DB{
 		conn:   sqliteDB,
@@ -67,42 +231,262 @@ func NewDB(dbname string) *DB {
 	}
 }
 
-// InsertComment 向数据库中插入评论数据
-func (d *DB) InsertComment(comment Comment, likeTime int64) {
-	stmt, err := d.conn.Prepare(`insert into comment
-(oid, type_code, rpid, ctime, msg, like_time, uid, uname, location)
-values (?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+//连接数据库，不对数据库结构做任何修改，readOnly 为 true 时不允许修改数据库
+func openDB(dbname string, readOnly bool) *sql.DB {
+	//使用 WAL 模式，写入时不会阻塞读取
+	dsn := dbname + "?_journal_mode=WAL&_busy_timeout=5000"
+	if readOnly {
+		dsn += "&_query_only=true"
+	}
+	sqliteDB, err := sql.Open("sqlite3", dsn)
 	if err != nil {
-		d.logger.Error("InsertComment: prepare, %v", err)
-		return
+		mainLogger.Error("连接数据库失败！%v", err)
+		return nil
 	}
-	_, err = stmt.Exec(comment.oid, comment.typeCode, comment.replyId,
-		comment.ctime, comment.msg, likeTime, comment.uid, comment.uname, comment.location)
+	err = sqliteDB.Ping()
 	if err != nil {
-		d.logger.Error("InsertComment: exec, %v", err)
-		return
+		mainLogger.Error("连接数据库失败！name=%s, err=%v", dbname, err)
+		return nil
 	}
-	d.logger.Debug("InsertComment 成功，oid=%d, rpid=%d, msg=%s",
-		comment.oid, comment.replyId, comment.msg)
+	mainLogger.Debug("连接 sqlite3 数据库 %s 成功", dbname)
+	return sqliteDB
 }
 
-// InsertFollower 插入粉丝数
-func (d *DB) InsertFollower(uid uint64, ctime int64, fans int) {
-	stmt, err := d.conn.Prepare(`insert into follower(uid, ctime, fans)
-values (?, ?, ?)`)
+//获取数据库当前的版本，没有 schema_version 表时为0，不会创建 schema_version 表
+func readVersion(conn *sql.DB) (int, error) {
+	var exists bool
+	err := conn.QueryRow(`select exists(select 1 from sqlite_master
+where type = 'table' and name = 'schema_version')`).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err = conn.QueryRow("select max(version) from schema_version").Scan(&version)
+	return int(version.Int64), err
+}
+
+//获取数据库当前的版本，没有 schema_version 表时为0
+func currentVersion(conn *sql.DB) (int, error) {
+	_, err := conn.Exec(`create table if not exists schema_version
+(
+    version     integer primary key, -- 版本号
+    description text,                -- 变更说明
+    applied_at  integer              -- 变更时间,时间戳形式单位秒
+);`)
+	if err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err = conn.QueryRow("select max(version) from schema_version").Scan(&version)
+	return int(version.Int64), err
+}
+
+//依次执行未执行的结构变更，所有变更在同一个事务中执行，执行前会备份数据库文件
+func migrate(conn *sql.DB, dbname string) error {
+	version, err := currentVersion(conn)
+	if err != nil {
+		return err
+	}
+	latest := SchemaVersion()
+	if version > latest {
+		return fmt.Errorf("数据库版本(%d)高于程序支持的版本(%d)，请使用新版本的程序", version, latest)
+	}
+	if version == latest {
+		mainLogger.Debug("数据库版本：%d", version)
+		return nil
+	}
+	//有数据的数据库才需要备份
+	var tables int
+	err = conn.QueryRow(`select count(*) from sqlite_master
+where type = 'table' and name not in ('schema_version', 'sqlite_sequence')`).Scan(&tables)
+	if err != nil {
+		return err
+	}
+	if tables > 0 {
+		//v5 在有重复的评论时会失败，在备份之前检查，避免每次启动失败都生成一个备份
+		if err = checkDuplicates(conn, version); err != nil {
+			return err
+		}
+		if err = backupDB(conn, dbname, fmt.Sprintf("v%d", version)); err != nil {
+			return err
+		}
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		mainLogger.Info("更新数据库结构：v%d %s", m.version, m.desc)
+		if err = m.up(tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("v%d %s：%v", m.version, m.desc, err)
+		}
+		_, err = tx.Exec("insert into schema_version(version, description, applied_at) values (?, ?, ?)",
+			m.version, m.desc, time.Now().Unix())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	mainLogger.Info("数据库版本：%d => %d", version, latest)
+	return nil
+}
+
+//备份数据库，备份文件名为 <dbname>.<tag>-<时间>.bak
+func backupDB(conn *sql.DB, dbname, tag string) error {
+	name := fmt.Sprintf("%s.%s-%s", dbname, tag, time.Now().Format("20060102150405"))
+	backup := name + ".bak"
+	//同一秒内多次备份时，在文件名后添加序号
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s-%d.bak", name, i)
+	}
+	mainLogger.Info("备份数据库：%s", backup)
+	if _, err := conn.Exec("vacuum into ?", backup); err != nil {
+		return fmt.Errorf("备份数据库失败，%v", err)
+	}
+	return nil
+}
+
+//在表中添加列，如果已经有该列则不做修改
+func addColumn(tx *sql.Tx, table, column, def string) error {
+	rows, err := tx.Query(fmt.Sprintf("pragma table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	var columns []string
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err = rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		columns = append(columns, name)
+	}
+	_ = rows.Close()
+	for _, name := range columns {
+		if name == column {
+			return nil
+		}
+	}
+	_, err = tx.Exec(fmt.Sprintf("alter table %s add column %s %s", table, column, def))
+	return err
+}
+
+//执行sql语句，*sql.DB 和 *sql.Tx 都实现了该接口
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+//获取存在重复记录的评论数量，oid 和 rpid 相同的评论视为重复
+func countDuplicates(e execer) (int, error) {
+	var count int
+	err := e.QueryRow(`select count(*)
+from (select 1 from comment group by oid, rpid having count(*) > 1)`).Scan(&count)
+	return count, err
+}
+
+//存在重复的评论，无法添加唯一索引
+func duplicatesError(duplicates int) error {
+	return fmt.Errorf("有 %d 条评论存在重复记录，请先运行 bobo-bot dedup 删除重复的评论", duplicates)
+}
+
+//从 version 更新到 v5 之前检查是否有重复的评论，有时返回错误
+func checkDuplicates(conn *sql.DB, version int) error {
+	if version >= 5 {
+		return nil
+	}
+	var tables int
+	err := conn.QueryRow("select count(*) from sqlite_master where type = 'table' and name = 'comment'").Scan(&tables)
+	if err != nil || tables == 0 {
+		return err
+	}
+	duplicates, err := countDuplicates(conn)
+	if err == nil && duplicates > 0 {
+		err = duplicatesError(duplicates)
+	}
+	return err
+}
+
+//删除重复的评论，每组重复的评论只保留最早插入的一条，并使用最早的点赞时间，返回删除的记录数
+func dedupComments(tx *sql.Tx) (int64, error) {
+	_, err := tx.Exec(`update comment
+set like_time = (select min(c.like_time) from comment c where c.oid = comment.oid and c.rpid = comment.rpid)
+where id in (select min(id) from comment group by oid, rpid having count(*) > 1)`)
 	if err != nil {
-		d.logger.Error("InsertFollower: prepare, %v", err)
-		return
+		return 0, err
 	}
-	_, err = stmt.Exec(uid, ctime, fans)
+	result, err := tx.Exec(`delete from comment
+where id not in (select min(id) from comment group by oid, rpid)`)
 	if err != nil {
-		d.logger.Error("InsertFollower: exec, %v", err)
-		return
+		return 0, err
 	}
-	d.logger.Debug("InsertFollower 成功， uid=%d, ctime=%d, fans=%d", uid, ctime, fans)
+	return result.RowsAffected()
 }
 
+// CommentRecord 保存到数据库中的评论
+type CommentRecord struct {
+	Comment
+	likeTime int64  //点赞时间，即获取到该评论的时间
+	watched  bool   //是否为关注列表中的用户发送
+	spam     string //被标记为刷屏的原因，多个原因使用逗号分隔，正常的评论为空
+}
+
+// InsertComment 向数据库中插入评论数据，评论已经存在时更新点赞数、回复数和用户名，保留第一次记录的点赞时间
+func (d *DB) InsertComment(record CommentRecord) {
+	comment := record.Comment
+	d.writer.add("comment", comment.oid, comment.typeCode, comment.replyId,
+		comment.ctime, comment.msg, record.likeTime, comment.uid, comment.uname, comment.location,
+		record.watched, comment.like, comment.rcount, record.spam)
+	d.logger.Debug("InsertComment，oid=%d, rpid=%d, msg=%s",
+		comment.oid, comment.replyId, comment.msg)
+}
+
+// InsertFollower 插入粉丝数
+func (d *DB) InsertFollower(uid uint64, ctime int64, fans int) {
+	d.writer.add("follower", uid, ctime, fans)
+	d.logger.Debug("InsertFollower， uid=%d, ctime=%d, fans=%d", uid, ctime, fans)
+}
+
+// InsertAccountStat 插入账号的统计数据，stats 的键为数据项名称
+func (d *DB) InsertAccountStat(uid uint64, ctime int64, stats map[string]int) {
+	for metric, value := range stats {
+		d.writer.add("account_stat", uid, ctime, metric, value)
+	}
+	d.logger.Debug("InsertAccountStat，uid=%d, ctime=%d, stats=%v", uid, ctime, stats)
+}
+
+// InsertVideoStat 插入视频的统计数据
+func (d *DB) InsertVideoStat(stat VideoStat, ctime int64) {
+	d.writer.add("video_stat", stat.aid, stat.bvID, ctime, stat.view, stat.danmaku, stat.reply,
+		stat.favorite, stat.coin, stat.share, stat.like)
+	d.logger.Debug("InsertVideoStat，bv=%s, ctime=%d", stat.bvID, ctime)
+}
+
+// Flush 等待已插入的数据全部写入数据库，返回上次等待之后提交事务失败的错误
+func (d *DB) Flush() error {
+	return d.writer.flush()
+}
+
+// Close 等待剩余的数据写入后断开连接
 func (d *DB) Close() {
+	//只读连接没有写入协程
+	if d.writer != nil {
+		d.writer.close()
+	}
 	d.logger.Debug("断开连接")
 	_ = d.conn.Close()
 }
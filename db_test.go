@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+	"github.com/Hami-Lemon/bobo-bot/set"
+)
+
+func TestMigrate(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "test.db")
+	d := NewDB(dbname, DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	version, err := currentVersion(d.conn)
+	if err != nil || version != SchemaVersion() {
+		t.Fatalf("want version %d, got %d, err=%v", SchemaVersion(), version, err)
+	}
+	d.InsertVideoStat(VideoStat{aid: 1, bvID: "BV17x411w7KC", view: 10, like: 2}, 1)
+	d.Flush()
+	var like int
+	if err = d.conn.QueryRow("select like from video_stat").Scan(&like); err != nil || like != 2 {
+		t.Errorf("want like 2, got %d, err=%v", like, err)
+	}
+	d.Close()
+
+	//再次打开时不需要更新
+	d = NewDB(dbname, DBOption{})
+	if d == nil {
+		t.Fatal("reopen fail")
+	}
+	//模拟更新版本的程序创建的数据库
+	_, err = d.conn.Exec("insert into schema_version(version, description, applied_at) values (?, ?, ?)",
+		SchemaVersion()+1, "future", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	if d = NewDB(dbname, DBOption{}); d != nil {
+		t.Error("want refuse to open newer schema")
+		d.Close()
+	}
+}
+
+func TestMigrate_legacy(t *testing.T) {
+	//旧版本程序创建的数据库，没有 schema_version 表和 watched 列
+	dbname := filepath.Join(t.TempDir(), "legacy.db")
+	conn, err := sql.Open("sqlite3", dbname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`create table comment
+(
+    id integer primary key autoincrement ,
+    oid integer, type_code integer, rpid integer, ctime integer, msg text,
+    like_time integer, uid integer, uname text, location text
+);
+insert into comment(oid, rpid, msg) values (1, 1, 'test');`)
+	_ = conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDB(dbname, DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	var watched int
+	if err = d.conn.QueryRow("select watched from comment where rpid = 1").Scan(&watched); err != nil {
+		t.Errorf("select watched: %v", err)
+	}
+	matches, _ := filepath.Glob(dbname + ".v0-*.bak")
+	if len(matches) != 1 {
+		t.Errorf("want one backup file, got %v", matches)
+	}
+}
+
+func TestNewReadOnlyDB(t *testing.T) {
+	dir := t.TempDir()
+	if d := NewReadOnlyDB(filepath.Join(dir, "missing.db")); d != nil {
+		t.Error("want nil for missing database")
+	}
+	//旧版本的数据库不更新结构，也不备份
+	legacy := filepath.Join(dir, "legacy.db")
+	conn, err := sql.Open("sqlite3", legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec("create table comment(id integer primary key, oid integer, rpid integer)")
+	_ = conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := NewReadOnlyDB(legacy); d != nil {
+		t.Error("want nil for outdated database")
+	}
+	if matches, _ := filepath.Glob(legacy + ".*.bak"); len(matches) != 0 {
+		t.Errorf("want no backup, got %v", matches)
+	}
+
+	dbname := filepath.Join(dir, "test.db")
+	NewDB(dbname, DBOption{}).Close()
+	d := NewReadOnlyDB(dbname)
+	if d == nil {
+		t.Fatal("NewReadOnlyDB fail")
+	}
+	defer d.Close()
+	if _, err = d.conn.Exec("insert into follower(uid, ctime, fans) values (1, 1, 1)"); err == nil {
+		t.Error("want error when writing")
+	}
+}
+
+func TestMigrate_duplicates(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "legacy.db")
+	conn, err := sql.Open("sqlite3", dbname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`create table comment
+(
+    id integer primary key autoincrement ,
+    oid integer, type_code integer, rpid integer, ctime integer, msg text,
+    like_time integer, uid integer, uname text, location text
+);
+insert into comment(oid, rpid, msg) values (1, 1, 'test'), (1, 1, 'test');`)
+	_ = conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//有重复的评论时更新失败，并且不会备份数据库
+	for i := 0; i < 2; i++ {
+		if d := NewDB(dbname, DBOption{}); d != nil {
+			d.Close()
+			t.Fatal("want migrate fail with duplicates")
+		}
+	}
+	if matches, _ := filepath.Glob(dbname + ".v0-*.bak"); len(matches) != 0 {
+		t.Errorf("want no backup file, got %v", matches)
+	}
+}
+
+func TestDB_InsertComment(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	comment := Comment{Account: Account{uid: 1, uname: "a"}, oid: 10, replyId: 100, msg: "test"}
+	d.InsertComment(CommentRecord{Comment: comment, likeTime: 1000})
+	comment.uname = "b"
+	comment.like = 5
+	d.InsertComment(CommentRecord{Comment: comment, likeTime: 2000, watched: true})
+	d.Flush()
+
+	var (
+		count, likeTime, likeCount, watched int
+		uname                               string
+	)
+	err := d.conn.QueryRow(`select count(*), like_time, like_count, uname, watched
+from comment where oid = 10 and rpid = 100`).Scan(&count, &likeTime, &likeCount, &uname, &watched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || likeTime != 1000 || likeCount != 5 || uname != "b" || watched != 1 {
+		t.Errorf("got count=%d, like_time=%d, like_count=%d, uname=%s, watched=%d",
+			count, likeTime, likeCount, uname, watched)
+	}
+}
+
+func TestDedupComments(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "dup.db")
+	conn, err := sql.Open("sqlite3", dbname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`create table comment
+(
+    id integer primary key autoincrement ,
+    oid integer, type_code integer, rpid integer, ctime integer, msg text,
+    like_time integer, uid integer, uname text, location text
+);
+insert into comment(oid, rpid, like_time) values (1, 1, 300), (1, 1, 100), (1, 2, 200), (1, 1, 400);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//存在重复的评论时拒绝更新
+	if d := NewDB(dbname, DBOption{}); d != nil {
+		d.Close()
+		t.Fatal("want migrate fail with duplicates")
+	}
+	tx, _ := conn.Begin()
+	deleted, err := dedupComments(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tx.Commit()
+	_ = conn.Close()
+	if deleted != 2 {
+		t.Errorf("want deleted 2, got %d", deleted)
+	}
+	d := NewDB(dbname, DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail after dedup")
+	}
+	defer d.Close()
+	var id, likeTime int
+	err = d.conn.QueryRow("select id, like_time from comment where rpid = 1").Scan(&id, &likeTime)
+	if err != nil || id != 1 || likeTime != 100 {
+		t.Errorf("want id=1, like_time=100, got id=%d, like_time=%d, err=%v", id, likeTime, err)
+	}
+}
+
+func TestDB_Close(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "test.db")
+	d := NewDB(dbname, DBOption{batchSize: 16, flushInterval: time.Hour})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	for i := 0; i < 100; i++ {
+		d.InsertFollower(1, int64(i), i)
+	}
+	//关闭时写入剩余的数据
+	d.Close()
+	d = NewDB(dbname, DBOption{})
+	if d == nil {
+		t.Fatal("reopen fail")
+	}
+	defer d.Close()
+	if records, err := d.FollowerHistory(1, 0, 100); err != nil || len(records) != 100 {
+		t.Errorf("want 100 records, got %d, err=%v", len(records), err)
+	}
+}
+
+func TestDB_Flush(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "test.db")
+	d := NewDB(dbname, DBOption{batchSize: 16, flushInterval: time.Hour})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	d.InsertFollower(1, 1, 100)
+	if err := d.Flush(); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	//开始事务失败时，等待写入的调用者收到错误，之后的等待不再重复报告
+	_ = d.conn.Close()
+	d.InsertFollower(1, 2, 110)
+	if err := d.Flush(); err == nil {
+		t.Error("want error")
+	}
+	if err := d.Flush(); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
+func TestDB_Query(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	comments := []Comment{
+		{Account: Account{uid: 1, uname: "a"}, oid: 10, replyId: 1, ctime: 60, msg: "hello"},
+		{Account: Account{uid: 1, uname: "a2"}, oid: 10, replyId: 2, ctime: 70, msg: "world"},
+		{Account: Account{uid: 2, uname: "b"}, oid: 10, replyId: 3, ctime: 130, msg: "hello world"},
+		{Account: Account{uid: 1, uname: "a3"}, oid: 20, replyId: 4, ctime: 200, msg: "other"},
+	}
+	for _, c := range comments {
+		d.InsertComment(CommentRecord{Comment: c, likeTime: int64(c.ctime)})
+	}
+	d.Flush()
+
+	if got, err := d.CommentsByTime(10, 61, 200); err != nil || len(got) != 2 || got[0].replyId != 2 {
+		t.Errorf("CommentsByTime: got %v, err=%v", got, err)
+	}
+	if got, err := d.CommentsByUid(0, 1); err != nil || len(got) != 3 {
+		t.Errorf("CommentsByUid: want 3, got %d, err=%v", len(got), err)
+	}
+	if got, err := d.CommentsByKeyword(10, "hello"); err != nil || len(got) != 2 {
+		t.Errorf("CommentsByKeyword: want 2, got %d, err=%v", len(got), err)
+	}
+	if got, err := d.CommentsByBoard(20); err != nil || len(got) != 1 || got[0].msg != "other" {
+		t.Errorf("CommentsByBoard: got %v, err=%v", got, err)
+	}
+	top, err := d.TopCommenters(10, 0, 0, 10)
+	want := []CommenterCount{{uid: 1, uname: "a2", count: 2}, {uid: 2, uname: "b", count: 1}}
+	if err != nil || !reflect.DeepEqual(top, want) {
+		t.Errorf("TopCommenters: want %v, got %v, err=%v", want, top, err)
+	}
+	minutes, err := d.CommentsPerMinute(10, 0, 0)
+	wantMinutes := []MinuteCount{{minute: 60, count: 2}, {minute: 120, count: 1}}
+	if err != nil || !reflect.DeepEqual(minutes, wantMinutes) {
+		t.Errorf("CommentsPerMinute: want %v, got %v, err=%v", wantMinutes, minutes, err)
+	}
+
+	//查询失败时返回错误
+	_ = d.conn.Close()
+	if _, err = d.Comments(CommentQuery{oid: 10}); err == nil {
+		t.Error("Comments: want error")
+	}
+	if _, err = d.CountCommenters(10, 0, 0); err == nil {
+		t.Error("CountCommenters: want error")
+	}
+}
+
+func TestDB_SearchComments(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	msgs := []string{"今天三三直播好耶", "三三好可爱", "明天也要直播", "test 延迟"}
+	for i, msg := range msgs {
+		c := Comment{Account: Account{uid: uint64(i%2 + 1)}, oid: 10, replyId: uint64(i + 1),
+			ctime: uint64(100 * (i + 1)), msg: msg}
+		d.InsertComment(CommentRecord{Comment: c})
+	}
+	d.Flush()
+
+	//少于三个字符的词使用子串匹配
+	records, err := d.SearchComments(SearchQuery{match: "三三"})
+	if err != nil || len(records) != 2 || records[0].replyId != 2 {
+		t.Errorf("short term: got %v, err=%v", records, err)
+	}
+	q := SearchQuery{match: "三三 直播"}
+	q.uid = 1
+	if records, err = d.SearchComments(q); err != nil || len(records) != 1 {
+		t.Errorf("short terms with uid: got %v, err=%v", records, err)
+	}
+	//子串匹配同样支持查询语法
+	if records, err = d.SearchComments(SearchQuery{match: "三三 OR 明天"}); err != nil || len(records) != 3 {
+		t.Errorf("short terms with OR: got %v, err=%v", records, err)
+	}
+	if records, err = d.SearchComments(SearchQuery{match: "三三 NOT 直播"}); err != nil ||
+		len(records) != 1 || records[0].replyId != 2 {
+		t.Errorf("short terms with NOT: got %v, err=%v", records, err)
+	}
+	if !d.fts {
+		if _, err = d.SearchComments(SearchQuery{match: "三三直播"}); err != ErrNoFTS {
+			t.Errorf("want ErrNoFTS, got %v", err)
+		}
+		t.Skip("sqlite 未启用 FTS5，使用 -tags sqlite_fts5 测试全文搜索")
+	}
+	if records, err = d.SearchComments(SearchQuery{match: "三三直播 OR 也要直播"}); err != nil || len(records) != 2 {
+		t.Errorf("fts: got %v, err=%v", records, err)
+	}
+	q = SearchQuery{match: "三三直播 OR 也要直播"}
+	q.to = 200
+	if records, err = d.SearchComments(q); err != nil || len(records) != 1 || records[0].replyId != 1 {
+		t.Errorf("fts with time: got %v, err=%v", records, err)
+	}
+}
+
+func TestDB_Export(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	comments := []Comment{
+		{Account: Account{uid: 1, uname: "三三"}, oid: 10, replyId: 1, ctime: 100, msg: "你好，\"世界\""},
+		{Account: Account{uid: 2, uname: "b"}, oid: 10, replyId: 2, ctime: 200, msg: "hello"},
+		{Account: Account{uid: 3, uname: "c"}, oid: 20, replyId: 3, ctime: 300, msg: "other"},
+	}
+	for _, c := range comments {
+		d.InsertComment(CommentRecord{Comment: c, likeTime: int64(c.ctime)})
+	}
+	d.Flush()
+
+	var buf bytes.Buffer
+	w, _ := newRowWriter(&buf, "csv", true)
+	count, err := d.Export(exportQuery{table: "comment", board: 10, to: 150}, w)
+	if err != nil || count != 1 {
+		t.Fatalf("csv: count=%d, err=%v", count, err)
+	}
+	want := utf8BOM + "id,oid,type_code,rpid,ctime,msg,like_time,uid,uname,location,watched,like_count,reply_count,source,root,spam\n" +
+		"1,10,0,1,100,\"你好，\"\"世界\"\"\",100,1,三三,,0,0,0,live,0,\n"
+	if buf.String() != want {
+		t.Errorf("csv: want %q, got %q", want, buf.String())
+	}
+
+	buf.Reset()
+	w, _ = newRowWriter(&buf, "jsonl", true)
+	if count, err = d.Export(exportQuery{table: "comment", uid: 2}, w); err != nil || count != 1 {
+		t.Fatalf("jsonl: count=%d, err=%v", count, err)
+	}
+	want = `{"id":2,"oid":10,"type_code":0,"rpid":2,"ctime":200,"msg":"hello","like_time":200,` +
+		`"uid":2,"uname":"b","location":"","watched":0,"like_count":0,"reply_count":0,"source":"live","root":0,"spam":""}` + "\n"
+	if buf.String() != want {
+		t.Errorf("jsonl: want %q, got %q", want, buf.String())
+	}
+
+	if _, err = d.Export(exportQuery{table: "follower", board: 10}, w); err == nil {
+		t.Error("want error for -board on follower")
+	}
+	if _, err = d.Export(exportQuery{table: "schema_version"}, w); err == nil {
+		t.Error("want error for unknown table")
+	}
+}
+
+func TestDB_Summary(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	var first, second report.Summary
+	first.Start, first.End = 0, 3600
+	first.Board.Oid = 10
+	first.Board.Hot = []int{1, 5, 2}
+	first.Board.Awl = []int{3, 9}
+	first.Board.People = map[uint64]int{1: 2, 2: 6}
+	first.Board.Count = 8
+	first.Account.StartFollowers, first.Account.EndFollowers = 100, 120
+	second = first
+	second.Start, second.End = 3600, 7200
+	second.Board.Oid = 20
+	d.InsertSummary(first, "first.json", false)
+	d.InsertSummary(second, "second.json", true)
+	d.Flush()
+
+	records, err := d.Summaries(10, 0, 0, 0)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Summaries: got %v, err=%v", records, err)
+	}
+	r := records[0]
+	if r.peakHot != 5 || r.peakTime != 60 || r.maxDelay != 9 || r.peopleCount != 2 || r.interrupted {
+		t.Errorf("Summaries: got %+v", r)
+	}
+	if records, _ = d.Summaries(0, 0, 0, 0); len(records) != 2 || records[0].file != "second.json" {
+		t.Errorf("Summaries: want second first, got %v", records)
+	}
+
+	id, ok, err := parseRecoverID("db:latest")
+	if err != nil || !ok || id != 0 {
+		t.Fatalf("parseRecoverID: id=%d, ok=%v, err=%v", id, ok, err)
+	}
+	latest, err := d.LoadSummary(id)
+	if err != nil || !reflect.DeepEqual(latest, second) {
+		t.Errorf("LoadSummary: want %+v, got %+v, err=%v", second, latest, err)
+	}
+	if _, err = d.LoadSummary(100); err == nil {
+		t.Error("LoadSummary: want error for missing id")
+	}
+	//恢复时只读取同一个评论区和统计时段的数据总结
+	if latest, err = d.LatestSummary(10, ""); err != nil || !reflect.DeepEqual(latest, first) {
+		t.Errorf("LatestSummary: want %+v, got %+v, err=%v", first, latest, err)
+	}
+	if _, err = d.LatestSummary(10, "night"); err == nil {
+		t.Error("LatestSummary: want error for other window")
+	}
+	if _, ok, _ = parseRecoverID("report/1.json"); ok {
+		t.Error("parseRecoverID: want file name")
+	}
+}
+
+func TestDB_Rebuild(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	const from, to = 6000, 6000 + 3600
+	//和运行时一样计数，作为期望的结果
+	live := &Counter{
+		peopleCount: make(map[uint64]int),
+		hotCount:    make([]int, 0, CountCap),
+		awlCount:    make([]int, 0, CountCap),
+		stats:       report.NewCommentStats(),
+		minuteUsers: make(map[int]*set.HashSet[uint64]),
+		hourUsers:   make(map[int]*set.HashSet[uint64]),
+		statCount:   make(map[string][]int),
+		startTime:   time.Unix(from, 0),
+	}
+	var records []CommentRecord
+	for i := 0; i < 50; i++ {
+		c := Comment{Account: Account{uid: uint64(i % 7)}, oid: 10, replyId: uint64(i + 1),
+			ctime: uint64(from - 30 + i*70), msg: strings.Repeat("[doge]", i%3), location: "上海"}
+		likeTime := time.Unix(int64(c.ctime)+int64(i%5)+2, 0)
+		d.InsertComment(CommentRecord{Comment: c, likeTime: likeTime.Unix()})
+		if likeTime.Unix() >= from && likeTime.Unix() <= to {
+			records = append(records, CommentRecord{Comment: c, likeTime: likeTime.Unix()})
+		}
+	}
+	d.Flush()
+	for _, r := range records {
+		first, err := d.FirstComment(10, r.uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		live.Count(r.Comment, time.Unix(r.likeTime, 0), commenterHistory{checked: true, first: first})
+	}
+	//其他评论区和时间段外的评论
+	d.InsertComment(CommentRecord{Comment: Comment{oid: 20, replyId: 1, ctime: from + 10}, likeTime: from + 10})
+	for i, fans := range []int{100, 110, 120, 125} {
+		d.InsertFollower(1, int64(from-600+i*1500), fans)
+		d.InsertAccountStat(1, int64(from-600+i*1500), map[string]int{StatFollower: fans})
+	}
+	d.InsertVideoStat(VideoStat{aid: 10, bvID: "BV1", view: 100}, from-600)
+	d.InsertVideoStat(VideoStat{aid: 10, bvID: "BV1", view: 220}, from+600)
+	d.Flush()
+
+	summary, err := d.Rebuild(10, 1, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Start != from || summary.End != to {
+		t.Errorf("want [%d, %d], got [%d, %d]", from, to, summary.Start, summary.End)
+	}
+	if summary.Board.Count != live.todayComment || !reflect.DeepEqual(summary.Board.Hot, live.hotCount) ||
+		!reflect.DeepEqual(summary.Board.Awl, live.awlCount) || !reflect.DeepEqual(summary.Board.People, live.peopleCount) ||
+		!reflect.DeepEqual(summary.Board.Latency, live.latency) || !reflect.DeepEqual(summary.Board.TotalLatency, live.total) {
+		t.Errorf("board: want count=%d, hot=%v, awl=%v, people=%v, got count=%d, hot=%v, awl=%v, people=%v",
+			live.todayComment, live.hotCount, live.awlCount, live.peopleCount,
+			summary.Board.Count, summary.Board.Hot, summary.Board.Awl, summary.Board.People)
+	}
+	//第一条评论在统计时段之前获取到，该用户为老用户
+	if stats := summary.Board.Stats; !reflect.DeepEqual(stats, live.stats) || stats.NewUsers != 6 ||
+		stats.ReturningUsers != 1 || len(stats.HourUsers) != 1 || stats.HourUsers[0] != 7 ||
+		stats.Locations["上海"] != live.todayComment {
+		t.Errorf("stats: want %+v, got %+v", live.stats, stats)
+	}
+	account := summary.Account
+	if account.StartFollowers != 100 || account.EndFollowers != 120 || !reflect.DeepEqual(account.FansCount, []int{100, 110, 120}) {
+		t.Errorf("followers: got start=%d, end=%d, fansCount=%v",
+			account.StartFollowers, account.EndFollowers, account.FansCount)
+	}
+	//开始时的数据为统计时段之前最近的一条记录
+	if !reflect.DeepEqual(account.Stats[StatFollower], []int{100, 110, 120}) ||
+		!reflect.DeepEqual(account.StatTimes, []int64{from - 600, from + 900, from + 2400}) {
+		t.Errorf("stats: got %v, times=%v", account.Stats, account.StatTimes)
+	}
+	video := summary.Video
+	if video == nil || video.Start["view"] != 100 || video.End["view"] != 220 ||
+		!reflect.DeepEqual(video.Growth["view"], []float64{6}) || video.Interval != 20 {
+		t.Errorf("video: got %+v", video)
+	}
+}
+
+func TestDB_Backfill(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	cursor, err := d.BackfillCursor(10)
+	if err != nil || cursor.next != 0 || cursor.done {
+		t.Fatalf("want empty cursor, got %+v, err=%v", cursor, err)
+	}
+	old := Comment{oid: 10, replyId: 1, ctime: 100, msg: "old"}
+	recent := Comment{oid: 10, replyId: 2, ctime: 200, msg: "recent"}
+	d.InsertBackfill(old, 0)
+	d.InsertBackfill(recent, 0)
+	d.InsertBackfill(Comment{oid: 10, replyId: 3, ctime: 150, msg: "reply"}, 1)
+	d.SaveBackfillCursor(BackfillCursor{oid: 10, typeCode: 11, next: 42, count: 3})
+	//运行时再次获取到的评论作为运行时获取的评论
+	d.InsertComment(CommentRecord{Comment: recent, likeTime: 205})
+	d.Flush()
+
+	if cursor, err = d.BackfillCursor(10); err != nil || cursor.next != 42 || cursor.count != 3 || cursor.typeCode != 11 {
+		t.Errorf("want saved cursor, got %+v, err=%v", cursor, err)
+	}
+	rows, err := d.conn.Query("select rpid, like_time, source, root from comment order by rpid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type row struct {
+		rpid, likeTime int64
+		source         string
+		root           int64
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		_ = rows.Scan(&r.rpid, &r.likeTime, &r.source, &r.root)
+		got = append(got, r)
+	}
+	want := []row{{1, 0, "backfill", 0}, {2, 205, "live", 0}, {3, 0, "backfill", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	//补全的评论不计入运行时的统计数据
+	summary, err := d.Rebuild(10, 0, 0, 1000)
+	if err != nil || summary.Board.Count != 1 {
+		t.Errorf("rebuild: want count 1, got %d, err=%v", summary.Board.Count, err)
+	}
+}
+
+func TestDB_addTrending(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	newSummary := func(start int64, window string, words map[string]int) report.Summary {
+		s := report.Summary{Start: start, End: start + 100, Window: window}
+		s.Board.Oid = 10
+		s.Board.Stats = report.NewCommentStats()
+		s.Board.Stats.Length.Count = 10
+		s.Board.Stats.Words = words
+		return s
+	}
+	d.InsertSummary(newSummary(0, "", map[string]int{"好耶": 8}), "", false)
+	d.InsertSummary(newSummary(100, "", map[string]int{"晚安": 1}), "", false)
+	//其他统计时段的数据总结
+	d.InsertSummary(newSummary(150, "hourly", map[string]int{"晚安": 9}), "", false)
+	d.Flush()
+
+	s := newSummary(200, "", map[string]int{"晚安": 9, "好耶": 8})
+	d.addTrending(&s)
+	//和最近的一条比较，更早的数据总结中的词语不影响结果
+	want := []report.Trend{{Name: "好耶", Count: 8, Growth: 9}, {Name: "晚安", Count: 9, Prev: 1, Growth: 5}}
+	if !reflect.DeepEqual(s.Board.Stats.Trending, want) {
+		t.Errorf("want %v, got %v", want, s.Board.Stats.Trending)
+	}
+	//没有上一条数据总结时不计算
+	s = newSummary(200, "daily", map[string]int{"晚安": 9})
+	if d.addTrending(&s); s.Board.Stats.Trending != nil {
+		t.Errorf("got %v", s.Board.Stats.Trending)
+	}
+}
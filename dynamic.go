@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/request"
+)
+
+// Image 上传到b站图床的图片，用于发布带图片的动态
+type Image struct {
+	url    string  //图片地址
+	width  int     //宽度
+	height int     //高度
+	size   float64 //文件大小，单位：KB
+}
+
+// UploadImage 上传图片，name 为图片的文件名
+func (b *BiliBili) UploadImage(name string) (Image, error) {
+	var img Image
+	info, err := os.Stat(name)
+	if err != nil {
+		return img, err
+	}
+	urlStr := "https://api.bilibili.com/x/dynamic/feed/draw/upload_bfs"
+	body := request.NewMultipartEntity()
+	body.AddFilePath("file_up", name)
+	body.AddField("biz", "new_dyn")
+	body.AddField("category", "daily")
+	body.AddField("csrf", b.user.csrf)
+	resp, err := checkResp(b.client.Post(urlStr, nil, body))
+	if err != nil {
+		return img, err
+	}
+	if resp == nil {
+		return img, fmt.Errorf("上传图片失败：%s，没有返回数据", name)
+	}
+	img.url = resp.Get("image_url").String()
+	img.width = int(resp.Get("image_width").Int())
+	img.height = int(resp.Get("image_height").Int())
+	img.size = float64(info.Size()) / 1024
+	b.logger.Debug("上传图片成功：%s, url: %s", name, img.url)
+	return img, nil
+}
+
+// PostDynamic 发布动态，images 为动态中的图片，可以为空，返回动态的id
+func (b *BiliBili) PostDynamic(text string, images []Image) (string, error) {
+	now := time.Now()
+	req := map[string]interface{}{
+		"content": map[string]interface{}{
+			"contents": []map[string]interface{}{
+				{"raw_text": text, "type": 1, "biz_id": ""},
+			},
+		},
+		"meta": map[string]interface{}{
+			"app_meta": map[string]interface{}{
+				"from":     "create.dynamic.web",
+				"mobi_app": "web",
+			},
+		},
+		"scene":       1, //有图片为2，无图为1
+		"attach_card": nil,
+		"upload_id": fmt.Sprintf("%s_%d_%d", strconv.FormatUint(b.user.uid, 10), now.Unix(),
+			now.Nanosecond()/100000),
+	}
+	if len(images) > 0 {
+		pics := make([]map[string]interface{}, 0, len(images))
+		for _, img := range images {
+			pics = append(pics, map[string]interface{}{
+				"img_src":    img.url,
+				"img_width":  img.width,
+				"img_height": img.height,
+				"img_size":   img.size,
+			})
+		}
+		req["pics"] = pics
+		req["scene"] = 2
+	}
+	data, err := json.Marshal(map[string]interface{}{"dyn_req": req})
+	if err != nil {
+		return "", err
+	}
+	urlStr := "https://api.bilibili.com/x/dynamic/feed/create/dyn"
+	body := request.NewByteEntity(data, "application/json; charset=utf-8")
+	resp, err := checkResp(b.client.Post(urlStr, map[string]interface{}{"csrf": b.user.csrf}, body))
+	if err != nil {
+		return "", err
+	}
+	if resp == nil {
+		return "", fmt.Errorf("发布动态失败，没有返回数据")
+	}
+	dynID := resp.Get("dyn_id_str").String()
+	b.logger.Debug("发布动态成功：%s", dynID)
+	return dynID, nil
+}
+
+//发布数据总结的动态，images 为图表的文件名，按顺序上传
+func (b *Bot) postSummary(text string, images []string) {
+	uploaded := make([]Image, 0, len(images))
+	for _, name := range images {
+		img, err := b.bili.UploadImage(name)
+		if err != nil {
+			b.logger.Error("上传图片失败：%s，%v", name, err)
+			pushAndLog(b.logger, "上传图片失败：%s，%v", name, err)
+			return
+		}
+		uploaded = append(uploaded, img)
+	}
+	dynID, err := b.bili.PostDynamic(text, uploaded)
+	if err != nil {
+		b.logger.Error("发布动态失败，%v", err)
+		pushAndLog(b.logger, "发布动态失败，%v", err)
+		return
+	}
+	b.logger.Info("发布动态成功！link: https://t.bilibili.com/%s", dynID)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/logger"
+	"github.com/Hami-Lemon/bobo-bot/request"
+	"github.com/tidwall/gjson"
+)
+
+//将所有请求转发到测试服务器
+type serverTransport struct {
+	server *url.URL
+}
+
+func (s serverTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme, r.URL.Host = s.server.Scheme, s.server.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+//创建请求发送到 handler 的 BiliBili
+func newTestBiliBili(t *testing.T, handler http.HandlerFunc) *BiliBili {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	client := request.New(map[string]string{}, map[string]string{}, 3)
+	client.SetTransport(serverTransport{server: u})
+	return &BiliBili{
+		user:   BotAccount{Account: Account{uid: 1}, csrf: "csrf"},
+		client: client,
+		logger: logger.New("BiliBili", logLevel, logDst),
+	}
+}
+
+//创建测试用的图片文件
+func writeImage(t *testing.T, name, data string) string {
+	name = filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestBiliBili_UploadImage(t *testing.T) {
+	fields := make(map[string]string)
+	var fileName string
+	b := newTestBiliBili(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/x/dynamic/feed/draw/upload_bfs" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse multipart form, %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for name, values := range r.MultipartForm.Value {
+			fields[name] = values[0]
+		}
+		if files := r.MultipartForm.File["file_up"]; len(files) == 1 {
+			fileName = files[0].Filename
+			f, _ := files[0].Open()
+			data, _ := io.ReadAll(f)
+			fields["file_up"] = string(data)
+		}
+		if fields["file_up"] == "bad" {
+			_, _ = w.Write([]byte(`{"code":-101,"message":"账号未登录"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"image_url":"https://i0.hdslb.com/a.png","image_width":800,"image_height":600}}`))
+	})
+
+	name := writeImage(t, "chart.png", strings.Repeat("x", 2048))
+	img, err := b.UploadImage(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Image{url: "https://i0.hdslb.com/a.png", width: 800, height: 600, size: 2}
+	if img != want {
+		t.Errorf("want %+v, got %+v", want, img)
+	}
+	wantFields := map[string]string{"biz": "new_dyn", "category": "daily", "csrf": "csrf",
+		"file_up": strings.Repeat("x", 2048)}
+	if !reflect.DeepEqual(fields, wantFields) || fileName != "chart.png" {
+		t.Errorf("want %v, got %v, file=%s", wantFields, fields, fileName)
+	}
+
+	//接口返回错误或文件不存在时返回错误
+	if _, err = b.UploadImage(writeImage(t, "bad.png", "bad")); err == nil || !strings.Contains(err.Error(), "-101") {
+		t.Errorf("want code error, got %v", err)
+	}
+	if _, err = b.UploadImage(filepath.Join(t.TempDir(), "missing.png")); err == nil {
+		t.Error("want error for missing file")
+	}
+}
+
+func TestBiliBili_PostDynamic(t *testing.T) {
+	var (
+		req  gjson.Result
+		code = 0
+	)
+	b := newTestBiliBili(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/x/dynamic/feed/create/dyn" ||
+			r.URL.Query().Get("csrf") != "csrf" || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			t.Errorf("got %s %s, content type %s", r.Method, r.URL, r.Header.Get("Content-Type"))
+		}
+		data, _ := io.ReadAll(r.Body)
+		req = gjson.GetBytes(data, "dyn_req")
+		data, _ = json.Marshal(map[string]any{"code": code, "message": "error", "data": map[string]any{"dyn_id_str": "123"}})
+		_, _ = w.Write(data)
+	})
+
+	images := []Image{
+		{url: "https://i0.hdslb.com/a.png", width: 800, height: 600, size: 2},
+		{url: "https://i0.hdslb.com/b.png", width: 400, height: 300, size: 1.5},
+	}
+	tests := []struct {
+		name   string
+		images []Image
+		scene  int64
+	}{
+		{"text", nil, 1},
+		{"images", images, 2},
+	}
+	for _, tt := range tests {
+		dynID, err := b.PostDynamic("晚安", tt.images)
+		if err != nil || dynID != "123" {
+			t.Errorf("%s: want 123, got %s, err=%v", tt.name, dynID, err)
+			continue
+		}
+		if got := req.Get("scene").Int(); got != tt.scene {
+			t.Errorf("%s: want scene %d, got %d", tt.name, tt.scene, got)
+		}
+		if got := req.Get("content.contents.0.raw_text").String(); got != "晚安" {
+			t.Errorf("%s: want raw_text 晚安, got %s", tt.name, got)
+		}
+		if !strings.HasPrefix(req.Get("upload_id").String(), "1_") {
+			t.Errorf("%s: got upload_id %s", tt.name, req.Get("upload_id"))
+		}
+		pics := req.Get("pics").Array()
+		if len(pics) != len(tt.images) {
+			t.Errorf("%s: want %d pics, got %d", tt.name, len(tt.images), len(pics))
+			continue
+		}
+		for i, pic := range pics {
+			img := tt.images[i]
+			if pic.Get("img_src").String() != img.url || int(pic.Get("img_width").Int()) != img.width ||
+				int(pic.Get("img_height").Int()) != img.height || pic.Get("img_size").Float() != img.size {
+				t.Errorf("%s: want %+v, got %s", tt.name, img, pic.Raw)
+			}
+		}
+	}
+
+	//接口返回错误时返回错误
+	code = -101
+	if _, err := b.PostDynamic("晚安", nil); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestBot_postSummary(t *testing.T) {
+	var (
+		uploads int
+		pics    []gjson.Result
+	)
+	bili := newTestBiliBili(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/x/dynamic/feed/draw/upload_bfs":
+			uploads++
+			_ = r.ParseMultipartForm(1 << 20)
+			if f, _, err := r.FormFile("file_up"); err == nil {
+				data, _ := io.ReadAll(f)
+				if string(data) == "bad" {
+					_, _ = w.Write([]byte(`{"code":-1,"message":"上传失败"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"code":0,"data":{"image_url":"https://i0.hdslb.com/` + string(data) + `.png"}}`))
+			}
+		case "/x/dynamic/feed/create/dyn":
+			data, _ := io.ReadAll(r.Body)
+			pics = gjson.GetBytes(data, "dyn_req.pics").Array()
+			_, _ = w.Write([]byte(`{"code":0,"data":{"dyn_id_str":"123"}}`))
+		}
+	})
+	record := make(recordPusher, 1)
+	oldPusher := pusher
+	defer func() {
+		pusher = oldPusher
+	}()
+	pusher = record
+	b := &Bot{bili: bili, logger: logger.New("bot", logLevel, logDst)}
+
+	//按顺序上传图片后发布动态
+	b.postSummary("晚安", []string{writeImage(t, "a.png", "a"), writeImage(t, "b.png", "b")})
+	if uploads != 2 || len(pics) != 2 || pics[0].Get("img_src").String() != "https://i0.hdslb.com/a.png" ||
+		pics[1].Get("img_src").String() != "https://i0.hdslb.com/b.png" {
+		t.Errorf("want 2 uploads and pics, got %d, %v", uploads, pics)
+	}
+
+	//上传图片失败时推送错误，不发布动态
+	uploads, pics = 0, nil
+	b.postSummary("晚安", []string{writeImage(t, "bad.png", "bad"), writeImage(t, "c.png", "c")})
+	if uploads != 1 || pics != nil {
+		t.Errorf("want 1 upload and no dynamic, got %d, %v", uploads, pics)
+	}
+	select {
+	case msg := <-record:
+		if !strings.Contains(msg.Text, "上传图片失败") {
+			t.Errorf("got %q", msg.Text)
+		}
+	case <-time.After(time.Second):
+		t.Error("error not pushed")
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// utf8BOM Excel 打开不带 BOM 的 csv 文件时会使用系统编码，导致中文乱码
+const utf8BOM = "\xEF\xBB\xBF"
+
+//可以导出的表，值为该表中对应 -board 参数的列，为空表示不支持该参数
+var exportTables = map[string]string{
+	"comment":      "oid",
+	"follower":     "",
+	"account_stat": "",
+	"video_stat":   "aid",
+}
+
+// exportQuery 导出数据的条件
+type exportQuery struct {
+	table string //表名
+	from  int64  //开始时间，对应 ctime 列
+	to    int64  //结束时间，包含该时间点
+	board uint64 //评论区的oid，对于 video_stat 为视频的av号
+	uid   uint64 //用户的uid
+}
+
+//生成查询语句
+func (q exportQuery) sql() (string, []any, error) {
+	boardColumn, ok := exportTables[q.table]
+	if !ok {
+		return "", nil, fmt.Errorf("不支持导出该表：%s", q.table)
+	}
+	var (
+		conds []string
+		args  []any
+	)
+	if q.from != 0 {
+		conds = append(conds, "ctime >= ?")
+		args = append(args, q.from)
+	}
+	if q.to != 0 {
+		conds = append(conds, "ctime <= ?")
+		args = append(args, q.to)
+	}
+	if q.board != 0 {
+		if boardColumn == "" {
+			return "", nil, fmt.Errorf("%s 表不支持 -board 参数", q.table)
+		}
+		conds = append(conds, boardColumn+" = ?")
+		args = append(args, q.board)
+	}
+	if q.uid != 0 {
+		if q.table == "video_stat" {
+			return "", nil, fmt.Errorf("%s 表不支持 -uid 参数", q.table)
+		}
+		conds = append(conds, "uid = ?")
+		args = append(args, q.uid)
+	}
+	query := "select * from " + q.table
+	if len(conds) > 0 {
+		query += " where " + strings.Join(conds, " and ")
+	}
+	return query + " order by ctime, id", args, nil
+}
+
+// rowWriter 按指定格式逐行写入查询结果
+type rowWriter interface {
+	header(columns []string) error
+	row(columns []string, values []any) error
+	flush() error
+}
+
+//csv 和 tsv 格式
+type csvWriter struct {
+	w   *csv.Writer
+	buf []string
+}
+
+func (c *csvWriter) header(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) row(_ []string, values []any) error {
+	c.buf = c.buf[:0]
+	for _, v := range values {
+		c.buf = append(c.buf, formatValue(v))
+	}
+	return c.w.Write(c.buf)
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+//jsonl 格式，每行一个 json 对象，键的顺序和列的顺序一致
+type jsonlWriter struct {
+	w *bufio.Writer
+}
+
+func (j *jsonlWriter) header([]string) error {
+	return nil
+}
+
+func (j *jsonlWriter) row(columns []string, values []any) error {
+	_ = j.w.WriteByte('{')
+	for i, column := range columns {
+		if i > 0 {
+			_ = j.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(column)
+		_, _ = j.w.Write(key)
+		_ = j.w.WriteByte(':')
+		v := values[i]
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, _ = j.w.Write(value)
+	}
+	_, err := j.w.WriteString("}\n")
+	return err
+}
+
+func (j *jsonlWriter) flush() error {
+	return j.w.Flush()
+}
+
+func formatValue(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(value)
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+//创建对应格式的 rowWriter，bom 为 true 时在 csv 和 tsv 的开头写入 BOM
+func newRowWriter(out io.Writer, format string, bom bool) (rowWriter, error) {
+	buf := bufio.NewWriter(out)
+	switch format {
+	case "csv", "tsv":
+		if bom {
+			_, _ = buf.WriteString(utf8BOM)
+		}
+		w := csv.NewWriter(buf)
+		if format == "tsv" {
+			w.Comma = '\t'
+		}
+		return &csvWriter{w: w}, nil
+	case "jsonl":
+		return &jsonlWriter{w: buf}, nil
+	default:
+		return nil, fmt.Errorf("不支持的格式：%s", format)
+	}
+}
+
+// Export 将满足条件的数据逐行写入 w 中，不会一次性读取所有数据，返回写入的行数
+func (d *DB) Export(q exportQuery, w rowWriter) (int, error) {
+	query, args, err := q.sql()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := d.conn.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if err = w.header(columns); err != nil {
+		return 0, err
+	}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	count := 0
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return count, err
+		}
+		if err = w.row(columns, values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return count, err
+	}
+	return count, w.flush()
+}
+
+//导出数据库中的数据
+func exportCmd(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名")
+	table := fs.String("table", "comment", "导出的表：comment，follower，account_stat，video_stat")
+	from := fs.String("from", "", "开始时间，例如：2022-07-01 或 2022-07-01 12:00")
+	to := fs.String("to", "", "结束时间，格式同 from")
+	board := fs.Uint64("board", 0, "评论区的oid，导出 video_stat 时为视频的av号")
+	uid := fs.Uint64("uid", 0, "用户的uid")
+	format := fs.String("format", "csv", "导出格式：csv，tsv，jsonl")
+	output := fs.String("o", "-", "输出文件，为 - 时输出到标准输出")
+	bom := fs.Bool("bom", true, "csv 和 tsv 文件开头写入 BOM，便于 Excel 打开，输出到标准输出时默认不写入")
+	_ = fs.Parse(args)
+
+	q := exportQuery{table: *table, board: *board, uid: *uid}
+	var err error
+	if q.from, err = parseTime(*from, false); err != nil {
+		mainLogger.Error("错误的开始时间：%v", err)
+		return 2
+	}
+	if q.to, err = parseTime(*to, true); err != nil {
+		mainLogger.Error("错误的结束时间：%v", err)
+		return 2
+	}
+	if _, _, err = q.sql(); err != nil {
+		mainLogger.Error("%v", err)
+		return 2
+	}
+
+	d := NewReadOnlyDB(*dbname)
+	if d == nil {
+		return 1
+	}
+	defer d.Close()
+
+	var (
+		out  io.Writer = os.Stdout
+		file *os.File
+	)
+	if *output == "-" {
+		//输出到标准输出时通常会交给其他程序处理，除非指定了 -bom，否则不写入 BOM
+		explicit := false
+		fs.Visit(func(f *flag.Flag) {
+			explicit = explicit || f.Name == "bom"
+		})
+		*bom = *bom && explicit
+	} else {
+		if file, err = os.Create(*output); err != nil {
+			mainLogger.Error("创建文件失败，%v", err)
+			return 1
+		}
+		out = file
+	}
+	w, err := newRowWriter(out, *format, *bom)
+	if err != nil {
+		mainLogger.Error("%v", err)
+		if file != nil {
+			_ = file.Close()
+		}
+		return 2
+	}
+	count, err := d.Export(q, w)
+	//关闭文件失败时写入的数据可能不完整
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		mainLogger.Error("导出失败，%v", err)
+		return 1
+	}
+	mainLogger.Info("导出 %d 条数据到 %s", count, *output)
+	return 0
+}
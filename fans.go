@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/push"
+)
+
+const (
+	minBaselineSamples = 6 //计算基线所需的最少样本数
+)
+
+// Milestone 里程碑，数值每经过 step 的整数倍或者自定义的值时触发
+type Milestone struct {
+	step   int   //例如1000，则每隔1000触发一次，为0时不触发
+	values []int //自定义的里程碑
+}
+
+// Crossed 数值从 from 变化到 to 时经过的里程碑，数值下降时按从高到低的顺序
+func (m Milestone) Crossed(from, to int) []int {
+	low, high := from, to
+	if low > high {
+		low, high = high, low
+	}
+	var result []int
+	if m.step > 0 {
+		for v := (low/m.step + 1) * m.step; v <= high; v += m.step {
+			result = append(result, v)
+		}
+	}
+	for _, v := range m.values {
+		if v > low && v <= high && (m.step <= 0 || v%m.step != 0) {
+			result = append(result, v)
+		}
+	}
+	sort.Ints(result)
+	if from > to {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result
+}
+
+// MilestoneTracker 记录上一次提醒的里程碑，数值在里程碑附近来回波动时不会重复提醒：
+//上升时只提醒高于上一次提醒的里程碑，下降时只提醒低于上一次提醒的里程碑
+type MilestoneTracker struct {
+	Milestone
+	last    int  //上一次提醒的里程碑，还没有提醒过时为第一次检查时的数值
+	started bool //是否已经检查过
+}
+
+func NewMilestoneTracker(m Milestone) *MilestoneTracker {
+	return &MilestoneTracker{Milestone: m}
+}
+
+// Check 数值从 from 变化到 to 时需要提醒的里程碑，顺序和 Crossed 相同
+func (t *MilestoneTracker) Check(from, to int) []int {
+	if !t.started {
+		t.last, t.started = from, true
+	}
+	var result []int
+	for _, m := range t.Crossed(from, to) {
+		if (from < to && m > t.last) || (from > to && m < t.last) {
+			result = append(result, m)
+		}
+	}
+	if len(result) > 0 {
+		t.last = result[len(result)-1]
+	}
+	return result
+}
+
+// FansOption 粉丝数提醒的配置
+type FansOption struct {
+	Milestone         //粉丝数的里程碑
+	window    int     //计算基线使用的历史数据时长，单位：小时，为0时不进行异常检测
+	threshold float64 //变化速度偏离基线超过 threshold 倍标准差时视为异常
+	minDelta  int     //触发异常提醒的最小变化量，避免粉丝数较少时频繁提醒
+}
+
+// FansEvent 粉丝数提醒事件
+type FansEvent struct {
+	milestone int            //达到的里程碑，为0时表示异常变化
+	last      FollowerRecord //上一次记录的粉丝数
+	now       FollowerRecord //本次记录的粉丝数
+	baseline  float64        //基线，每分钟的平均变化量
+	deviation float64        //偏离基线的标准差倍数
+}
+
+// Delta 两次记录间的粉丝数变化
+func (e FansEvent) Delta() int {
+	return e.now.fans - e.last.fans
+}
+
+// Message 生成推送消息，alias 为账号的别名
+func (e FansEvent) Message(alias string) push.Message {
+	from := time.Unix(e.last.ctime, 0).Format("01-02 15:04")
+	to := time.Unix(e.now.ctime, 0).Format("01-02 15:04")
+	var title, text string
+	if e.milestone != 0 {
+		if e.Delta() >= 0 {
+			title = fmt.Sprintf("%s的粉丝数突破%d", alias, e.milestone)
+		} else {
+			title = fmt.Sprintf("%s的粉丝数跌破%d", alias, e.milestone)
+		}
+		text = fmt.Sprintf("%s - %s\n粉丝数：%d => %d(%+d)",
+			from, to, e.last.fans, e.now.fans, e.Delta())
+	} else {
+		if e.Delta() >= 0 {
+			title = fmt.Sprintf("%s的粉丝数异常增长", alias)
+		} else {
+			title = fmt.Sprintf("%s的粉丝数异常下降", alias)
+		}
+		minutes := float64(e.now.ctime-e.last.ctime) / 60
+		text = fmt.Sprintf("%s - %s\n粉丝数：%d => %d(%+d)\n基线：%+.1f/%.0f分钟",
+			from, to, e.last.fans, e.now.fans, e.Delta(), e.baseline*minutes, minutes)
+		if !math.IsInf(e.deviation, 1) {
+			text += fmt.Sprintf("，偏离%.1f倍标准差", e.deviation)
+		}
+	}
+	return push.Message{
+		Title: title,
+		Text:  text,
+	}
+}
+
+// FansAlert 粉丝数提醒，在粉丝数达到里程碑或者变化速度异常时触发
+type FansAlert struct {
+	FansOption
+	history    []FollowerRecord  //时间窗口内的粉丝数记录，按时间升序
+	milestones *MilestoneTracker //已经提醒过的里程碑
+}
+
+// NewFansAlert 创建 FansAlert，history 为数据库中保存的历史数据，用于计算基线
+func NewFansAlert(opt FansOption, history []FollowerRecord) *FansAlert {
+	return &FansAlert{
+		FansOption: opt,
+		history:    history,
+		milestones: NewMilestoneTracker(opt.Milestone),
+	}
+}
+
+// Check 记录新的粉丝数，并返回触发的提醒
+func (f *FansAlert) Check(record FollowerRecord) []FansEvent {
+	if len(f.history) == 0 {
+		f.history = append(f.history, record)
+		return nil
+	}
+	last := f.history[len(f.history)-1]
+	var events []FansEvent
+	for _, m := range f.milestones.Check(last.fans, record.fans) {
+		events = append(events, FansEvent{milestone: m, last: last, now: record})
+	}
+	if event, ok := f.anomaly(last, record); ok {
+		events = append(events, event)
+	}
+	f.history = append(f.history, record)
+	//移除时间窗口外的数据
+	begin := record.ctime - int64(f.window)*3600
+	i := 0
+	for i < len(f.history)-1 && f.history[i].ctime < begin {
+		i++
+	}
+	f.history = f.history[i:]
+	return events
+}
+
+//以历史数据中每分钟的变化速度作为基线，判断本次变化是否异常
+func (f *FansAlert) anomaly(last, now FollowerRecord) (FansEvent, bool) {
+	if f.window <= 0 || now.ctime <= last.ctime {
+		return FansEvent{}, false
+	}
+	delta := now.fans - last.fans
+	if delta < f.minDelta && -delta < f.minDelta {
+		return FansEvent{}, false
+	}
+	var rates []float64
+	for i := 1; i < len(f.history); i++ {
+		pre, cur := f.history[i-1], f.history[i]
+		if cur.ctime <= pre.ctime {
+			continue
+		}
+		rates = append(rates, float64(cur.fans-pre.fans)/float64(cur.ctime-pre.ctime)*60)
+	}
+	if len(rates) < minBaselineSamples {
+		return FansEvent{}, false
+	}
+	var mean, std float64
+	for _, r := range rates {
+		mean += r
+	}
+	mean /= float64(len(rates))
+	for _, r := range rates {
+		std += (r - mean) * (r - mean)
+	}
+	std = math.Sqrt(std / float64(len(rates)))
+	rate := float64(delta) / float64(now.ctime-last.ctime) * 60
+	//粉丝数稳定时标准差可能为0，此时只要达到 minDelta 就视为异常
+	deviation := math.Inf(1)
+	if std > 0 {
+		deviation = math.Abs(rate-mean) / std
+	} else if rate == mean {
+		return FansEvent{}, false
+	}
+	if deviation < f.threshold {
+		return FansEvent{}, false
+	}
+	return FansEvent{
+		last:      last,
+		now:       now,
+		baseline:  mean,
+		deviation: deviation,
+	}, true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMilestone_Crossed(t *testing.T) {
+	milestone := Milestone{step: 1000, values: []int{123456, 1500, 2000}}
+	tests := []struct {
+		name     string
+		from, to int
+		want     []int
+	}{
+		{"no change", 1001, 1001, nil},
+		{"inside step", 1001, 1999, []int{1500}},
+		{"reach step", 999, 1000, []int{1000}},
+		{"cross several", 900, 3100, []int{1000, 1500, 2000, 3000}},
+		{"custom", 123000, 123456, []int{123456}},
+		{"drop", 2100, 1400, []int{2000, 1500}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := milestone.Crossed(test.from, test.to); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("from=%d, to=%d, want %v, got %v", test.from, test.to, test.want, got)
+			}
+		})
+	}
+}
+
+func TestFansAlert_Check(t *testing.T) {
+	//每10分钟增长10个粉丝，波动很小
+	var history []FollowerRecord
+	fans := 10000
+	for i := 0; i < 12; i++ {
+		fans += 10 + i%2
+		history = append(history, FollowerRecord{uid: 1, ctime: int64(i * 600), fans: fans})
+	}
+	opt := FansOption{window: 24, threshold: 3, minDelta: 20}
+	tests := []struct {
+		name  string
+		delta int
+		want  bool
+	}{
+		{"normal", 11, false},
+		{"drop", -300, true},
+		{"spike", 500, true},
+		{"small", 15, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			alert := NewFansAlert(opt, append([]FollowerRecord(nil), history...))
+			record := FollowerRecord{uid: 1, ctime: 12 * 600, fans: fans + test.delta}
+			events := alert.Check(record)
+			if got := len(events) == 1 && events[0].milestone == 0; got != test.want {
+				t.Errorf("delta=%d, want anomaly %v, got %v", test.delta, test.want, events)
+			}
+		})
+	}
+}
+
+func TestMilestoneTracker_Check(t *testing.T) {
+	tracker := NewMilestoneTracker(Milestone{step: 1000})
+	tests := []struct {
+		from, to int
+		want     []int
+	}{
+		{990, 1001, []int{1000}},
+		//在里程碑附近来回波动时不重复提醒
+		{1001, 999, nil},
+		{999, 1000, nil},
+		{1000, 999, nil},
+		{999, 2003, []int{2000}},
+		{2003, 1990, nil},
+		//下降到新的里程碑以下
+		{1990, 950, []int{1000}},
+		{950, 1001, nil},
+		{1001, 2001, []int{2000}},
+	}
+	for _, test := range tests {
+		if got := tracker.Check(test.from, test.to); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("from=%d, to=%d, want %v, got %v", test.from, test.to, test.want, got)
+		}
+	}
+}
+
+func TestFansAlert_CheckOscillation(t *testing.T) {
+	alert := NewFansAlert(FansOption{Milestone: Milestone{step: 1000}}, nil)
+	var milestones []int
+	for i, fans := range []int{998, 999, 1000, 999, 1000, 999, 1000, 1001} {
+		for _, e := range alert.Check(FollowerRecord{uid: 1, ctime: int64(i * 600), fans: fans}) {
+			milestones = append(milestones, e.milestone)
+		}
+	}
+	if !reflect.DeepEqual(milestones, []int{1000}) {
+		t.Errorf("want one milestone, got %v", milestones)
+	}
+}
@@ -6,9 +6,11 @@ require (
 	github.com/andybalholm/brotli v1.0.4
 	github.com/mattn/go-sqlite3 v1.14.13
 	github.com/tidwall/gjson v1.14.1
+	golang.org/x/image v0.14.0
 )
 
 require (
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
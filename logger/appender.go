@@ -108,19 +108,26 @@ func (f *FileAppender) logFile() {
 	f.nowSize = 0
 }
 
-//ConsoleAppender 向标准输出写日志
-type ConsoleAppender struct{}
+//ConsoleAppender 向标准输出或标准错误写日志
+type ConsoleAppender struct {
+	out *os.File
+}
 
 func NewConsoleAppender() *ConsoleAppender {
-	return &ConsoleAppender{}
+	return &ConsoleAppender{out: os.Stdout}
+}
+
+//NewStderrAppender 向标准错误写日志，用于标准输出需要输出数据的情况
+func NewStderrAppender() *ConsoleAppender {
+	return &ConsoleAppender{out: os.Stderr}
 }
 
 func (c *ConsoleAppender) Write(p []byte) (int, error) {
-	return os.Stdout.Write(p)
+	return c.out.Write(p)
 }
 
 func (c *ConsoleAppender) WriteMsg(msg string) {
-	_, _ = os.Stdout.WriteString(msg)
+	_, _ = c.out.WriteString(msg)
 }
 
 func (c *ConsoleAppender) Close() {
@@ -6,6 +6,8 @@ import (
 	"flag"
 	"github.com/Hami-Lemon/bobo-bot/logger"
 	"github.com/Hami-Lemon/bobo-bot/push"
+	"github.com/Hami-Lemon/bobo-bot/report"
+	"github.com/Hami-Lemon/bobo-bot/util"
 	"github.com/tidwall/gjson"
 	"io"
 	"os"
@@ -15,8 +17,9 @@ import (
 )
 
 const (
-	Version     = "0.3.2"
-	logFileSize = 1024 * 512
+	Version        = "0.3.2"
+	logFileSize    = 1024 * 512
+	defaultChannel = "default" //默认推送渠道的名称
 )
 
 var (
@@ -26,19 +29,22 @@ var (
 	logDst      logger.Appender = logger.NewConsoleAppender()
 	mainLogger                  = logger.New("main", logLevel, logger.NewConsoleAppender())
 	db          *DB
-	pusher      push.Pusher //消息推送
-	summaryFile = flag.String("r", "", "数据总结文件")
+	pusher      push.Pusher            //消息推送，默认的推送渠道
+	channels    map[string]push.Pusher //所有推送渠道，键为渠道名称
+	summaryFile = flag.String("r", "", "数据总结文件，db:latest 或 db:<id> 表示从数据库中读取")
 )
 
 type config struct {
 	BotOption
 	isFans bool
-	hour   int
-	minute int
 	dbname string
+	db     DBOption
 }
 
 func main() {
+	if code, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
 	flag.Parse()
 	mainLogger.Info("bobo-bot version: %s build on %s", Version, buildTime)
 	botAccount, monitorAccount, board, con := readSetting()
@@ -49,24 +55,24 @@ func main() {
 	} else {
 		mainLogger.Info("登录成功，%s", bili.user.uname)
 	}
-	db = NewDB(con.dbname)
+	db = NewDB(con.dbname, con.db)
 	if db == nil {
 		return
 	}
 	var bot *Bot
 	if strings.Compare("", *summaryFile) == 0 {
 		bot = NewBot(bili, board, monitorAccount, con.BotOption)
+		bot.ResumeCheckpoints(time.Now())
 	} else {
 		mainLogger.Info("从上次中断中恢复...")
-		f, err := os.Open(*summaryFile)
-		if err != nil {
-			mainLogger.Error("打开文件失败，%v", err)
+		//从数据库中读取最近的一条时，只读取当前评论区第一个统计时段的数据总结
+		if !bili.BoardDetail(&board) {
+			mainLogger.Error("获取评论区信息失败！")
 			return
 		}
-		var summary Summary
-		err = json.NewDecoder(f).Decode(&summary)
+		summary, err := loadSummary(*summaryFile, board.oid, con.windows[0].name)
 		if err != nil {
-			mainLogger.Error("解析文件失败，%v", err)
+			mainLogger.Error("读取恢复信息失败，%v", err)
 			return
 		}
 		bot = RecoverBot(bili, con.BotOption, summary)
@@ -75,28 +81,69 @@ func main() {
 		mainLogger.Info("account:%d, uname=%s, follower=%d", bot.monitor.uid, bot.monitor.uname, bot.monitor.follower)
 	}
 	go waitExit(bot)
-	go summarize(bot, con.hour, con.minute)
+	bot.CatchUp(time.Now())
+	go bot.RunWindows()
+	go bot.RunAggregates()
+	go bot.MonitorCheckpoint()
 	go readCmd(bot)
 	mainLogger.Info("开始赛博监控...")
 	mainLogger.Info("监控评论区：name=%s, did=%d, bv=%s", board.name, board.dId, board.bvID)
 	defer logDst.Close()
 	if con.isFans {
-		mainLogger.Info("粉丝数监控：uid=%d", monitorAccount.uid)
-		go bot.MonitorFans()
+		mainLogger.Info("统计数据监控：uid=%d, interval=%d分钟, stats=%v",
+			monitorAccount.uid, con.statInterval, con.stats)
+		go bot.MonitorStats()
+	}
+	if board.bvID != "" && con.video.interval > 0 {
+		mainLogger.Info("视频数据监控：bv=%s, interval=%d分钟", board.bvID, con.video.interval)
+		go bot.MonitorVideo()
 	}
 	bot.Monitor()
-	bot.Summarize()
+	bot.Checkpoint()
+	for _, w := range bot.windows {
+		_, _ = bot.Summarize(w, true)
+	}
 	db.Close()
 	mainLogger.Info("程序停止")
 }
 
+//读取用于恢复的数据总结，name 为json文件名，或者 db:latest，db:<id> 表示从数据库中读取，
+//其中 db:latest 为评论区 oid 的统计时段 window 中最近的一条
+func loadSummary(name string, oid uint64, window string) (report.Summary, error) {
+	var summary report.Summary
+	id, ok, err := parseRecoverID(name)
+	if err != nil {
+		return summary, err
+	}
+	if ok && id == 0 {
+		return db.LatestSummary(oid, window)
+	}
+	if ok {
+		return db.LoadSummary(id)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return summary, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&summary)
+	return summary, err
+}
+
 func readCmd(bot *Bot) {
 	sc := bufio.NewScanner(os.Stdin)
 	for sc.Scan() {
 		text := sc.Text()
+		args := strings.Fields(text)
 		if strings.Compare(text, "exit") == 0 || strings.Compare(text, "quit") == 0 {
 			bot.Stop()
 			return
+		} else if len(args) > 0 && args[0] == "watch" {
+			watchCmd(bot.watchlist, args[1:])
+		} else if len(args) > 0 && args[0] == "history" {
+			historyCmd(bot.board.oid, args[1:])
+		} else if len(args) > 0 && args[0] == "top" {
+			topCmd(bot.board.oid, bot.counter.startTime.Unix(), args[1:])
 		} else {
 			mainLogger.Warn("error command!")
 		}
@@ -112,23 +159,25 @@ func waitExit(bot *Bot) {
 	bot.Stop()
 }
 
-//定时器，在指定时间汇总数据
-func summarize(bot *Bot, h, m int) {
-	tick := time.Tick(time.Minute)
-	for t := range tick {
-		if (h == -1 || t.Hour() == h) && t.Minute() == m {
-			fileName := bot.Summarize()
-			if strings.Compare("", fileName) != 0 {
-				bot.ReportSummarize(fileName)
-			}
+//推送错误消息，如果推送失败，写入到日志中
+func pushAndLog(l *logger.Logger, msg string, args ...any) {
+	go func() {
+		err := pusher.Push(push.NewText(msg, args...))
+		if err != nil {
+			l.Error("推送消息失败，%v", err)
 		}
-	}
+		l.Debug("推送消息成功")
+	}()
 }
 
-//推送错误消息，如果推送失败，写入到日志中
-func pushAndLog(l *logger.Logger, msg string, args ...any) {
+//推送消息到指定渠道，渠道不存在时使用默认渠道，如果推送失败，写入到日志中
+func pushMessage(l *logger.Logger, channel string, msg push.Message) {
+	p, ok := channels[channel]
+	if !ok {
+		p = pusher
+	}
 	go func() {
-		err := pusher.Push(msg, args...)
+		err := p.Push(msg)
 		if err != nil {
 			l.Error("推送消息失败，%v", err)
 		}
@@ -176,10 +225,48 @@ func readSetting() (BotAccount, MonitorAccount, Board, config) {
 	con.likeCD = float32(setting.Get("config.like").Float()) //点赞一次后等待的秒数
 	con.isLike = setting.Get("config.isLike").Bool()
 	con.isPost = setting.Get("config.isPost").Bool()
-	con.isFans = setting.Get("config.isFans").Bool()     //是否监控粉丝数变化
-	con.hour = int(setting.Get("config.hour").Int())     //生成数据汇总的小时数，为 -1 则每小时生成一次
-	con.minute = int(setting.Get("config.minute").Int()) //生成数据汇总的分钟数
-	con.dbname = setting.Get("config.dbname").String()   //sqlite3 数据库名称，一个文件名即可
+	con.isFans = setting.Get("config.isFans").Bool()                 //是否监控粉丝数等统计数据变化
+	con.statInterval = int(setting.Get("config.statInterval").Int()) //统计数据的记录间隔，单位：分钟
+	if con.statInterval <= 0 {
+		con.statInterval = 10
+	}
+	hasFollower := false
+	for _, stat := range setting.Get("config.stats").Array() { //需要记录的统计数据项，为空时记录所有数据项
+		if err := checkStat(con.stats, stat.String()); err != nil {
+			mainLogger.Error("读取统计数据项失败，%v", err)
+			panic(err)
+		}
+		con.stats = append(con.stats, stat.String())
+		hasFollower = hasFollower || stat.String() == StatFollower
+	}
+	if len(con.stats) == 0 {
+		con.stats = AllStats
+	} else if !hasFollower {
+		//粉丝数的记录、提醒和数据总结都依赖粉丝数，总是需要获取
+		con.stats = append([]string{StatFollower}, con.stats...)
+	}
+	//生成数据总结的统计时段，没有配置 windows 时使用 hour 和 minute，hour 为 -1 则每小时生成一次
+	if con.windows, err = readWindows(setting.Get("config")); err != nil {
+		mainLogger.Error("读取统计时段失败，%v", err)
+		panic(err)
+	}
+	//程序启动时每个统计时段最多补全的数据总结数量，默认为24，小于等于0时不补全
+	if catchUp := setting.Get("config.catchUp"); catchUp.Exists() {
+		con.catchUp = int(catchUp.Int())
+	} else {
+		con.catchUp = defaultCatchUp
+	}
+	con.dbname = setting.Get("config.dbname").String() //sqlite3 数据库名称，一个文件名即可
+	//保存检查点的间隔，单位：分钟，默认为5，小于等于0时不保存
+	if checkpoint := setting.Get("config.checkpoint"); checkpoint.Exists() {
+		con.checkpoint = int(checkpoint.Int())
+	} else {
+		con.checkpoint = 5
+	}
+	//每个事务最多写入的数据条数
+	con.db.batchSize = int(setting.Get("config.dbBatch").Int())
+	//两次提交事务的最大间隔，单位：毫秒
+	con.db.flushInterval = time.Duration(setting.Get("config.dbFlush").Int()) * time.Millisecond
 
 	loggerLevel := setting.Get("logger.level").String()       //日志级别
 	loggerAppender := setting.Get("logger.appender").String() //日志写入文件还是直接在控制台输出
@@ -187,7 +274,120 @@ func readSetting() (BotAccount, MonitorAccount, Board, config) {
 	//使用钉钉机器人推送消息，如果webhook为空字符串，则不会推送
 	webhook := setting.Get("push.webhook").String()
 	secret := setting.Get("push.secret").String()
-	pusher = push.NewDingPusher(webhook, secret)
+	//使用telegram机器人推送消息，如果token为空字符串，则不会推送
+	tgToken := setting.Get("push.telegram.token").String()
+	tgChatId := setting.Get("push.telegram.chatId").String()
+	ding := push.NewDingPusher(webhook, secret)
+	telegram := push.NewTelegramPusher(tgToken, tgChatId)
+	pusher = push.NewMultiPusher(ding, telegram)
+	channels = map[string]push.Pusher{
+		defaultChannel: pusher,
+		"ding":         ding,
+		"telegram":     telegram,
+	}
+	//自定义的推送渠道
+	setting.Get("push.channels").ForEach(func(key, value gjson.Result) bool {
+		switch value.Get("type").String() {
+		case "ding":
+			channels[key.String()] = push.NewDingPusher(value.Get("webhook").String(),
+				value.Get("secret").String())
+		case "telegram":
+			channels[key.String()] = push.NewTelegramPusher(value.Get("token").String(),
+				value.Get("chatId").String())
+		default:
+			mainLogger.Warn("未知的推送渠道类型：%s, type=%s", key.String(), value.Get("type").String())
+		}
+		return true
+	})
+
+	//粉丝数提醒
+	con.fans.step = int(setting.Get("fans.step").Int())        //每隔 step 个粉丝提醒一次
+	for _, m := range setting.Get("fans.milestones").Array() { //自定义的里程碑
+		con.fans.values = append(con.fans.values, int(m.Int()))
+	}
+	con.fans.window = int(setting.Get("fans.window").Int())     //计算基线的时间窗口，单位：小时
+	con.fans.threshold = setting.Get("fans.threshold").Float()  //偏离基线的标准差倍数
+	con.fans.minDelta = int(setting.Get("fans.minDelta").Int()) //触发异常提醒的最小变化量
+	if con.fans.threshold <= 0 {
+		con.fans.threshold = 3
+	}
+
+	//刷屏检测
+	con.spam.window = setting.Get("spam.window").Int()              //滑动窗口的时长，单位：秒
+	con.spam.duplicates = int(setting.Get("spam.duplicates").Int()) //相似评论的数量上限
+	con.spam.similarity = setting.Get("spam.similarity").Float()    //相似度的下限
+	con.spam.rate = int(setting.Get("spam.rate").Int())             //同一个用户的评论数上限
+	con.spam.skipLike = setting.Get("spam.skipLike").Bool()         //是否不点赞被标记的评论
+	if con.spam.window <= 0 {
+		con.spam.window = defaultSpamWindow
+	}
+	if con.spam.similarity <= 0 || con.spam.similarity > 1 {
+		con.spam.similarity = defaultSpamSimilarity
+	}
+
+	//视频数据监控，只对视频评论区生效
+	con.video.interval = int(setting.Get("video.interval").Int()) //记录间隔，单位：分钟
+	con.video.hotPages = int(setting.Get("video.hotPages").Int()) //查找热门列表的页数
+	con.video.milestones = make(map[string]Milestone)
+	//各项数据的里程碑，值为数字时表示每隔多少触发一次，为数组时表示自定义的里程碑
+	setting.Get("video.milestones").ForEach(func(key, value gjson.Result) bool {
+		var m Milestone
+		if value.IsArray() {
+			for _, v := range value.Array() {
+				m.values = append(m.values, int(v.Int()))
+			}
+		} else {
+			m.step = int(value.Int())
+		}
+		con.video.milestones[key.String()] = m
+		return true
+	})
+
+	//数据总结文字的模板文件，为空时使用默认模板
+	var tmplText string
+	if tmplFile := setting.Get("report.template").String(); tmplFile != "" {
+		tmplData, err := os.ReadFile(tmplFile)
+		if err != nil {
+			mainLogger.Error("读取数据总结模板失败，%v", err)
+			panic(err)
+		}
+		tmplText = string(tmplData)
+	}
+	if con.reportTmpl, err = report.ParseTemplate(tmplText); err != nil {
+		mainLogger.Error("解析数据总结模板失败，%v", err)
+		panic(err)
+	}
+
+	//数据总结的图表，发布动态时需要上传png图片
+	if con.chart, con.chartFormats, err = readChartOption(setting.Get("report.chart")); err != nil {
+		mainLogger.Error("读取图表配置失败，%v", err)
+		panic(err)
+	}
+	//周报和月报，默认合并第一个统计时段的数据总结
+	loc, err := readLocation(setting.Get("config"))
+	if err == nil {
+		con.aggregate, err = readAggregateOption(setting.Get("report.aggregate"), loc, con.windows[0].name)
+	}
+	if err != nil {
+		mainLogger.Error("读取周报和月报配置失败，%v", err)
+		panic(err)
+	}
+	if (con.isPost || con.aggregate.isPost) && !util.Contains(con.chartFormats, "png") {
+		con.chartFormats = append(con.chartFormats, "png")
+	}
+
+	//关注列表，列表中的用户发送评论时推送提醒
+	for _, item := range setting.Get("watchlist").Array() {
+		watch := Watch{
+			uid:     item.Get("uid").Uint(),
+			alias:   item.Get("alias").String(),
+			channel: item.Get("channel").String(),
+		}
+		for _, keyword := range item.Get("keywords").Array() {
+			watch.keywords = append(watch.keywords, keyword.String())
+		}
+		con.watchlist = append(con.watchlist, watch)
+	}
 
 	switch loggerLevel {
 	case "Debug":
@@ -16,63 +16,111 @@ import (
 )
 
 type Pusher interface {
-	Push(msg string, args ...any) error
+	Push(msg Message) error
 }
 
-// DingPusher 钉钉机器人消息推送
-type DingPusher struct {
-	webhook string //webhook地址
-	secret  string //签名密钥
+// Message 推送的消息，各推送方式会将其转换为对应的消息格式，不支持的部分退化为纯文本
+type Message struct {
+	Title    string   //标题
+	Text     string   //纯文本形式的正文，不能为空
+	Markdown string   //markdown 格式的正文，为空时使用 Text
+	Link     string   //消息对应的链接，例如评论的地址
+	Image    string   //图片地址
+	At       []string //需要at的人，钉钉中为手机号，telegram中为用户名
+	AtAll    bool     //是否at全体成员
 }
 
-func NewDingPusher(webhook, secret string) *DingPusher {
-	return &DingPusher{
-		webhook: webhook,
-		secret:  secret,
+// NewText 创建一条纯文本消息，和之前的推送保持一致，默认at全体成员
+func NewText(msg string, args ...any) Message {
+	return Message{
+		Text:  fmt.Sprintf(msg, args...),
+		AtAll: true,
 	}
 }
 
-func (d *DingPusher) Push(msg string, args ...any) error {
-	//未配置webhook，不进行推送
-	if strings.Compare("", d.webhook) == 0 {
-		return nil
+// PlainText 将消息转换为纯文本形式，标题和链接会拼接到正文中
+func (m Message) PlainText() string {
+	var sb strings.Builder
+	if m.Title != "" {
+		sb.WriteString(m.Title)
+		sb.WriteByte('\n')
 	}
-	urlStr, err := d.sign()
-	if err != nil {
-		return err
+	sb.WriteString(m.Text)
+	if m.Link != "" {
+		sb.WriteByte('\n')
+		sb.WriteString(m.Link)
 	}
-	text := fmt.Sprintf(msg, args...)
-	body := map[string]interface{}{
-		"at": map[string]interface{}{
-			"isAtAll": true, //at全体成员
-		},
-		"text": map[string]interface{}{
-			"content": text, //消息内容
-		},
-		"msgtype": "text", //消息为文本类型
+	return sb.String()
+}
+
+// MultiPusher 同时推送到多个渠道
+type MultiPusher []Pusher
+
+func NewMultiPusher(pushers ...Pusher) MultiPusher {
+	return pushers
+}
+
+func (m MultiPusher) Push(msg Message) error {
+	var errMsg []string
+	for _, p := range m {
+		if err := p.Push(msg); err != nil {
+			errMsg = append(errMsg, err.Error())
+		}
+	}
+	if len(errMsg) != 0 {
+		return errors.New(strings.Join(errMsg, "; "))
 	}
+	return nil
+}
+
+//发送json格式的请求体，并将响应解析到 resp 中
+func postJson(urlStr string, body any, resp any) error {
 	jsonBody, _ := json.Marshal(body)
 	reader := bytes.NewReader(jsonBody)
 
 	req, err := http.NewRequest(http.MethodPost, urlStr, reader)
 	if err != nil {
-		return nil
+		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	client := &http.Client{Timeout: 2 * time.Second} //两秒的超时
-	resp, err := client.Do(req)
+	r, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = resp.Body.Close()
+		_ = r.Body.Close()
 	}()
+	return json.NewDecoder(r.Body).Decode(resp)
+}
 
+// DingPusher 钉钉机器人消息推送
+type DingPusher struct {
+	webhook string //webhook地址
+	secret  string //签名密钥
+}
+
+func NewDingPusher(webhook, secret string) *DingPusher {
+	return &DingPusher{
+		webhook: webhook,
+		secret:  secret,
+	}
+}
+
+func (d *DingPusher) Push(msg Message) error {
+	//未配置webhook，不进行推送
+	if strings.Compare("", d.webhook) == 0 {
+		return nil
+	}
+	urlStr, err := d.sign()
+	if err != nil {
+		return err
+	}
 	var errInfo struct {
 		ErrCode int    `json:"errcode"`
 		ErrMsg  string `json:"errmsg"`
 	}
-	err = json.NewDecoder(resp.Body).Decode(&errInfo)
+	err = postJson(urlStr, d.body(msg), &errInfo)
 	if err != nil {
 		return err
 	}
@@ -82,6 +130,78 @@ func (d *DingPusher) Push(msg string, args ...any) error {
 	return nil
 }
 
+//将消息转换为钉钉的消息格式
+//https://open.dingtalk.com/document/group/message-types-and-data-format
+//有链接且不需要at时使用 actionCard，有链接、图片或 markdown 正文时使用 markdown，否则使用 text。
+//actionCard 不支持at，需要at时链接放在 markdown 正文中
+func (d *DingPusher) body(msg Message) map[string]interface{} {
+	at := map[string]interface{}{
+		"atMobiles": msg.At,
+		"isAtAll":   msg.AtAll,
+	}
+	if msg.Link == "" && msg.Image == "" && msg.Markdown == "" {
+		return map[string]interface{}{
+			"at": at,
+			"text": map[string]interface{}{
+				"content": msg.PlainText(), //消息内容
+			},
+			"msgtype": "text", //消息为文本类型
+		}
+	}
+	title := msg.Title
+	if title == "" {
+		//标题只会显示在会话列表中，取正文的第一行
+		title, _, _ = strings.Cut(msg.Text, "\n")
+	}
+	var sb strings.Builder
+	if msg.Markdown != "" {
+		sb.WriteString(msg.Markdown)
+	} else {
+		if msg.Title != "" {
+			sb.WriteString("#### ")
+			sb.WriteString(msg.Title)
+			sb.WriteString("\n\n")
+		}
+		//markdown 中的换行需要两个换行符
+		sb.WriteString(strings.ReplaceAll(msg.Text, "\n", "\n\n"))
+	}
+	if msg.Image != "" {
+		sb.WriteString("\n\n![image](")
+		sb.WriteString(msg.Image)
+		sb.WriteString(")")
+	}
+	needAt := msg.AtAll || len(msg.At) > 0
+	if msg.Link != "" && needAt {
+		sb.WriteString("\n\n[查看详情](")
+		sb.WriteString(msg.Link)
+		sb.WriteString(")")
+	}
+	//markdown 消息中需要在正文内包含 @手机号 才会at对应的人
+	for _, mobile := range msg.At {
+		sb.WriteString(" @")
+		sb.WriteString(mobile)
+	}
+	if msg.Link != "" && !needAt {
+		return map[string]interface{}{
+			"actionCard": map[string]interface{}{
+				"title":       title,
+				"text":        sb.String(),
+				"singleTitle": "查看详情",
+				"singleURL":   msg.Link,
+			},
+			"msgtype": "actionCard",
+		}
+	}
+	return map[string]interface{}{
+		"at": at,
+		"markdown": map[string]interface{}{
+			"title": title,
+			"text":  sb.String(),
+		},
+		"msgtype": "markdown",
+	}
+}
+
 func (d *DingPusher) sign() (string, error) {
 	if strings.Compare("", d.secret) == 0 {
 		return d.webhook, nil
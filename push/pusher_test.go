@@ -0,0 +1,52 @@
+package push
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestDingPusher_body(t *testing.T) {
+	d := NewDingPusher("", "")
+	tests := []struct {
+		msg      Message
+		msgType  string
+		atAll    bool
+		contains []string
+	}{
+		{NewText("啵啵开播了"), "text", true, []string{"啵啵开播了"}},
+		//需要at时链接放在 markdown 正文中
+		{Message{Title: "新评论", Text: "晚安", Link: "https://b23.tv/1", AtAll: true}, "markdown", true,
+			[]string{"#### 新评论", "晚安", "[查看详情](https://b23.tv/1)"}},
+		{Message{Text: "晚安", Link: "https://b23.tv/1", At: []string{"123"}}, "markdown", false,
+			[]string{"[查看详情](https://b23.tv/1)", "@123"}},
+		{Message{Title: "新评论", Text: "晚安", Link: "https://b23.tv/1"}, "actionCard", false, []string{"晚安"}},
+		{Message{Text: "粉丝数", Image: "https://i0.hdslb.com/a.png"}, "markdown", false,
+			[]string{"![image](https://i0.hdslb.com/a.png)"}},
+	}
+	for i, tt := range tests {
+		data, err := json.Marshal(d.body(tt.msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := gjson.ParseBytes(data)
+		if got := body.Get("msgtype").String(); got != tt.msgType {
+			t.Errorf("%d: want msgtype %s, got %s", i, tt.msgType, got)
+			continue
+		}
+		if got := body.Get("at.isAtAll").Bool(); got != tt.atAll {
+			t.Errorf("%d: want isAtAll %v, got %v", i, tt.atAll, got)
+		}
+		text := body.Get(tt.msgType + ".text").String()
+		if tt.msgType == "text" {
+			text = body.Get("text.content").String()
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(text, s) {
+				t.Errorf("%d: %q not in %q", i, s, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package push
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+)
+
+const (
+	telegramApi        = "https://api.telegram.org/bot%s/%s"
+	telegramCaptionMax = 1024 //图片说明的最大长度
+)
+
+// TelegramPusher telegram机器人消息推送
+type TelegramPusher struct {
+	token  string //机器人的token
+	chatId string //接收消息的会话id，可以是频道的用户名，例如：@channel
+}
+
+func NewTelegramPusher(token, chatId string) *TelegramPusher {
+	return &TelegramPusher{
+		token:  token,
+		chatId: chatId,
+	}
+}
+
+func (t *TelegramPusher) Push(msg Message) error {
+	//未配置token，不进行推送
+	if t.token == "" || t.chatId == "" {
+		return nil
+	}
+	text := t.html(msg)
+	//有图片时以图片说明的形式发送，说明过长时先发送图片，再发送文本
+	if msg.Image != "" {
+		caption := text
+		if len([]rune(caption)) > telegramCaptionMax {
+			caption = ""
+		}
+		err := t.send("sendPhoto", map[string]interface{}{
+			"chat_id":    t.chatId,
+			"photo":      msg.Image,
+			"caption":    caption,
+			"parse_mode": "HTML",
+		})
+		if err != nil || caption != "" {
+			return err
+		}
+	}
+	return t.send("sendMessage", map[string]interface{}{
+		"chat_id":    t.chatId,
+		"text":       text,
+		"parse_mode": "HTML",
+	})
+}
+
+//将消息转换为 telegram 支持的 html 格式，telegram 不支持完整的 markdown，所以正文只使用纯文本
+//https://core.telegram.org/bots/api#html-style
+func (t *TelegramPusher) html(msg Message) string {
+	var sb strings.Builder
+	if msg.Title != "" {
+		sb.WriteString("<b>")
+		sb.WriteString(html.EscapeString(msg.Title))
+		sb.WriteString("</b>\n")
+	}
+	sb.WriteString(html.EscapeString(msg.Text))
+	if msg.Link != "" {
+		sb.WriteString(fmt.Sprintf("\n<a href=\"%s\">查看详情</a>", html.EscapeString(msg.Link)))
+	}
+	for _, user := range msg.At {
+		sb.WriteString(" @")
+		sb.WriteString(html.EscapeString(strings.TrimPrefix(user, "@")))
+	}
+	return sb.String()
+}
+
+func (t *TelegramPusher) send(method string, body map[string]interface{}) error {
+	var resp struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	err := postJson(fmt.Sprintf(telegramApi, t.token, method), body, &resp)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return errors.New(resp.Description)
+	}
+	return nil
+}
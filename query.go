@@ -0,0 +1,265 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+//评论表中查询的列，和 scanComment 中的顺序一致
+const commentColumns = `oid, type_code, rpid, ctime, msg, like_time, uid, uname,
+coalesce(location, ''), coalesce(watched, 0), coalesce(like_count, 0), coalesce(reply_count, 0),
+coalesce(spam, '')`
+
+// CommentQuery 评论的查询条件，零值表示不限制该条件
+type CommentQuery struct {
+	oid     uint64 //评论区的oid
+	uid     uint64 //评论发送者的uid
+	keyword string //评论内容中包含的关键词
+	from    int64  //评论发布时间的起点，时间戳形式，单位秒
+	to      int64  //评论发布时间的终点，包含该时间点
+	limit   int    //最多返回的评论数
+	desc    bool   //是否按发布时间降序排列
+	noSpam  bool   //是否排除被标记为刷屏的评论
+}
+
+//生成 where 子句和对应的参数
+func (q CommentQuery) where() (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	if q.oid != 0 {
+		conds = append(conds, "oid = ?")
+		args = append(args, q.oid)
+	}
+	if q.uid != 0 {
+		conds = append(conds, "uid = ?")
+		args = append(args, q.uid)
+	}
+	if q.keyword != "" {
+		conds = append(conds, "instr(msg, ?) > 0")
+		args = append(args, q.keyword)
+	}
+	if q.from != 0 {
+		conds = append(conds, "ctime >= ?")
+		args = append(args, q.from)
+	}
+	if q.to != 0 {
+		conds = append(conds, "ctime <= ?")
+		args = append(args, q.to)
+	}
+	if q.noSpam {
+		conds = append(conds, "coalesce(spam, '') = ''")
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " where " + strings.Join(conds, " and "), args
+}
+
+//从查询结果中读取一条评论
+func scanComment(scan func(dest ...any) error) (CommentRecord, error) {
+	var r CommentRecord
+	err := scan(&r.oid, &r.typeCode, &r.replyId, &r.ctime, &r.msg, &r.likeTime, &r.uid, &r.uname,
+		&r.location, &r.watched, &r.like, &r.rcount, &r.spam)
+	return r, err
+}
+
+// Comments 查询满足条件的评论，默认按发布时间升序
+func (d *DB) Comments(q CommentQuery) ([]CommentRecord, error) {
+	where, args := q.where()
+	query := "select " + commentColumns + " from comment" + where + " order by ctime"
+	if q.desc {
+		query += " desc"
+	}
+	if q.limit > 0 {
+		query += " limit ?"
+		args = append(args, q.limit)
+	}
+	rows, err := d.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []CommentRecord
+	for rows.Next() {
+		r, err := scanComment(rows.Scan)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// CommentsByTime 查询评论区在 [from, to] 时间段内发布的评论
+func (d *DB) CommentsByTime(oid uint64, from, to int64) ([]CommentRecord, error) {
+	return d.Comments(CommentQuery{oid: oid, from: from, to: to})
+}
+
+// CommentsByUid 查询用户发送的评论，oid 为0时查询所有评论区
+func (d *DB) CommentsByUid(oid, uid uint64) ([]CommentRecord, error) {
+	return d.Comments(CommentQuery{oid: oid, uid: uid})
+}
+
+// CommentsByKeyword 查询包含关键词的评论，oid 为0时查询所有评论区
+func (d *DB) CommentsByKeyword(oid uint64, keyword string) ([]CommentRecord, error) {
+	return d.Comments(CommentQuery{oid: oid, keyword: keyword})
+}
+
+// CommentsByBoard 查询评论区的所有评论
+func (d *DB) CommentsByBoard(oid uint64) ([]CommentRecord, error) {
+	return d.Comments(CommentQuery{oid: oid})
+}
+
+// CommenterCount 用户的发评数量
+type CommenterCount struct {
+	uid   uint64 //用户的uid
+	uname string //最近一次记录的用户名
+	count int    //发送的评论数
+}
+
+// TopCommenters 查询发送评论最多的 n 个用户，oid 为0时查询所有评论区，不包含被标记为刷屏的评论
+func (d *DB) TopCommenters(oid uint64, from, to int64, n int) ([]CommenterCount, error) {
+	where, args := CommentQuery{oid: oid, from: from, to: to, noSpam: true}.where()
+	args = append(args, n)
+	//使用 max(ctime) 时，sqlite 中的 uname 为 ctime 最大的那一条记录中的值
+	rows, err := d.conn.Query(`select uid, uname, count(*) as cnt, max(ctime)
+from comment`+where+` group by uid order by cnt desc, uid limit ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []CommenterCount
+	for rows.Next() {
+		var (
+			c    CommenterCount
+			last int64
+		)
+		if err = rows.Scan(&c.uid, &c.uname, &c.count, &last); err != nil {
+			return result, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// FirstComment 用户在评论区中第一条评论的发布时间，包含补全历史评论时获取的评论，没有评论时为0
+func (d *DB) FirstComment(oid, uid uint64) (int64, error) {
+	var first int64
+	err := d.conn.QueryRow("select coalesce(min(ctime), 0) from comment where oid = ? and uid = ?",
+		oid, uid).Scan(&first)
+	return first, err
+}
+
+// CountCommenters 查询 [from, to] 时间段内发送评论的人数，只发送了被标记为刷屏的评论的用户不计入
+func (d *DB) CountCommenters(oid uint64, from, to int64) (int, error) {
+	where, args := CommentQuery{oid: oid, from: from, to: to, noSpam: true}.where()
+	var count int
+	err := d.conn.QueryRow("select count(distinct uid) from comment"+where, args...).Scan(&count)
+	return count, err
+}
+
+// MinuteCount 一分钟内的评论数
+type MinuteCount struct {
+	minute int64 //该分钟开始的时间戳，单位秒
+	count  int   //评论数
+}
+
+// CommentsPerMinute 查询 [from, to] 时间段内每分钟的评论数，没有评论的分钟不会出现在结果中
+func (d *DB) CommentsPerMinute(oid uint64, from, to int64) ([]MinuteCount, error) {
+	where, args := CommentQuery{oid: oid, from: from, to: to}.where()
+	rows, err := d.conn.Query(`select ctime / 60 * 60 as minute, count(*)
+from comment`+where+` group by minute order by minute`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []MinuteCount
+	for rows.Next() {
+		var m MinuteCount
+		if err = rows.Scan(&m.minute, &m.count); err != nil {
+			return result, err
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
+
+// FollowerRecord 粉丝数记录
+type FollowerRecord struct {
+	uid   uint64 //账号对应的uid
+	ctime int64  //对应的时间点，时间戳形式，单位秒
+	fans  int    //粉丝数
+}
+
+// FollowerHistory 获取 [from, to] 时间段内的粉丝数记录，按时间升序
+func (d *DB) FollowerHistory(uid uint64, from, to int64) ([]FollowerRecord, error) {
+	rows, err := d.conn.Query(`select uid, ctime, fans from follower
+where uid = ? and ctime between ? and ? order by ctime`, uid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []FollowerRecord
+	for rows.Next() {
+		var r FollowerRecord
+		if err = rows.Scan(&r.uid, &r.ctime, &r.fans); err != nil {
+			return records, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+//处理控制台中的 history 命令，查看用户在评论区中最近发送的评论
+//history <uid> [n]
+func historyCmd(oid uint64, args []string) {
+	if len(args) == 0 {
+		mainLogger.Warn("usage: history <uid> [n]")
+		return
+	}
+	uid, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		mainLogger.Warn("错误的uid：%s", args[0])
+		return
+	}
+	n := 10
+	if len(args) > 1 {
+		if n, err = strconv.Atoi(args[1]); err != nil {
+			mainLogger.Warn("错误的数量：%s", args[1])
+			return
+		}
+	}
+	records, err := db.Comments(CommentQuery{oid: oid, uid: uid, limit: n, desc: true})
+	if err != nil {
+		mainLogger.Error("查询评论失败，%v", err)
+		return
+	}
+	mainLogger.Info("uid=%d 最近的%d条评论", uid, len(records))
+	for _, r := range records {
+		mainLogger.Info("[%s] %s：%s", time.Unix(int64(r.ctime), 0).Format("01-02 15:04:05"), r.uname, r.msg)
+	}
+}
+
+//处理控制台中的 top 命令，查看本次统计时段内发送评论最多的用户
+//top [n]
+func topCmd(oid uint64, from int64, args []string) {
+	n := 10
+	var err error
+	if len(args) > 0 {
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			mainLogger.Warn("错误的数量：%s", args[0])
+			return
+		}
+	}
+	top, err := db.TopCommenters(oid, from, 0, n)
+	if err != nil {
+		mainLogger.Error("查询评论失败，%v", err)
+		return
+	}
+	for i, c := range top {
+		mainLogger.Info("%d. %s(uid=%d)：%d条", i+1, c.uname, c.uid, c.count)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+)
+
+// Rebuild 使用数据库中保存的评论和统计数据，重新生成 [from, to] 时间段的数据总结。
+//评论按获取到的时间（like_time）筛选，并和运行时一样通过 Counter.Count 计数，被标记为刷屏的评论通过 Counter.CountSpam 计数；
+//数据库中没有保存评论区的总评论数，所以 Board 中的 StartAllCount 等字段为0
+func (d *DB) Rebuild(oid, uid uint64, from, to int64) (report.Summary, error) {
+	bot := &Bot{
+		board:   Board{oid: oid},
+		monitor: MonitorAccount{Account: Account{uid: uid}},
+		counter: newCounter("", time.Unix(from, 0)),
+	}
+	//评论区名称等信息从之前的数据总结中获取
+	if last, err := d.lastSummary(oid); err == nil {
+		bot.board.name = last.Board.Name
+		bot.board.dId = last.Board.DynamicId
+		bot.board.bvID = last.Board.BvID
+		bot.monitor.uname = last.Account.Name
+		bot.monitor.alias = last.Account.Alias
+		bot.statInterval = last.Account.StatInterval
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return report.Summary{}, err
+	}
+
+	if err := d.replayComments(bot.counter, oid, from, to); err != nil {
+		return report.Summary{}, err
+	}
+	endFollowers, err := d.replayFollowers(bot, from, to)
+	if err != nil {
+		return report.Summary{}, err
+	}
+	if err = d.replayAccountStats(bot.counter, uid, from, to); err != nil {
+		return report.Summary{}, err
+	}
+	if bot.counter.video, err = d.replayVideoStats(oid, from, to); err != nil {
+		return report.Summary{}, err
+	}
+
+	summary := bot.snapshot(bot.counter)
+	summary.End = to
+	summary.Account.EndFollowers = endFollowers
+	return summary, nil
+}
+
+//获取评论区最近的一条数据总结
+func (d *DB) lastSummary(oid uint64) (report.Summary, error) {
+	var (
+		summary report.Summary
+		data    string
+	)
+	err := d.conn.QueryRow("select data from summary where oid = ? order by end_time desc, id desc limit 1",
+		oid).Scan(&data)
+	if err != nil {
+		return summary, err
+	}
+	err = json.Unmarshal([]byte(data), &summary)
+	return summary, err
+}
+
+//按获取到评论的顺序重新计数，不包含补全历史评论时获取的评论
+func (d *DB) replayComments(counter *Counter, oid uint64, from, to int64) error {
+	rows, err := d.conn.Query("select "+commentColumns+` from comment
+where oid = ? and like_time between ? and ? and source = 'live' order by like_time, id`, oid, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanComment(rows.Scan)
+		if err != nil {
+			return err
+		}
+		if r.spam != "" {
+			counter.CountSpam(r.Comment, r.spam)
+			continue
+		}
+		first, err := d.FirstComment(oid, r.uid)
+		if err != nil {
+			return err
+		}
+		counter.Count(r.Comment, time.Unix(r.likeTime, 0), commenterHistory{checked: true, first: first})
+	}
+	return rows.Err()
+}
+
+//重新记录粉丝数变化，开始时的粉丝数为 from 之前最近的一条记录，没有时使用时间段内的第一条记录，
+//返回结束时的粉丝数
+func (d *DB) replayFollowers(bot *Bot, from, to int64) (int, error) {
+	uid := bot.monitor.uid
+	var start sql.NullInt64
+	err := d.conn.QueryRow("select fans from follower where uid = ? and ctime < ? order by ctime desc limit 1",
+		uid, from).Scan(&start)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	records, err := d.FollowerHistory(uid, from, to)
+	if err != nil {
+		return 0, err
+	}
+	//和运行时一样，粉丝数变化的第一项为开始时的粉丝数
+	switch {
+	case start.Valid:
+		bot.counter.startFollowers = int(start.Int64)
+		bot.counter.fansCount = append(bot.counter.fansCount, bot.counter.startFollowers)
+	case len(records) > 0:
+		bot.counter.startFollowers = records[0].fans
+	}
+	for _, r := range records {
+		bot.counter.fansCount = append(bot.counter.fansCount, r.fans)
+	}
+	if len(records) == 0 {
+		return bot.counter.startFollowers, nil
+	}
+	return records[len(records)-1].fans, nil
+}
+
+//重新记录账号的各项统计数据，开始时的数据为 from 之前最近一次记录的数据。
+//同一时间记录的数据为一次记录，和运行时一样，只获取到部分数据项的记录会被忽略
+func (d *DB) replayAccountStats(counter *Counter, uid uint64, from, to int64) error {
+	rows, err := d.conn.Query(`select ctime, metric, value, id from account_stat
+where uid = ? and ctime = (select max(ctime) from account_stat where uid = ? and ctime < ?)
+union all
+select ctime, metric, value, id from account_stat where uid = ? and ctime between ? and ?
+order by ctime, id`, uid, uid, from, uid, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var (
+		times   []int64
+		samples []map[string]int
+		metrics []string //出现过的数据项，按第一次出现的顺序
+		seen    = make(map[string]bool)
+	)
+	for rows.Next() {
+		var (
+			ctime, id int64
+			metric    string
+			value     int
+		)
+		if err = rows.Scan(&ctime, &metric, &value, &id); err != nil {
+			return err
+		}
+		if len(times) == 0 || times[len(times)-1] != ctime {
+			times = append(times, ctime)
+			samples = append(samples, make(map[string]int))
+		}
+		samples[len(samples)-1][metric] = value
+		if !seen[metric] {
+			seen[metric] = true
+			metrics = append(metrics, metric)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i, stats := range samples {
+		if hasStats(stats, metrics) {
+			counter.addStats(metrics, stats, times[i])
+		}
+	}
+	return nil
+}
+
+//重新记录视频数据，视频评论区的oid即为视频的av号，没有记录时返回nil。
+//数据库中没有保存是否进入热门和排行榜排名，对应的字段为零值
+func (d *DB) replayVideoStats(aid uint64, from, to int64) (*report.VideoSummary, error) {
+	//开始时的数据为 from 之前最近的一条记录
+	rows, err := d.conn.Query(`select * from (select bvid, ctime, view, danmaku, reply, favorite, coin, share, like
+from video_stat where aid = ? and ctime < ? order by ctime desc limit 1)
+union all
+select * from (select bvid, ctime, view, danmaku, reply, favorite, coin, share, like
+from video_stat where aid = ? and ctime between ? and ? order by ctime)`, aid, from, aid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		video    *report.VideoSummary
+		lastTime int64
+	)
+	for rows.Next() {
+		stat := VideoStat{aid: aid}
+		var ctime int64
+		err = rows.Scan(&stat.bvID, &ctime, &stat.view, &stat.danmaku, &stat.reply, &stat.favorite,
+			&stat.coin, &stat.share, &stat.like)
+		if err != nil {
+			return nil, err
+		}
+		if video == nil {
+			video = newVideoSummary(stat, 0)
+		} else {
+			if video.Interval == 0 {
+				video.Interval = int(ctime-lastTime) / 60
+			}
+			video.Add(stat.Metrics(), 0, float64(ctime-lastTime)/60)
+		}
+		lastTime = ctime
+	}
+	return video, rows.Err()
+}
+
+//使用数据库中的数据重新生成数据总结
+func rebuildCmd(args []string) int {
+	fs := flag.NewFlagSet("rebuild", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名")
+	oid := fs.Uint64("board", 0, "评论区的oid")
+	uid := fs.Uint64("uid", settingValue("account.uid").Uint(), "监控的账号uid，默认读取 setting.json")
+	from := fs.String("from", "", "开始时间，例如：2022-07-01 07:33")
+	to := fs.String("to", "", "结束时间，格式同 from")
+	output := fs.String("o", "-", "输出文件，为 - 时输出到标准输出")
+	save := fs.Bool("save", false, "同时保存到数据库的 summary 表中")
+	window := fs.String("window", "", "对应的统计时段名称，用于和该统计时段的上一个数据总结比较词语，以及保存到数据库中")
+	_ = fs.Parse(args)
+
+	start, err := parseTime(*from, false)
+	if err != nil || start == 0 {
+		mainLogger.Error("错误的开始时间：%s", *from)
+		return 2
+	}
+	end, err := parseTime(*to, true)
+	if err != nil || end == 0 {
+		mainLogger.Error("错误的结束时间：%s", *to)
+		return 2
+	}
+	if *oid == 0 {
+		mainLogger.Error("需要指定评论区的oid")
+		return 2
+	}
+	d := NewDB(*dbname, DBOption{})
+	if d == nil {
+		return 1
+	}
+	defer d.Close()
+	summary, err := d.Rebuild(*oid, *uid, start, end)
+	if err != nil {
+		mainLogger.Error("重新生成数据总结失败，%v", err)
+		return 1
+	}
+	summary.Window = *window
+	d.addTrending(&summary)
+	data, _ := json.Marshal(summary)
+	if *output == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+	} else {
+		err = writeFileAtomic(*output, data)
+	}
+	if err != nil {
+		mainLogger.Error("保存数据总结失败，%v", err)
+		return 1
+	}
+	if *save {
+		file := *output
+		if file == "-" {
+			file = ""
+		}
+		d.InsertSummary(summary, file, false)
+	}
+	mainLogger.Info("评论数：%d，参与人数：%d，粉丝数：%d => %d", summary.Board.Count, len(summary.Board.People),
+		summary.Account.StartFollowers, summary.Account.EndFollowers)
+	return 0
+}
@@ -0,0 +1,240 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//聚合报告的周期
+const (
+	PeriodWeek  = "week"  //周报，从星期一开始
+	PeriodMonth = "month" //月报，从每月1日开始
+)
+
+//星期的名称，星期一为0
+var weekdayNames = []string{"星期一", "星期二", "星期三", "星期四", "星期五", "星期六", "星期日"}
+
+// PeriodRange t 之前最近一个完整周期的时间范围 [start, end)，使用 t 的时区
+func PeriodRange(period string, t time.Time) (start, end time.Time, err error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch period {
+	case PeriodWeek:
+		end = day.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+		return end.AddDate(0, 0, -7), end, nil
+	case PeriodMonth:
+		end = day.AddDate(0, 0, 1-t.Day())
+		return end.AddDate(0, -1, 0), end, nil
+	default:
+		return start, end, fmt.Errorf("不支持的周期：%s，可选：%s，%s", period, PeriodWeek, PeriodMonth)
+	}
+}
+
+// RankedCommenter 评论数排行中的用户
+type RankedCommenter struct {
+	Uid   uint64 //用户的uid
+	Name  string //最近一次记录的用户名
+	Count int    //发送的评论数
+}
+
+// FansPoint 某个时间点的粉丝数
+type FansPoint struct {
+	Time time.Time
+	Fans int
+}
+
+// Aggregate 一个周期内的聚合报告，评论数相关的数据由多个数据总结合并得到，
+//发送评论的人数，评论数排行和粉丝数变化从数据库中读取
+type Aggregate struct {
+	Period      string    //周期，week 或 month
+	Start       time.Time //周期的开始时间
+	End         time.Time //周期的结束时间，不包含
+	BoardName   string    //评论区名称
+	AccountName string    //账号的用户名
+	Summaries   int       //合并的数据总结数量
+
+	Comments     int        //记录到的评论数
+	Daily        []int      //每天的评论数
+	PeakDay      time.Time  //评论数最多的一天，有多个时为最早的一天
+	PeakDayCount int        //评论数最多的一天的评论数
+	PeakHot      int        //每分钟评论数的最大值，即最高同接
+	PeakTime     time.Time  //评论数最多的一分钟的开始时间
+	Heatmap      [7][24]int //每个星期几、每个小时内的评论数，星期一为0
+
+	People    int               //发送评论的人数
+	Top       []RankedCommenter //评论数最多的用户
+	Followers Change            //粉丝数变化
+	Fans      []FansPoint       //粉丝数变化曲线
+
+	Previous *Aggregate //上一个周期的数据，用于比较，没有数据时为nil
+}
+
+// NewAggregate 合并 [start, end) 内的每分钟评论数，只统计该时间范围内的分钟，
+//多个数据总结包含同一分钟时取最大值，避免重叠的数据总结（例如中断时生成的数据总结）被重复统计
+func NewAggregate(period string, start, end time.Time, summaries []Summary) *Aggregate {
+	a := &Aggregate{Period: period, Start: start, End: end, Summaries: len(summaries)}
+	loc := start.Location()
+	minutes := make(map[int64]int)
+	for _, s := range summaries {
+		if s.Board.Name != "" {
+			a.BoardName = s.Board.Name
+		}
+		if s.Account.Name != "" {
+			a.AccountName = s.Account.Name
+		}
+		for i, hot := range s.Board.Hot {
+			m := s.Start/60*60 + int64(i)*60
+			if m < start.Unix() || m >= end.Unix() {
+				continue
+			}
+			if hot > minutes[m] {
+				minutes[m] = hot
+			}
+		}
+	}
+
+	days := make(map[string]int)
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days[d.Format("2006-01-02")] = len(a.Daily)
+		a.Daily = append(a.Daily, 0)
+	}
+	for m, hot := range minutes {
+		t := time.Unix(m, 0).In(loc)
+		a.Comments += hot
+		a.Daily[days[t.Format("2006-01-02")]] += hot
+		a.Heatmap[(int(t.Weekday())+6)%7][t.Hour()] += hot
+		if hot > a.PeakHot || hot == a.PeakHot && t.Before(a.PeakTime) {
+			a.PeakHot, a.PeakTime = hot, t
+		}
+	}
+	for i, count := range a.Daily {
+		if count > a.PeakDayCount {
+			a.PeakDayCount, a.PeakDay = count, start.AddDate(0, 0, i)
+		}
+	}
+	if a.PeakDay.IsZero() {
+		a.PeakDay = start
+	}
+	if a.PeakTime.IsZero() {
+		a.PeakTime = start
+	}
+	return a
+}
+
+// PeriodName 周期的名称，周或月
+func (a *Aggregate) PeriodName() string {
+	if a.Period == PeriodMonth {
+		return "月"
+	}
+	return "周"
+}
+
+// LastDay 周期的最后一天
+func (a *Aggregate) LastDay() time.Time {
+	return a.End.AddDate(0, 0, -1)
+}
+
+// TopN 评论数最多的 n 个用户
+func (a *Aggregate) TopN(n int) []RankedCommenter {
+	if n > len(a.Top) {
+		n = len(a.Top)
+	}
+	return a.Top[:n]
+}
+
+// BusiestHour 评论数最多的星期几和小时，例如：星期六 21时，没有评论时返回空字符串
+func (a *Aggregate) BusiestHour() string {
+	day, hour, max := 0, 0, 0
+	for d, hours := range a.Heatmap {
+		for h, count := range hours {
+			if count > max {
+				day, hour, max = d, h, count
+			}
+		}
+	}
+	if max == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s %d时", weekdayNames[day], hour)
+}
+
+// DefaultAggregateTemplate 默认的周报和月报模板
+const DefaultAggregateTemplate = `【{{.PeriodName}}报】{{date .Start "01月02日"}}-{{date .LastDay "01月02日"}}
+【{{.AccountName}}】粉丝数变化：{{change .Followers}}
+【{{.BoardName}}】记录到的评论数：{{.Comments}}{{with .Previous}}，上{{$.PeriodName}}：{{.Comments}}（{{percent $.Comments .Comments}}）{{end}}
+发送评论人数：{{.People}}{{with .Previous}}，上{{$.PeriodName}}：{{.People}}（{{percent $.People .People}}）{{end}}
+评论最多的一天：{{date .PeakDay "01月02日"}}，{{.PeakDayCount}}条
+{{date .PeakTime "01-02 15:04"}} 达到最高同接：{{.PeakHot}}条/分钟
+{{with .BusiestHour}}最活跃的时段：{{.}}
+{{end}}评论数排行：
+{{range $i, $c := .TopN 20}}{{add $i 1}}. {{$c.Name}}：{{$c.Count}}条
+{{end}}`
+
+// ParseAggregateTemplate 解析周报和月报的模板，text 为空时使用默认模板。
+//模板中的数据为 Aggregate，可以使用的函数和数据总结的模板相同
+func ParseAggregateTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultAggregateTemplate
+	}
+	return template.New("aggregate").Funcs(funcs).Parse(text)
+}
+
+// Text 使用模板生成报告的文字，会去掉末尾的换行
+func (a *Aggregate) Text(tmpl *template.Template) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, a); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+//图表的名称，周报和月报的图表保存在单独的目录中
+const (
+	ChartDaily   = "daily"   //每天的评论数
+	ChartHeatmap = "heatmap" //每个星期几、每个小时内的评论数
+)
+
+// AggregateChartNames 周报和月报的所有图表的名称，发布动态时按这个顺序上传图片
+var AggregateChartNames = []string{ChartDaily, ChartFans, ChartHeatmap}
+
+// AggregateCharts 根据周报或月报生成图表，图表的标题可以使用 opt.Titles 配置
+func AggregateCharts(a *Aggregate, opt ChartOption) []Chart {
+	timeRange := fmt.Sprintf("%s - %s", a.Start.Format("01-02"), a.LastDay().Format("01-02"))
+	daily := Chart{
+		Name:   ChartDaily,
+		Title:  opt.title(ChartDaily, "%s 每天的评论数", timeRange),
+		XLabel: "日期", YLabel: "评论数",
+		Bar: true,
+	}
+	for i, count := range a.Daily {
+		daily.Labels = append(daily.Labels, a.Start.AddDate(0, 0, i).Format("01-02"))
+		daily.Values = append(daily.Values, float64(count))
+	}
+	fans := Chart{
+		Name:   ChartFans,
+		Title:  opt.title(ChartFans, "%s 粉丝数变化", timeRange),
+		XLabel: "时间", YLabel: "粉丝数",
+	}
+	for _, p := range a.Fans {
+		fans.Labels = append(fans.Labels, p.Time.In(a.Start.Location()).Format("01-02 15:04"))
+		fans.Values = append(fans.Values, float64(p.Fans))
+	}
+	heatmap := Chart{
+		Name:   ChartHeatmap,
+		Title:  opt.title(ChartHeatmap, "%s 每小时的评论数", timeRange),
+		XLabel: "小时", YLabel: "星期",
+		YLabels: weekdayNames,
+	}
+	for h := 0; h < 24; h++ {
+		heatmap.Labels = append(heatmap.Labels, fmt.Sprintf("%d", h))
+	}
+	for _, hours := range a.Heatmap {
+		row := make([]float64, len(hours))
+		for h, count := range hours {
+			row[h] = float64(count)
+		}
+		heatmap.Heatmap = append(heatmap.Heatmap, row)
+	}
+	return []Chart{daily, fans, heatmap}
+}
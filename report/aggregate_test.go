@@ -0,0 +1,159 @@
+package report
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPeriodRange(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	}
+	tests := []struct {
+		period     string
+		t          time.Time
+		start, end time.Time
+	}{
+		{PeriodWeek, time.Date(2022, 7, 13, 10, 0, 0, 0, time.Local), day(2022, 7, 4), day(2022, 7, 11)},
+		//星期一的0点生成上一周的报告
+		{PeriodWeek, day(2022, 7, 11), day(2022, 7, 4), day(2022, 7, 11)},
+		{PeriodWeek, time.Date(2022, 7, 10, 23, 0, 0, 0, time.Local), day(2022, 6, 27), day(2022, 7, 4)},
+		{PeriodMonth, day(2022, 3, 1), day(2022, 2, 1), day(2022, 3, 1)},
+		{PeriodMonth, time.Date(2022, 1, 15, 8, 0, 0, 0, time.Local), day(2021, 12, 1), day(2022, 1, 1)},
+	}
+	for _, tt := range tests {
+		start, end, err := PeriodRange(tt.period, tt.t)
+		if err != nil || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s %v: want [%v, %v), got [%v, %v), %v", tt.period, tt.t, tt.start, tt.end, start, end, err)
+		}
+	}
+	if _, _, err := PeriodRange("year", time.Now()); err == nil {
+		t.Error("want error for unknown period")
+	}
+}
+
+func testAggregate() *Aggregate {
+	at := func(d, h, m int) int64 {
+		return time.Date(2022, 7, d, h, m, 0, 0, time.Local).Unix()
+	}
+	var a, b, c Summary
+	a.Start, a.Board.Hot = at(4, 23, 58), []int{1, 2, 3, 4}
+	a.Board.Name, a.Account.Name = "啵版", "三三"
+	//和 a 重叠的中断时的数据总结
+	b.Start, b.Board.Hot = at(5, 0, 0)+20, []int{5, 1}
+	//只有最后一分钟在周期内
+	c.Start, c.Board.Hot = at(3, 23, 59), []int{7, 2}
+	start := time.Date(2022, 7, 4, 0, 0, 0, 0, time.Local)
+	return NewAggregate(PeriodWeek, start, start.AddDate(0, 0, 7), []Summary{c, a, b})
+}
+
+func TestNewAggregate(t *testing.T) {
+	a := testAggregate()
+	if a.Summaries != 3 || a.BoardName != "啵版" || a.AccountName != "三三" {
+		t.Errorf("got summaries %d, names %q %q", a.Summaries, a.BoardName, a.AccountName)
+	}
+	if want := []int{5, 9, 0, 0, 0, 0, 0}; a.Comments != 14 || !reflect.DeepEqual(a.Daily, want) {
+		t.Errorf("want 14 comments, daily %v, got %d, %v", want, a.Comments, a.Daily)
+	}
+	if a.PeakHot != 5 || !a.PeakTime.Equal(time.Date(2022, 7, 5, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("peak: got %d at %v", a.PeakHot, a.PeakTime)
+	}
+	if a.PeakDayCount != 9 || a.PeakDay.Day() != 5 {
+		t.Errorf("peak day: got %d at %v", a.PeakDayCount, a.PeakDay)
+	}
+	if a.Heatmap[0][0] != 2 || a.Heatmap[0][23] != 3 || a.Heatmap[1][0] != 9 {
+		t.Errorf("heatmap: got %v, %v", a.Heatmap[0], a.Heatmap[1])
+	}
+	if got := a.BusiestHour(); got != "星期二 0时" {
+		t.Errorf("busiest hour: got %q", got)
+	}
+	if got := a.LastDay(); got.Day() != 10 {
+		t.Errorf("last day: got %v", got)
+	}
+
+	empty := NewAggregate(PeriodMonth, a.Start, a.Start.AddDate(0, 1, 0), nil)
+	if len(empty.Daily) != 31 || empty.Comments != 0 || !empty.PeakTime.Equal(a.Start) || empty.BusiestHour() != "" {
+		t.Errorf("empty: got %+v", empty)
+	}
+}
+
+func TestAggregate_Text(t *testing.T) {
+	a := testAggregate()
+	a.People = 3
+	a.Followers = Change{Start: 100, End: 120}
+	a.Top = []RankedCommenter{{1, "a", 8}, {2, "b", 4}}
+	a.Previous = &Aggregate{Comments: 10, People: 0}
+	tmpl, err := ParseAggregateTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := a.Text(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"【周报】07月04日-07月10日",
+		"记录到的评论数：14，上周：10（+40.0%）",
+		"发送评论人数：3，上周：0（-）",
+		"评论最多的一天：07月05日，9条",
+		"最活跃的时段：星期二 0时",
+		"1. a：8条\n2. b：4条",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("want %q in:\n%s", want, text)
+		}
+	}
+	if strings.HasSuffix(text, "\n") {
+		t.Error("text should not end with newline")
+	}
+	if _, err = ParseAggregateTemplate("{{.Comments"); err == nil {
+		t.Error("want error for invalid template")
+	}
+}
+
+func TestAggregateCharts(t *testing.T) {
+	a := testAggregate()
+	a.Fans = []FansPoint{{a.Start, 100}, {a.Start.Add(time.Hour), 110}}
+	charts := AggregateCharts(a, ChartOption{Titles: map[string]string{ChartHeatmap: "热力图 %s"}})
+	if len(charts) != len(AggregateChartNames) {
+		t.Fatalf("want %d charts, got %d", len(AggregateChartNames), len(charts))
+	}
+	for i, c := range charts {
+		if c.Name != AggregateChartNames[i] {
+			t.Errorf("name: want %s, got %s", AggregateChartNames[i], c.Name)
+		}
+	}
+	if got := charts[0].Values; !reflect.DeepEqual(got, []float64{5, 9, 0, 0, 0, 0, 0}) || charts[0].Labels[1] != "07-05" {
+		t.Errorf("daily: got %v, %v", got, charts[0].Labels)
+	}
+	if got := charts[1].Values; !reflect.DeepEqual(got, []float64{100, 110}) {
+		t.Errorf("fans: got %v", got)
+	}
+	heatmap := charts[2]
+	if heatmap.Title != "热力图 07-04 - 07-10" || len(heatmap.Heatmap) != 7 || len(heatmap.Heatmap[0]) != 24 ||
+		heatmap.Heatmap[1][0] != 9 {
+		t.Errorf("heatmap: got %q, %v", heatmap.Title, heatmap.Heatmap)
+	}
+
+	r, err := NewChartRenderer(ChartOption{Width: 320, Height: 180})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range charts {
+		var buf bytes.Buffer
+		if err = r.PNG(&buf, c); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = png.Decode(&buf); err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+		}
+		buf.Reset()
+		if err = r.SVG(&buf, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/vector"
+)
+
+type point struct {
+	x, y float64
+}
+
+//路径中的一个操作，kind 和SVG中的命令相同：M，L，Q，C
+type pathOp struct {
+	kind   byte
+	points []point
+}
+
+//由多个闭合的子路径组成的路径
+type path []pathOp
+
+//矩形对应的路径
+func rectPath(x, y, w, h float64) path {
+	return polygonPath([]point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}})
+}
+
+//多边形对应的路径
+func polygonPath(points []point) path {
+	p := make(path, 0, len(points))
+	for i, pt := range points {
+		kind := byte('L')
+		if i == 0 {
+			kind = 'M'
+		}
+		p = append(p, pathOp{kind: kind, points: []point{pt}})
+	}
+	return p
+}
+
+//画布，PNG和SVG使用相同的绘制逻辑
+type canvas interface {
+	//填充路径
+	fill(p path, c color.NRGBA)
+	//绘制折线，width 为线宽
+	stroke(points []point, width float64, c color.NRGBA)
+	//写入图片
+	encode(w io.Writer) error
+}
+
+//绘制PNG图片
+type pngCanvas struct {
+	img *image.RGBA
+	r   *vector.Rasterizer
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	return &pngCanvas{
+		img: image.NewRGBA(image.Rect(0, 0, width, height)),
+		r:   vector.NewRasterizer(width, height),
+	}
+}
+
+//只在路径所在的区域内光栅化，避免每次都处理整张图片
+func (c *pngCanvas) fill(p path, col color.NRGBA) {
+	if len(p) == 0 {
+		return
+	}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, op := range p {
+		for _, pt := range op.points {
+			minX, minY = math.Min(minX, pt.x), math.Min(minY, pt.y)
+			maxX, maxY = math.Max(maxX, pt.x), math.Max(maxY, pt.y)
+		}
+	}
+	area := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(c.img.Bounds())
+	if area.Empty() {
+		return
+	}
+	ox, oy := float32(area.Min.X), float32(area.Min.Y)
+	c.r.Reset(area.Dx(), area.Dy())
+	for i, op := range p {
+		pts := op.points
+		switch op.kind {
+		case 'M':
+			if i > 0 {
+				c.r.ClosePath()
+			}
+			c.r.MoveTo(float32(pts[0].x)-ox, float32(pts[0].y)-oy)
+		case 'L':
+			c.r.LineTo(float32(pts[0].x)-ox, float32(pts[0].y)-oy)
+		case 'Q':
+			c.r.QuadTo(float32(pts[0].x)-ox, float32(pts[0].y)-oy, float32(pts[1].x)-ox, float32(pts[1].y)-oy)
+		case 'C':
+			c.r.CubeTo(float32(pts[0].x)-ox, float32(pts[0].y)-oy, float32(pts[1].x)-ox, float32(pts[1].y)-oy,
+				float32(pts[2].x)-ox, float32(pts[2].y)-oy)
+		}
+	}
+	c.r.ClosePath()
+	c.r.Draw(c.img, area, image.NewUniform(col), image.Point{})
+}
+
+//将折线的每一段转换为矩形，并在转折处补上正方形，使线段之间没有缺口。
+//光栅化时方向相反的路径会相互抵消，因此所有矩形都使用相同的方向
+func (c *pngCanvas) stroke(points []point, width float64, col color.NRGBA) {
+	var p path
+	half := width / 2
+	for i, pt := range points {
+		if i > 0 {
+			prev := points[i-1]
+			dx, dy := pt.x-prev.x, pt.y-prev.y
+			l := math.Hypot(dx, dy)
+			if l == 0 {
+				continue
+			}
+			nx, ny := -dy/l*half, dx/l*half
+			p = append(p, polygonPath([]point{
+				{prev.x + nx, prev.y + ny}, {pt.x + nx, pt.y + ny}, {pt.x - nx, pt.y - ny}, {prev.x - nx, prev.y - ny},
+			})...)
+		}
+		if i > 0 && i < len(points)-1 {
+			p = append(p, polygonPath([]point{
+				{pt.x - half, pt.y + half}, {pt.x + half, pt.y + half}, {pt.x + half, pt.y - half}, {pt.x - half, pt.y - half},
+			})...)
+		}
+	}
+	c.fill(p, col)
+}
+
+func (c *pngCanvas) encode(w io.Writer) error {
+	return png.Encode(w, c.img)
+}
+
+//绘制SVG图片
+type svgCanvas struct {
+	width, height int
+	sb            strings.Builder
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	return &svgCanvas{width: width, height: height}
+}
+
+//保留两位小数，减小文件体积
+func svgNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+//SVG中的颜色和透明度属性
+func svgColor(attr string, c color.NRGBA) string {
+	s := fmt.Sprintf(`%s="#%02x%02x%02x"`, attr, c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf(` %s-opacity="%s"`, attr, svgNum(float64(c.A)/0xff))
+	}
+	return s
+}
+
+func (c *svgCanvas) fill(p path, col color.NRGBA) {
+	if len(p) == 0 {
+		return
+	}
+	c.sb.WriteString(`<path d="`)
+	for i, op := range p {
+		if op.kind == 'M' && i > 0 {
+			c.sb.WriteString("Z")
+		}
+		c.sb.WriteByte(op.kind)
+		for j, pt := range op.points {
+			if j > 0 {
+				c.sb.WriteByte(' ')
+			}
+			c.sb.WriteString(svgNum(pt.x))
+			c.sb.WriteByte(',')
+			c.sb.WriteString(svgNum(pt.y))
+		}
+	}
+	c.sb.WriteString(`Z" `)
+	c.sb.WriteString(svgColor("fill", col))
+	c.sb.WriteString("/>\n")
+}
+
+func (c *svgCanvas) stroke(points []point, width float64, col color.NRGBA) {
+	if len(points) < 2 {
+		return
+	}
+	c.sb.WriteString(`<polyline points="`)
+	for i, pt := range points {
+		if i > 0 {
+			c.sb.WriteByte(' ')
+		}
+		c.sb.WriteString(svgNum(pt.x))
+		c.sb.WriteByte(',')
+		c.sb.WriteString(svgNum(pt.y))
+	}
+	c.sb.WriteString(`" fill="none" stroke-width="`)
+	c.sb.WriteString(svgNum(width))
+	c.sb.WriteString(`" stroke-linejoin="round" `)
+	c.sb.WriteString(svgColor("stroke", col))
+	c.sb.WriteString("/>\n")
+}
+
+func (c *svgCanvas) encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		c.width, c.height, c.width, c.height)
+	_, _ = bw.WriteString(c.sb.String())
+	_, _ = bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
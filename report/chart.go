@@ -0,0 +1,445 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//图表的名称，同时也是保存时的文件名
+const (
+	ChartHot         = "hot"          //每段时间内的总评论数
+	ChartFans        = "fans"         //粉丝数变化
+	ChartDelayMean   = "delay_mean"   //每段时间内的平均延迟
+	ChartDelayMedian = "delay_median" //每段时间内的延迟中位数
+)
+
+// ChartNames 所有图表的名称，发布动态时按这个顺序上传图片
+var ChartNames = []string{ChartHot, ChartFans, ChartDelayMean, ChartDelayMedian}
+
+// ChartOption 绘制图表的配置，零值表示使用默认值
+type ChartOption struct {
+	Bucket int               //聚合数据的时长，单位：分钟，默认为10，即每10分钟的数据聚合为一个点
+	Width  int               //图片的宽度，默认为1600
+	Height int               //图片的高度，默认为900
+	Titles map[string]string //图表的标题，键为图表名称，标题中的 %s 会替换为统计时段，例如：07-01 - 07-02
+	Font   []byte            //字体文件的内容，支持ttf和otf，为空时使用内置字体
+}
+
+// Chart 一张图表，横轴为时间
+type Chart struct {
+	Name   string    //图表名称
+	Title  string    //标题
+	XLabel string    //横轴名称
+	YLabel string    //纵轴名称
+	Labels []string  //横轴上每个点对应的标签
+	Values []float64 //纵轴上的值
+	Bar    bool      //是否为柱状图，否则为折线图
+	Fill   bool      //折线图是否填充折线下方的区域
+
+	Heatmap [][]float64 //热力图的数据，第 i 行对应纵轴的第 i 个标签，不为空时绘制热力图，Values 无效
+	YLabels []string    //热力图纵轴上每一行对应的标签
+}
+
+// Gather 数据聚合，每 step 个数据通过 fn 聚合为一个，末尾不足 step 个的数据会被舍弃
+func Gather(data []float64, step int, fn func([]float64) float64) []float64 {
+	if step <= 0 {
+		return nil
+	}
+	result := make([]float64, 0, len(data)/step)
+	for i := 0; i+step <= len(data); i += step {
+		result = append(result, fn(data[i:i+step]))
+	}
+	return result
+}
+
+// Sum 求和
+func Sum(data []float64) float64 {
+	sum := 0.0
+	for _, v := range data {
+		sum += v
+	}
+	return sum
+}
+
+// Mean 平均数，data 为空时返回0
+func Mean(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	return Sum(data) / float64(len(data))
+}
+
+// Median 中位数，data 为空时返回0
+func Median(data []float64) float64 {
+	n := len(data)
+	if n == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+//转换为浮点数，并在末尾填充数据，使长度为 step 的整数倍
+func padded(data []int, step int, pad func([]float64) float64) []float64 {
+	result := make([]float64, len(data), len(data)+step)
+	for i, v := range data {
+		result[i] = float64(v)
+	}
+	for len(result)%step != 0 {
+		result = append(result, pad(result))
+	}
+	return result
+}
+
+//合并每 step 分钟内的延迟分布，返回每段时间内延迟的平均数和中位数，
+//没有评论的时间段使用上一段时间的值
+func latencyBuckets(latency []Latency, step int) (mean, median []float64) {
+	for i := 0; i < len(latency); i += step {
+		end := i + step
+		if end > len(latency) {
+			end = len(latency)
+		}
+		l := MergeLatency(latency[i:end])
+		switch {
+		case l.Count > 0:
+			mean, median = append(mean, l.Mean), append(median, l.P50)
+		case len(mean) > 0:
+			mean, median = append(mean, mean[len(mean)-1]), append(median, median[len(median)-1])
+		default:
+			mean, median = append(mean, 0), append(median, 0)
+		}
+	}
+	return mean, median
+}
+
+//聚合时长对应的文字，用于默认标题
+func bucketText(bucket int) string {
+	if bucket == 10 {
+		return "十分钟"
+	}
+	return strconv.Itoa(bucket) + "分钟"
+}
+
+func (opt ChartOption) withDefault() ChartOption {
+	if opt.Bucket <= 0 {
+		opt.Bucket = 10
+	}
+	if opt.Width <= 0 {
+		opt.Width = 1600
+	}
+	if opt.Height <= 0 {
+		opt.Height = 900
+	}
+	return opt
+}
+
+//图表的标题，没有配置时使用 def
+func (opt ChartOption) title(name, def, timeRange string) string {
+	if t, ok := opt.Titles[name]; ok && t != "" {
+		def = t
+	}
+	return fmt.Sprintf(def, timeRange)
+}
+
+// Charts 根据数据总结生成图表，每分钟的评论数和延迟按 opt.Bucket 聚合，
+//评论数取总和，延迟合并每分钟的延迟分布后分别取平均数和中位数。延迟会受到个别极端值的影响，因此同时生成两张图表
+func Charts(s Summary, opt ChartOption) []Chart {
+	opt = opt.withDefault()
+	step := opt.Bucket
+	start := time.Unix(s.Start, 0)
+	timeRange := fmt.Sprintf("%s - %s", start.Format("01-02"), time.Unix(s.End, 0).Format("01-02"))
+	//每个点对应的时间，interval 为两个点之间的间隔，单位：分钟
+	labels := func(n, interval int) []string {
+		result := make([]string, n)
+		for i := range result {
+			result[i] = start.Add(time.Duration(i*interval) * time.Minute).Format("15:04")
+		}
+		return result
+	}
+
+	//评论数不足时补0，延迟不足时使用最后一个值补齐
+	hot := Gather(padded(s.Board.Hot, step, func([]float64) float64 { return 0 }), step, Sum)
+	last := func(data []float64) float64 { return data[len(data)-1] }
+	var delayMean, delayMedian []float64
+	if len(s.Board.Latency) > 0 {
+		delayMean, delayMedian = latencyBuckets(s.Board.Latency, step)
+	} else if len(s.Board.Awl) > 0 {
+		//旧版本的数据总结中只有每分钟的最大延迟
+		delay := padded(s.Board.Awl, step, last)
+		delayMean = Gather(delay, step, Mean)
+		delayMedian = Gather(delay, step, Median)
+	}
+	fans := make([]float64, len(s.Account.FansCount))
+	for i, v := range s.Account.FansCount {
+		fans[i] = float64(v)
+	}
+	fansInterval := s.Account.StatInterval
+	if fansInterval <= 0 {
+		fansInterval = step
+	}
+
+	bucket := bucketText(step)
+	return []Chart{
+		{
+			Name:   ChartHot,
+			Title:  opt.title(ChartHot, "%s "+bucket+"内总评论数", timeRange),
+			XLabel: "时间", YLabel: "评论数",
+			Labels: labels(len(hot), step), Values: hot,
+			Bar: true,
+		},
+		{
+			Name:   ChartFans,
+			Title:  opt.title(ChartFans, "%s 粉丝数变化", timeRange),
+			XLabel: "时间", YLabel: "粉丝数",
+			Labels: labels(len(fans), fansInterval), Values: fans,
+		},
+		{
+			Name:   ChartDelayMean,
+			Title:  opt.title(ChartDelayMean, "%s "+bucket+"内平均延迟（单位：秒）", timeRange),
+			XLabel: "时间", YLabel: "平均延迟",
+			Labels: labels(len(delayMean), step), Values: delayMean,
+			Fill: true,
+		},
+		{
+			Name:   ChartDelayMedian,
+			Title:  opt.title(ChartDelayMedian, "%s "+bucket+"内延迟中位数（单位：秒）", timeRange),
+			XLabel: "时间", YLabel: "延迟中位数",
+			Labels: labels(len(delayMedian), step), Values: delayMedian,
+			Fill: true,
+		},
+	}
+}
+
+//图表使用的颜色，和 seaborn 的默认样式相近
+var (
+	colorBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	colorPlot       = color.NRGBA{R: 0xea, G: 0xea, B: 0xf2, A: 0xff}
+	colorGrid       = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	colorText       = color.NRGBA{R: 0x26, G: 0x26, B: 0x26, A: 0xff}
+	colorLine       = color.NRGBA{R: 0x4c, G: 0x72, B: 0xb0, A: 0xff}
+	colorFill       = color.NRGBA{R: 0x87, G: 0xce, B: 0xeb, A: 0x66}
+)
+
+// ChartRenderer 将图表绘制为PNG或SVG图片
+type ChartRenderer struct {
+	opt  ChartOption
+	font *textFont
+}
+
+// NewChartRenderer 创建图表的绘制器，字体文件无法解析时返回错误
+func NewChartRenderer(opt ChartOption) (*ChartRenderer, error) {
+	opt = opt.withDefault()
+	f, err := parseFont(opt.Font)
+	if err != nil {
+		return nil, err
+	}
+	return &ChartRenderer{opt: opt, font: f}, nil
+}
+
+//纵轴的刻度，和之前 matplotlib 绘制的图表相同：最大值加10作为上限，
+//最小值小于50时从0开始，最多15个刻度
+func yTicks(values []float64) []float64 {
+	yMax, yMin := values[0], values[0]
+	for _, v := range values {
+		yMax = math.Max(yMax, v)
+		yMin = math.Min(yMin, v)
+	}
+	yMax += 10
+	if yMin < 50 {
+		yMin = 0
+	}
+	step := 1.0
+	if yMax-yMin > 15 {
+		step = math.Ceil((yMax - yMin) / 15)
+	}
+	end := math.Floor(yMax + step)
+	ticks := make([]float64, 0, 17)
+	for v := yMin; v < end; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+//刻度上的数字
+func tickText(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+//在画布上绘制图表
+func (r *ChartRenderer) draw(c canvas, chart Chart) {
+	w, h := float64(r.opt.Width), float64(r.opt.Height)
+	scale := h / 900
+	tickSize, labelSize, titleSize := 14*scale, 16*scale, 20*scale
+	left, right, top, bottom := 100*scale, w-40*scale, 60*scale, h-110*scale
+
+	c.fill(rectPath(0, 0, w, h), colorBackground)
+	c.fill(rectPath(left, top, right-left, bottom-top), colorPlot)
+	c.fill(r.font.path(chart.Title, titleSize, (left+right)/2, top-20*scale, 0, anchorMiddle), colorText)
+	c.fill(r.font.path(chart.XLabel, labelSize, (left+right)/2, h-15*scale, 0, anchorMiddle), colorText)
+	c.fill(r.font.path(chart.YLabel, labelSize, 30*scale, (top+bottom)/2, 90, anchorMiddle), colorText)
+	if len(chart.Heatmap) > 0 {
+		r.drawHeatmap(c, chart, left, right, top, bottom, scale)
+		return
+	}
+	n := len(chart.Values)
+	if n == 0 {
+		c.fill(r.font.path("暂无数据", titleSize, (left+right)/2, (top+bottom)/2, 0, anchorMiddle), colorText)
+		return
+	}
+
+	ticks := yTicks(chart.Values)
+	low, high := ticks[0], ticks[len(ticks)-1]
+	if high == low {
+		high = low + 1
+	}
+	y := func(v float64) float64 {
+		return bottom - (v-low)/(high-low)*(bottom-top)
+	}
+	for _, t := range ticks {
+		ty := y(t)
+		c.stroke([]point{{left, ty}, {right, ty}}, scale, colorGrid)
+		c.fill(r.font.path(tickText(t), tickSize, left-8*scale, ty+tickSize/3, 0, anchorEnd), colorText)
+	}
+
+	//每个点位于对应区间的中间，超过24个点时只显示部分标签，并旋转标签避免重叠
+	slot := (right - left) / float64(n)
+	x := func(i int) float64 {
+		return left + (float64(i)+0.5)*slot
+	}
+	labelStep, angle := 1, 0.0
+	if n > 24 {
+		labelStep, angle = int(math.Ceil(float64(n)/24)), 33
+	}
+	for i := 0; i < n && i < len(chart.Labels); i += labelStep {
+		tx := x(i)
+		c.stroke([]point{{tx, top}, {tx, bottom}}, scale, colorGrid)
+		if angle == 0 {
+			c.fill(r.font.path(chart.Labels[i], tickSize, tx, bottom+tickSize+8*scale, 0, anchorMiddle), colorText)
+		} else {
+			c.fill(r.font.path(chart.Labels[i], tickSize, tx+tickSize/3, bottom+tickSize+4*scale, angle, anchorEnd),
+				colorText)
+		}
+	}
+
+	if chart.Bar {
+		width := slot * 0.8
+		var bars path
+		for i, v := range chart.Values {
+			bars = append(bars, rectPath(x(i)-width/2, y(v), width, y(low)-y(v))...)
+		}
+		c.fill(bars, colorLine)
+		return
+	}
+	line := make([]point, n)
+	for i, v := range chart.Values {
+		line[i] = point{x(i), y(v)}
+	}
+	if chart.Fill {
+		area := append([]point{{line[0].x, y(low)}}, line...)
+		area = append(area, point{line[n-1].x, y(low)})
+		c.fill(polygonPath(area), colorFill)
+	}
+	c.stroke(line, 2.5*scale, colorLine)
+}
+
+//绘制热力图，颜色越深表示值越大
+func (r *ChartRenderer) drawHeatmap(c canvas, chart Chart, left, right, top, bottom, scale float64) {
+	tickSize := 14 * scale
+	rows, cols := len(chart.Heatmap), 0
+	max := 0.0
+	for _, row := range chart.Heatmap {
+		if len(row) > cols {
+			cols = len(row)
+		}
+		for _, v := range row {
+			max = math.Max(max, v)
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	cellW, cellH := (right-left)/float64(cols), (bottom-top)/float64(rows)
+	for i, row := range chart.Heatmap {
+		y := top + float64(i)*cellH
+		for j, v := range row {
+			c.fill(rectPath(left+float64(j)*cellW, y, cellW, cellH), mixColor(colorPlot, colorLine, v/max))
+		}
+		if i < len(chart.YLabels) {
+			c.fill(r.font.path(chart.YLabels[i], tickSize, left-8*scale, y+cellH/2+tickSize/3, 0, anchorEnd),
+				colorText)
+		}
+	}
+	for j := 0; j < cols && j < len(chart.Labels); j++ {
+		c.fill(r.font.path(chart.Labels[j], tickSize, left+(float64(j)+0.5)*cellW, bottom+tickSize+8*scale, 0,
+			anchorMiddle), colorText)
+	}
+	//网格线
+	for i := 0; i <= rows; i++ {
+		y := top + float64(i)*cellH
+		c.stroke([]point{{left, y}, {right, y}}, scale, colorGrid)
+	}
+	for j := 0; j <= cols; j++ {
+		x := left + float64(j)*cellW
+		c.stroke([]point{{x, top}, {x, bottom}}, scale, colorGrid)
+	}
+}
+
+//按比例 t 混合两种颜色，t 为0时为 from，为1时为 to
+func mixColor(from, to color.NRGBA, t float64) color.NRGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return color.NRGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: mix(from.A, to.A)}
+}
+
+// PNG 将图表绘制为PNG图片
+func (r *ChartRenderer) PNG(w io.Writer, chart Chart) error {
+	c := newPNGCanvas(r.opt.Width, r.opt.Height)
+	r.draw(c, chart)
+	return c.encode(w)
+}
+
+// SVG 将图表绘制为SVG图片，文字会转换为路径，查看时不需要安装字体
+func (r *ChartRenderer) SVG(w io.Writer, chart Chart) error {
+	c := newSVGCanvas(r.opt.Width, r.opt.Height)
+	r.draw(c, chart)
+	return c.encode(w)
+}
+
+// Save 将图表保存到 dir 目录中，文件名为 图表名称.格式，format 可选：png，svg，返回保存的文件名
+func (r *ChartRenderer) Save(dir string, chart Chart, format string) (string, error) {
+	var render func(io.Writer, Chart) error
+	switch format {
+	case "png":
+		render = r.PNG
+	case "svg":
+		render = r.SVG
+	default:
+		return "", errors.New("不支持的图片格式：" + format)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, chart.Name+"."+format)
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	if err = render(f, chart); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	return name, f.Close()
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestGather(t *testing.T) {
+	data := []float64{1, 2, 3, 4, 5, 6, 7}
+	if got := Gather(data, 3, Sum); !reflect.DeepEqual(got, []float64{6, 15}) {
+		t.Errorf("sum: got %v", got)
+	}
+	if got := Gather(data, 2, Median); !reflect.DeepEqual(got, []float64{1.5, 3.5, 5.5}) {
+		t.Errorf("median: got %v", got)
+	}
+	if got := Median([]float64{5, 1, 3}); got != 3 {
+		t.Errorf("median: want 3, got %v", got)
+	}
+}
+
+func TestCharts(t *testing.T) {
+	s := testSummary()
+	s.Board.Hot = []int{1, 2, 3, 4, 5, 6, 7}
+	s.Board.Awl = []int{1, 9, 2, 4, 6}
+	s.Account.FansCount = []int{10, 11, 12}
+	s.Account.StatInterval = 10
+	charts := Charts(s, ChartOption{Bucket: 3, Titles: map[string]string{ChartFans: "粉丝 %s"}})
+	if len(charts) != len(ChartNames) {
+		t.Fatalf("want %d charts, got %d", len(ChartNames), len(charts))
+	}
+	//不足的评论数补0，不足的延迟使用最后一个值补齐
+	want := map[string][]float64{
+		ChartHot:         {6, 15, 7},
+		ChartFans:        {10, 11, 12},
+		ChartDelayMean:   {4, 16.0 / 3},
+		ChartDelayMedian: {2, 6},
+	}
+	for i, c := range charts {
+		if c.Name != ChartNames[i] {
+			t.Errorf("name: want %s, got %s", ChartNames[i], c.Name)
+		}
+		if !reflect.DeepEqual(c.Values, want[c.Name]) {
+			t.Errorf("%s: want %v, got %v", c.Name, want[c.Name], c.Values)
+		}
+	}
+	if got := charts[0].Labels; !reflect.DeepEqual(got, []string{"07:33", "07:36", "07:39"}) {
+		t.Errorf("hot labels: got %v", got)
+	}
+	if got := charts[1].Labels; !reflect.DeepEqual(got, []string{"07:33", "07:43", "07:53"}) {
+		t.Errorf("fans labels: got %v", got)
+	}
+	if charts[0].Title != "07-01 - 07-02 3分钟内总评论数" || charts[1].Title != "粉丝 07-01 - 07-02" {
+		t.Errorf("titles: got %q, %q", charts[0].Title, charts[1].Title)
+	}
+}
+
+func TestCharts_Latency(t *testing.T) {
+	s := testSummary()
+	s.Board.Latency = make([]Latency, 5)
+	for i, delays := range [][]int{{1, 9}, {2}, {}, {}, {}} {
+		for _, d := range delays {
+			s.Board.Latency[i].Add(d)
+		}
+	}
+	//合并每段时间内的延迟分布，没有评论的时间段使用上一段时间的值
+	charts := Charts(s, ChartOption{Bucket: 2})
+	if got := charts[2].Values; !reflect.DeepEqual(got, []float64{4, 4, 4}) {
+		t.Errorf("mean: got %v", got)
+	}
+	if got := charts[3].Values; !reflect.DeepEqual(got, []float64{2, 2, 2}) {
+		t.Errorf("median: got %v", got)
+	}
+}
+
+func TestYTicks(t *testing.T) {
+	//上限为最大值加10，最小值小于50时从0开始，最多15个间隔
+	if got := yTicks([]float64{3, 8}); !reflect.DeepEqual(got, []float64{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}) {
+		t.Errorf("got %v", got)
+	}
+	got := yTicks([]float64{100, 400})
+	if got[0] != 100 || got[1] != 121 || got[len(got)-1] != 415 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestChartRenderer(t *testing.T) {
+	r, err := NewChartRenderer(ChartOption{Width: 320, Height: 180})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testSummary()
+	s.Board.Awl = []int{1, 2, 3}
+	for _, c := range Charts(s, ChartOption{Bucket: 1}) {
+		var buf bytes.Buffer
+		if err = r.PNG(&buf, c); err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 180 {
+			t.Errorf("%s: size %v", c.Name, b)
+		}
+		buf.Reset()
+		if err = r.SVG(&buf, c); err != nil {
+			t.Fatal(err)
+		}
+		dec := xml.NewDecoder(&buf)
+		for {
+			if _, err = dec.Token(); err != nil {
+				break
+			}
+		}
+		if err != io.EOF {
+			t.Errorf("%s: invalid svg, %v", c.Name, err)
+		}
+	}
+	if _, err = NewChartRenderer(ChartOption{Font: []byte("not a font")}); err == nil {
+		t.Error("want error for invalid font")
+	}
+}
@@ -0,0 +1,113 @@
+package report
+
+import (
+	_ "embed"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+//内置字体，文泉驿微米黑的子集，包含ASCII、常用标点以及GB2312中的字符，见 fonts/README.md
+//
+//go:embed fonts/wqy-microhei-subset.ttf
+var defaultFont []byte
+
+//绘制文字使用的字体，文字会转换为路径后绘制，因此PNG和SVG中的文字相同，查看SVG时不需要安装字体
+type textFont struct {
+	font *sfnt.Font
+	buf  sfnt.Buffer
+}
+
+//解析字体文件，data 为空时使用内置字体
+func parseFont(data []byte) (*textFont, error) {
+	if len(data) == 0 {
+		data = defaultFont
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &textFont{font: f}, nil
+}
+
+//文字的宽度，size 为字体大小，单位：像素
+func (f *textFont) width(s string, size float64) float64 {
+	ppem := fixed.Int26_6(size * 64)
+	var w fixed.Int26_6
+	for _, r := range s {
+		idx, err := f.font.GlyphIndex(&f.buf, r)
+		if err != nil {
+			continue
+		}
+		adv, err := f.font.GlyphAdvance(&f.buf, idx, ppem, font.HintingNone)
+		if err == nil {
+			w += adv
+		}
+	}
+	return float64(w) / 64
+}
+
+//文字的对齐方式
+type anchor int
+
+const (
+	anchorStart  anchor = iota //(x, y) 为文字的开始位置
+	anchorMiddle               //(x, y) 为文字的中间位置
+	anchorEnd                  //(x, y) 为文字的结束位置
+)
+
+//将文字转换为路径，(x, y) 为基线上的点，angle 为逆时针旋转的角度，单位：度
+func (f *textFont) path(s string, size, x, y, angle float64, a anchor) path {
+	ppem := fixed.Int26_6(size * 64)
+	offset := 0.0
+	switch a {
+	case anchorMiddle:
+		offset = -f.width(s, size) / 2
+	case anchorEnd:
+		offset = -f.width(s, size)
+	}
+	sin, cos := math.Sincos(-angle * math.Pi / 180)
+	//将字形中的点旋转后平移到 (x, y)
+	transform := func(p fixed.Point26_6, dx float64) point {
+		px, py := float64(p.X)/64+dx, float64(p.Y)/64
+		return point{x + px*cos - py*sin, y + px*sin + py*cos}
+	}
+
+	var p path
+	for _, r := range s {
+		idx, err := f.font.GlyphIndex(&f.buf, r)
+		if err != nil {
+			continue
+		}
+		segments, err := f.font.LoadGlyph(&f.buf, idx, ppem, nil)
+		if err != nil {
+			continue
+		}
+		for _, seg := range segments {
+			op := pathOp{}
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				op.kind = 'M'
+				op.points = []point{transform(seg.Args[0], offset)}
+			case sfnt.SegmentOpLineTo:
+				op.kind = 'L'
+				op.points = []point{transform(seg.Args[0], offset)}
+			case sfnt.SegmentOpQuadTo:
+				op.kind = 'Q'
+				op.points = []point{transform(seg.Args[0], offset), transform(seg.Args[1], offset)}
+			case sfnt.SegmentOpCubeTo:
+				op.kind = 'C'
+				op.points = []point{transform(seg.Args[0], offset), transform(seg.Args[1], offset),
+					transform(seg.Args[2], offset)}
+			}
+			p = append(p, op)
+		}
+		adv, err := f.font.GlyphAdvance(&f.buf, idx, ppem, font.HintingNone)
+		if err == nil {
+			offset += float64(adv) / 64
+		}
+	}
+	return p
+}
@@ -0,0 +1,208 @@
+# encoding=UTF-8
+# 从文泉驿微米黑（wqy-microhei.ttc）中提取图表使用的字体子集，
+# 包含ASCII、Latin-1、常用标点以及GB2312中的所有字符，生成 wqy-microhei-subset.ttf
+# 用法：python subset.py wqy-microhei.ttc
+import struct
+import sys
+
+KEEP_TABLES = ['OS/2', 'cmap', 'cvt ', 'fpgm', 'gasp', 'glyf', 'head', 'hhea', 'hmtx', 'loca', 'maxp', 'name', 'post',
+               'prep']
+
+
+# 需要保留的字符
+def charset():
+    chars = set(range(0x20, 0x7f)) | set(range(0xa0, 0x100))
+    chars |= set(range(0x2000, 0x2070)) | set(range(0x3000, 0x3040)) | set(range(0xff00, 0xfff0))
+    for hi in range(0xa1, 0xf8):
+        for lo in range(0xa1, 0xff):
+            try:
+                chars.add(ord(bytes([hi, lo]).decode('gb2312')))
+            except UnicodeDecodeError:
+                pass
+    return chars
+
+
+def read_tables(data, offset):
+    num = struct.unpack('>H', data[offset + 4:offset + 6])[0]
+    tables = {}
+    for i in range(num):
+        tag, _, off, length = struct.unpack('>4sIII', data[offset + 12 + 16 * i:offset + 28 + 16 * i])
+        tables[tag.decode('latin-1')] = data[off:off + length]
+    return tables
+
+
+# 读取 cmap 中 format 4 和 format 12 的子表，返回字符到字形的映射
+def read_cmap(cmap):
+    result = {}
+    num = struct.unpack('>H', cmap[2:4])[0]
+    for i in range(num):
+        pid, eid, off = struct.unpack('>HHI', cmap[4 + 8 * i:12 + 8 * i])
+        fmt = struct.unpack('>H', cmap[off:off + 2])[0]
+        if fmt == 4:
+            seg = struct.unpack('>H', cmap[off + 6:off + 8])[0] // 2
+            base = off + 14
+            ends = struct.unpack('>%dH' % seg, cmap[base:base + 2 * seg])
+            starts = struct.unpack('>%dH' % seg, cmap[base + 2 * seg + 2:base + 4 * seg + 2])
+            deltas = struct.unpack('>%dh' % seg, cmap[base + 4 * seg + 2:base + 6 * seg + 2])
+            ro_base = base + 6 * seg + 2
+            offsets = struct.unpack('>%dH' % seg, cmap[ro_base:ro_base + 2 * seg])
+            for s in range(seg):
+                for c in range(starts[s], ends[s] + 1):
+                    if c == 0xffff:
+                        continue
+                    if offsets[s] == 0:
+                        gid = (c + deltas[s]) & 0xffff
+                    else:
+                        p = ro_base + 2 * s + offsets[s] + 2 * (c - starts[s])
+                        gid = struct.unpack('>H', cmap[p:p + 2])[0]
+                        if gid != 0:
+                            gid = (gid + deltas[s]) & 0xffff
+                    if gid != 0:
+                        result.setdefault(c, gid)
+        elif fmt == 12:
+            groups = struct.unpack('>I', cmap[off + 12:off + 16])[0]
+            for g in range(groups):
+                start, end, gid = struct.unpack('>III', cmap[off + 16 + 12 * g:off + 28 + 12 * g])
+                for c in range(start, end + 1):
+                    result.setdefault(c, gid + c - start)
+    return result
+
+
+def glyph_data(tables, long_loca, gid):
+    loca = tables['loca']
+    if long_loca:
+        start, end = struct.unpack('>II', loca[4 * gid:4 * gid + 8])
+    else:
+        start, end = struct.unpack('>HH', loca[2 * gid:2 * gid + 4])
+        start, end = start * 2, end * 2
+    return tables['glyf'][start:end]
+
+
+# 复合字形引用的字形，以及每个字形id在数据中的位置
+def components(glyph):
+    result = []
+    if len(glyph) < 10 or struct.unpack('>h', glyph[:2])[0] >= 0:
+        return result
+    p = 10
+    while True:
+        flags, gid = struct.unpack('>HH', glyph[p:p + 4])
+        result.append((p + 2, gid))
+        p += 4
+        p += 4 if flags & 0x1 else 2
+        if flags & 0x8:
+            p += 2
+        elif flags & 0x40:
+            p += 4
+        elif flags & 0x80:
+            p += 8
+        if not flags & 0x20:
+            return result
+
+
+def build_cmap(mapping):
+    codes = sorted(c for c in mapping if c < 0xffff)
+    segments = []
+    for c in codes:
+        if segments and segments[-1][1] == c - 1 and mapping[c] - c == mapping[segments[-1][0]] - segments[-1][0]:
+            segments[-1][1] = c
+        else:
+            segments.append([c, c])
+    segments.append([0xffff, 0xffff])
+    seg = len(segments)
+    search = 2 ** (seg.bit_length() - 1) * 2
+    ends = [e for _, e in segments]
+    starts = [s for s, _ in segments]
+    deltas = [(mapping[s] - s) & 0xffff if s != 0xffff else 1 for s, _ in segments]
+    body = struct.pack('>HHHH', seg * 2, search, (search // 2).bit_length() - 1, seg * 2 - search)
+    body += struct.pack('>%dH' % seg, *ends) + b'\0\0' + struct.pack('>%dH' % seg, *starts)
+    body += struct.pack('>%dH' % seg, *deltas) + struct.pack('>%dH' % seg, *([0] * seg))
+    sub = struct.pack('>HHH', 4, len(body) + 6, 0) + body
+    return struct.pack('>HHHHI', 0, 1, 3, 1, 12) + sub
+
+
+def checksum(data):
+    data += b'\0' * (-len(data) % 4)
+    return sum(struct.unpack('>%dI' % (len(data) // 4), data)) & 0xffffffff
+
+
+def main():
+    data = open(sys.argv[1], 'rb').read()
+    offset = 0
+    if data[:4] == b'ttcf':
+        offset = struct.unpack('>I', data[12:16])[0]
+    tables = read_tables(data, offset)
+    long_loca = struct.unpack('>h', tables['head'][50:52])[0] == 1
+    num_metrics = struct.unpack('>H', tables['hhea'][34:36])[0]
+    cmap = read_cmap(tables['cmap'])
+
+    # 按原字形id顺序重新编号，0号字形固定为 .notdef
+    keep = {0}
+    pending = [cmap[c] for c in charset() if c in cmap]
+    while pending:
+        gid = pending.pop()
+        if gid in keep:
+            continue
+        keep.add(gid)
+        pending.extend(g for _, g in components(glyph_data(tables, long_loca, gid)))
+    order = sorted(keep)
+    new_id = {g: i for i, g in enumerate(order)}
+
+    glyf, loca, hmtx = b'', [], b''
+    for gid in order:
+        glyph = bytearray(glyph_data(tables, long_loca, gid))
+        for p, g in components(bytes(glyph)):
+            struct.pack_into('>H', glyph, p, new_id[g])
+        glyph += b'\0' * (-len(glyph) % 4)
+        loca.append(len(glyf))
+        glyf += bytes(glyph)
+        m = min(gid, num_metrics - 1)
+        advance = struct.unpack('>H', tables['hmtx'][4 * m:4 * m + 2])[0]
+        if gid < num_metrics:
+            lsb = struct.unpack('>h', tables['hmtx'][4 * gid + 2:4 * gid + 4])[0]
+        else:
+            p = 4 * num_metrics + 2 * (gid - num_metrics)
+            lsb = struct.unpack('>h', tables['hmtx'][p:p + 2])[0]
+        hmtx += struct.pack('>Hh', advance, lsb)
+    loca.append(len(glyf))
+
+    mapping = {c: new_id[cmap[c]] for c in charset() if c in cmap}
+    tables['glyf'] = glyf
+    tables['loca'] = struct.pack('>%dI' % len(loca), *loca)
+    tables['hmtx'] = hmtx
+    head = bytearray(tables['head'])
+    struct.pack_into('>I', head, 8, 0)
+    struct.pack_into('>h', head, 50, 1)
+    tables['head'] = bytes(head)
+    hhea = bytearray(tables['hhea'])
+    struct.pack_into('>H', hhea, 34, len(order))
+    tables['hhea'] = bytes(hhea)
+    maxp = bytearray(tables['maxp'])
+    struct.pack_into('>H', maxp, 4, len(order))
+    tables['maxp'] = bytes(maxp)
+    tables['cmap'] = build_cmap(mapping)
+    # post 3.0 不包含字形名称
+    tables['post'] = struct.pack('>I', 0x30000) + tables['post'][4:32]
+
+    tags = [t for t in KEEP_TABLES if t in tables]
+    n = len(tags)
+    search = 2 ** (n.bit_length() - 1) * 16
+    out = struct.pack('>IHHHH', 0x10000, n, search, (search // 16).bit_length() - 1, n * 16 - search)
+    offset = 12 + 16 * n
+    directory, body = b'', b''
+    for tag in tags:
+        table = tables[tag]
+        directory += struct.pack('>4sIII', tag.encode('latin-1'), checksum(table), offset + len(body), len(table))
+        body += table + b'\0' * (-len(table) % 4)
+    font = bytearray(out + directory + body)
+    # 计算 head 中的 checkSumAdjustment
+    for i in range(n):
+        tag, _, off, _ = struct.unpack('>4sIII', font[12 + 16 * i:28 + 16 * i])
+        if tag == b'head':
+            struct.pack_into('>I', font, off + 8, (0xB1B0AFBA - checksum(bytes(font))) & 0xffffffff)
+    with open('wqy-microhei-subset.ttf', 'wb') as f:
+        f.write(font)
+    print('glyphs: %d, chars: %d, size: %d' % (len(order), len(mapping), len(font)))
+
+
+if __name__ == '__main__':
+    main()
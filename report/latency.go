@@ -0,0 +1,119 @@
+package report
+
+import "math"
+
+//延迟分布中每个区间的上限（包含），单位：秒，超过最后一个上限的延迟记录在最后一个区间中。
+//大部分延迟都在十秒以内，因此十秒以内每秒一个区间，百分位数是准确的，更大的延迟使用近似值
+var latencyBounds = []int{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 16, 18, 20, 25, 30, 40, 50, 60, 90, 120, 180, 240, 300,
+	600, 900, 1800, 3600, 7200, 21600, 86400,
+}
+
+//延迟所在的区间
+func latencyBucket(delay int) int {
+	for i, bound := range latencyBounds {
+		if delay <= bound {
+			return i
+		}
+	}
+	return len(latencyBounds)
+}
+
+// Latency 一段时间内获取到评论的延迟统计，单位：秒。
+//Hist 为延迟的分布，元素为每个区间内的评论数，末尾为0的区间会被省略，可以合并多段时间的统计
+type Latency struct {
+	Count int     `json:"count"` //评论数
+	Mean  float64 `json:"mean"`  //平均数
+	P50   float64 `json:"p50"`   //中位数
+	P90   float64 `json:"p90"`   //90百分位数
+	P99   float64 `json:"p99"`   //99百分位数
+	Max   int     `json:"max"`   //最大值
+	Sum   int     `json:"sum"`   //总和
+	Hist  []int   `json:"hist"`  //延迟的分布
+}
+
+// Add 记录一条评论的延迟
+func (l *Latency) Add(delay int) {
+	if delay < 0 {
+		delay = 0
+	}
+	i := latencyBucket(delay)
+	for len(l.Hist) <= i {
+		l.Hist = append(l.Hist, 0)
+	}
+	l.Hist[i]++
+	l.Count++
+	l.Sum += delay
+	if delay > l.Max {
+		l.Max = delay
+	}
+	l.update()
+}
+
+// Merge 合并另一段时间的统计
+func (l *Latency) Merge(o Latency) {
+	for len(l.Hist) < len(o.Hist) {
+		l.Hist = append(l.Hist, 0)
+	}
+	for i, n := range o.Hist {
+		l.Hist[i] += n
+	}
+	l.Count += o.Count
+	l.Sum += o.Sum
+	if o.Max > l.Max {
+		l.Max = o.Max
+	}
+	l.update()
+}
+
+//更新平均数和百分位数
+func (l *Latency) update() {
+	if l.Count == 0 {
+		return
+	}
+	l.Mean = math.Round(float64(l.Sum)/float64(l.Count)*100) / 100
+	l.P50 = l.Percentile(50)
+	l.P90 = l.Percentile(90)
+	l.P99 = l.Percentile(99)
+}
+
+// Percentile 第 p 百分位数（0 < p <= 100），使用最近秩方法，
+//所在区间包含多个值时按排名在区间内线性插值，结果不会超过最大值
+func (l *Latency) Percentile(p float64) float64 {
+	if l.Count == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(l.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for i, n := range l.Hist {
+		if seen+n < rank {
+			seen += n
+			continue
+		}
+		lower, upper := 0.0, float64(l.Max)
+		if i > 0 {
+			lower = float64(latencyBounds[i-1] + 1)
+		}
+		if i < len(latencyBounds) && float64(latencyBounds[i]) < upper {
+			upper = float64(latencyBounds[i])
+		}
+		if upper <= lower {
+			return math.Min(lower, float64(l.Max))
+		}
+		v := lower + (upper-lower)*float64(rank-seen)/float64(n)
+		return math.Round(v*100) / 100
+	}
+	return float64(l.Max)
+}
+
+// MergeLatency 合并多段时间的统计
+func MergeLatency(ls []Latency) Latency {
+	var total Latency
+	for _, l := range ls {
+		total.Merge(l)
+	}
+	return total
+}
@@ -0,0 +1,47 @@
+package report
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLatency(t *testing.T) {
+	var l Latency
+	for _, d := range []int{1, 2, 2, 3, 4, 5, 6, 7, 8, 100} {
+		l.Add(d)
+	}
+	if l.Count != 10 || l.Sum != 138 || l.Max != 100 || l.Mean != 13.8 {
+		t.Errorf("got count=%d, sum=%d, max=%d, mean=%v", l.Count, l.Sum, l.Max, l.Mean)
+	}
+	//十秒以内的百分位数是准确的
+	if l.P50 != 4 || l.P90 != 8 || l.P99 != 100 {
+		t.Errorf("got p50=%v, p90=%v, p99=%v", l.P50, l.P90, l.P99)
+	}
+
+	//超过十秒的延迟使用区间内的近似值，不会超过最大值
+	var large Latency
+	for _, d := range []int{31, 35, 38} {
+		large.Add(d)
+	}
+	if p := large.Percentile(50); p < 31 || p > 38 {
+		t.Errorf("large p50: got %v", p)
+	}
+	if p := large.Percentile(100); p != 38 {
+		t.Errorf("large p100: want 38, got %v", p)
+	}
+
+	total := MergeLatency([]Latency{l, large, {}})
+	if total.Count != 13 || total.Max != 100 || total.Sum != 242 {
+		t.Errorf("merge: got count=%d, max=%d, sum=%d", total.Count, total.Max, total.Sum)
+	}
+	if total.P50 != 6 {
+		t.Errorf("merge p50: want 6, got %v", total.P50)
+	}
+
+	data, _ := json.Marshal(total)
+	var decoded Latency
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, total) {
+		t.Errorf("json: got %+v, %v", decoded, err)
+	}
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"sort"
+	"time"
+)
+
+// Commenter 发送评论的用户
+type Commenter struct {
+	Uid   uint64 //用户的uid
+	Count int    //发送的评论数
+}
+
+// Change 某项数据在统计时段内的变化
+type Change struct {
+	Start int //开始时的值
+	End   int //结束时的值
+}
+
+// Delta 变化量
+func (c Change) Delta() int {
+	return c.End - c.Start
+}
+
+// Report 根据数据总结计算出的各项指标，用于生成数据总结的文字
+type Report struct {
+	Start       time.Time //统计的开始时间
+	End         time.Time //统计的结束时间
+	BoardName   string    //评论区名称
+	AccountName string    //账号的用户名
+
+	Followers Change //粉丝数变化
+	AllCount  Change //总评论数变化，包含楼中楼
+	Count     Change //评论数变化，不包含楼中楼
+
+	PeakHot  int       //每分钟评论数的最大值，即最高同接
+	PeakTime time.Time //评论数最多的一分钟的开始时间，有多个时为最早的一个
+
+	Latency Latency      //获取到评论的延迟统计，单位：秒
+	Stats   CommentStats //评论的其他统计数据，旧版本的数据总结中为空
+	Spam    SpamStats    //被标记为刷屏的评论
+
+	Recorded   int         //记录到的评论数
+	People     int         //发送评论的人数
+	Commenters []Commenter //发送评论的用户，按评论数降序排列，评论数相同时按uid升序排列
+}
+
+// New 计算数据总结中的各项指标
+func New(s Summary) *Report {
+	r := &Report{
+		Start:       time.Unix(s.Start, 0),
+		End:         time.Unix(s.End, 0),
+		BoardName:   s.Board.Name,
+		AccountName: s.Account.Name,
+		Followers:   Change{s.Account.StartFollowers, s.Account.EndFollowers},
+		AllCount:    Change{s.Board.StartAllCount, s.Board.EndAllCount},
+		Count:       Change{s.Board.StartCount, s.Board.EndCount},
+		Recorded:    s.Board.Count,
+		People:      len(s.Board.People),
+		Latency:     s.Board.TotalLatency,
+		Stats:       s.Board.Stats,
+		Spam:        s.Board.Spam,
+	}
+	peak := 0
+	for i, hot := range s.Board.Hot {
+		if hot > r.PeakHot {
+			r.PeakHot, peak = hot, i
+		}
+	}
+	r.PeakTime = r.Start.Add(time.Duration(peak) * time.Minute)
+
+	r.Commenters = make([]Commenter, 0, len(s.Board.People))
+	for uid, count := range s.Board.People {
+		r.Commenters = append(r.Commenters, Commenter{Uid: uid, Count: count})
+	}
+	sort.Slice(r.Commenters, func(i, j int) bool {
+		a, b := r.Commenters[i], r.Commenters[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Uid < b.Uid
+	})
+	return r
+}
+
+// Top 发送评论最多的用户，没有用户发送评论时返回零值
+func (r *Report) Top() Commenter {
+	if len(r.Commenters) == 0 {
+		return Commenter{}
+	}
+	return r.Commenters[0]
+}
+
+// TopN 发送评论最多的 n 个用户
+func (r *Report) TopN(n int) []Commenter {
+	if n > len(r.Commenters) {
+		n = len(r.Commenters)
+	}
+	return r.Commenters[:n]
+}
@@ -0,0 +1,68 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testSummary() Summary {
+	var s Summary
+	s.Start = time.Date(2022, 7, 1, 7, 33, 0, 0, time.Local).Unix()
+	s.End = time.Date(2022, 7, 2, 7, 33, 0, 0, time.Local).Unix()
+	s.Board.Name = "啵版"
+	s.Board.Hot = []int{3, 8, 2, 8}
+	s.Board.People = map[uint64]int{3: 5, 1: 2, 2: 5}
+	s.Board.Count = 12
+	s.Board.StartAllCount, s.Board.EndAllCount = 1000, 1050
+	s.Board.StartCount, s.Board.EndCount = 800, 812
+	s.Account.Name = "三三"
+	s.Account.StartFollowers, s.Account.EndFollowers = 10000, 9990
+	return s
+}
+
+func TestNew(t *testing.T) {
+	r := New(testSummary())
+	if r.PeakHot != 8 || !r.PeakTime.Equal(r.Start.Add(time.Minute)) {
+		t.Errorf("peak: got %d at %v", r.PeakHot, r.PeakTime)
+	}
+	want := []Commenter{{2, 5}, {3, 5}, {1, 2}}
+	if !reflect.DeepEqual(r.Commenters, want) {
+		t.Errorf("commenters: want %v, got %v", want, r.Commenters)
+	}
+	if r.Top() != want[0] || len(r.TopN(10)) != 3 {
+		t.Errorf("top: got %v, %v", r.Top(), r.TopN(10))
+	}
+	if r.Followers.Delta() != -10 || r.People != 3 {
+		t.Errorf("got followers delta %d, people %d", r.Followers.Delta(), r.People)
+	}
+}
+
+func TestReport_Text(t *testing.T) {
+	tmpl, err := ParseTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := New(testSummary()).Text(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `【数据总结】07月01日-07月02日
+【三三】粉丝数变化：10000 => 9990(-10)
+【啵版】评论数变化：1000 => 1050(+50)
+不含楼中楼评论数：800 => 812(+12)
+07-01 07:34 达到最高同接：8条/分钟
+发送评论人数：3
+单个账号最多发送评论：5 条`
+	if text != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, text)
+	}
+
+	tmpl, err = ParseTemplate("{{.BoardName}} {{signed .Count.Delta}}\n{{range .TopN 2}}{{.Uid}}:{{.Count}} {{end}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, err = New(testSummary()).Text(tmpl); err != nil || text != "啵版 +12\n2:5 3:5 " {
+		t.Errorf("custom template: got %q, err=%v", text, err)
+	}
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/Hami-Lemon/bobo-bot/util"
+)
+
+//评论被标记为刷屏的原因，一条评论有多个原因时使用逗号分隔
+const (
+	SpamDuplicate = "duplicate" //短时间内大量相似的评论（复读）
+	SpamRate      = "rate"      //同一个用户短时间内发送的评论过多
+)
+
+// SpamStats 统计时段内被标记为刷屏的评论，这些评论不计入评论数、每分钟的评论数和参与评论的人数等数据
+type SpamStats struct {
+	Count     int            `json:"count"`     //被标记的评论数
+	Duplicate int            `json:"duplicate"` //重复的评论数
+	Rate      int            `json:"rate"`      //发送频率异常的评论数
+	Hot       []int          `json:"hot"`       //每分钟内被标记的评论数，按评论的发送时间统计
+	People    map[uint64]int `json:"people"`    //发送被标记的评论的用户，键为uid，值为评论数
+}
+
+// Add 记录一条被标记的评论，reason 为标记的原因，minute 为距离统计开始时间的分钟数，小于0时不计入 Hot
+func (s *SpamStats) Add(reason string, minute int, uid uint64) {
+	s.Count++
+	for _, r := range strings.Split(reason, ",") {
+		switch r {
+		case SpamDuplicate:
+			s.Duplicate++
+		case SpamRate:
+			s.Rate++
+		}
+	}
+	if minute >= 0 {
+		var hot int
+		s.Hot, hot = util.SliceGet(s.Hot, minute)
+		s.Hot = util.SliceSet(s.Hot, minute, hot+1)
+	}
+	if s.People == nil {
+		s.People = make(map[uint64]int)
+	}
+	s.People[uid]++
+}
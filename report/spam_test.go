@@ -0,0 +1,36 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpamStats_Add(t *testing.T) {
+	var s SpamStats
+	s.Add(SpamDuplicate, 2, 1)
+	s.Add(SpamDuplicate+","+SpamRate, 0, 1)
+	//统计开始之前发送的评论不计入 Hot
+	s.Add(SpamRate, -1, 2)
+	want := SpamStats{Count: 3, Duplicate: 2, Rate: 2, Hot: []int{1, 0, 1}, People: map[uint64]int{1: 2, 2: 1}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("want %+v, got %+v", want, s)
+	}
+}
+
+func TestReport_TextSpam(t *testing.T) {
+	tmpl, err := ParseTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testSummary()
+	s.Board.Spam.Add(SpamDuplicate, 0, 1)
+	s.Board.Spam.Add(SpamRate, 0, 2)
+	text, err := New(s).Text(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "条\n刷屏的评论：2 条（复读：1，频率异常：1），未计入以上数据"; !strings.HasSuffix(text, want) {
+		t.Errorf("want suffix %q, got:\n%s", want, text)
+	}
+}
@@ -0,0 +1,147 @@
+package report
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+//评论长度分布中每个区间的上限（包含），单位：字符，超过最后一个上限的记录在最后一个区间中
+var lengthBounds = []int{5, 10, 20, 50, 100, 200, 500}
+
+// LengthStats 评论长度的统计，长度为评论内容的字符数
+type LengthStats struct {
+	Count int     `json:"count"` //评论数
+	Sum   int     `json:"sum"`   //总长度
+	Mean  float64 `json:"mean"`  //平均长度
+	Max   int     `json:"max"`   //最大长度
+	Hist  []int   `json:"hist"`  //长度的分布，区间的上限为：5，10，20，50，100，200，500
+}
+
+// Add 记录一条评论的长度
+func (l *LengthStats) Add(length int) {
+	i := len(lengthBounds)
+	for j, bound := range lengthBounds {
+		if length <= bound {
+			i = j
+			break
+		}
+	}
+	for len(l.Hist) <= i {
+		l.Hist = append(l.Hist, 0)
+	}
+	l.Hist[i]++
+	l.Count++
+	l.Sum += length
+	if length > l.Max {
+		l.Max = length
+	}
+	l.Mean = math.Round(float64(l.Sum)/float64(l.Count)*100) / 100
+}
+
+// CommentStats 统计时段内评论的其他统计数据
+type CommentStats struct {
+	MinuteUsers    []int          `json:"minuteUsers"`    //每分钟内发送评论的人数，按评论的发送时间统计
+	HourUsers      []int          `json:"hourUsers"`      //每小时内发送评论的人数
+	NewUsers       int            `json:"newUsers"`       //第一次在评论区发送评论的人数
+	ReturningUsers int            `json:"returningUsers"` //统计时段之前在评论区发送过评论的人数
+	Locations      map[string]int `json:"locations"`      //ip归属地的分布，键为归属地，值为评论数
+	Length         LengthStats    `json:"length"`         //评论的长度
+	Emotes         map[string]int `json:"emotes"`         //表情的使用次数，键为表情，例如：[doge]，😂
+	EmoteComments  int            `json:"emoteComments"`  //包含表情的评论数
+	Words          map[string]int `json:"words"`          //词语出现的评论数，数据总结中只保存出现次数最多的词语
+	Bigrams        map[string]int `json:"bigrams"`        //相邻两个词语组成的词组出现的评论数
+	Trending       []Trend        `json:"trending"`       //和上一个统计时段相比出现次数增长最快的词语和词组
+}
+
+// NewCommentStats 创建空的统计数据
+func NewCommentStats() CommentStats {
+	return CommentStats{
+		Locations: make(map[string]int),
+		Emotes:    make(map[string]int),
+		Words:     make(map[string]int),
+		Bigrams:   make(map[string]int),
+	}
+}
+
+//b站的表情，例如：[doge]，[tv_微笑]
+var emoteRegexp = regexp.MustCompile(`\[[^\[\]\s]{1,16}]`)
+
+//是否为emoji，只判断常用的范围
+func isEmoji(r rune) bool {
+	return r >= 0x1f000 && r <= 0x1faff || r >= 0x2600 && r <= 0x27bf
+}
+
+// ParseEmotes 评论中使用的表情，包括b站的表情和emoji，按出现的顺序返回
+func ParseEmotes(msg string) []string {
+	var emotes []string
+	last := 0
+	//emoji 和b站表情按位置合并
+	for _, loc := range emoteRegexp.FindAllStringIndex(msg, -1) {
+		emotes = appendEmoji(emotes, msg[last:loc[0]])
+		emotes = append(emotes, msg[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	return appendEmoji(emotes, msg[last:])
+}
+
+func appendEmoji(emotes []string, s string) []string {
+	for _, r := range s {
+		if isEmoji(r) {
+			emotes = append(emotes, string(r))
+		}
+	}
+	return emotes
+}
+
+// AddComment 记录评论的内容和ip归属地，没有归属地时记为“未知”，并统计评论中的词语
+func (s *CommentStats) AddComment(msg, location string) {
+	if location == "" {
+		location = "未知"
+	}
+	s.Locations[location]++
+	s.Length.Add(utf8.RuneCountInString(msg))
+	emotes := ParseEmotes(msg)
+	if len(emotes) > 0 {
+		s.EmoteComments++
+	}
+	for _, e := range emotes {
+		s.Emotes[e]++
+	}
+	s.addWords(msg)
+}
+
+// Ranked 排行中的一项
+type Ranked struct {
+	Name  string
+	Count int
+}
+
+//按数量降序排列，数量相同时按名称升序排列，返回前 n 项，n 小于等于0时返回全部
+func rank(m map[string]int, n int) []Ranked {
+	items := make([]Ranked, 0, len(m))
+	for name, count := range m {
+		items = append(items, Ranked{name, count})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Name < items[j].Name
+	})
+	if n > 0 && n < len(items) {
+		items = items[:n]
+	}
+	return items
+}
+
+// TopLocations 评论数最多的 n 个ip归属地
+func (s CommentStats) TopLocations(n int) []Ranked {
+	return rank(s.Locations, n)
+}
+
+// TopEmotes 使用次数最多的 n 个表情
+func (s CommentStats) TopEmotes(n int) []Ranked {
+	return rank(s.Emotes, n)
+}
@@ -0,0 +1,45 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEmotes(t *testing.T) {
+	got := ParseEmotes("好耶😂[doge][tv_微笑] [不是 表情]👍")
+	if want := []string{"😂", "[doge]", "[tv_微笑]", "👍"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got = ParseEmotes("没有表情"); len(got) != 0 {
+		t.Errorf("want empty, got %v", got)
+	}
+}
+
+func TestCommentStats(t *testing.T) {
+	s := NewCommentStats()
+	s.AddComment("[doge][doge]", "上海")
+	s.AddComment("你好", "")
+	s.AddComment("这是一条比较长的评论，超过了十个字", "北京")
+	s.AddComment("😂", "上海")
+	if s.Length.Count != 4 || s.Length.Max != 17 || s.Length.Mean != 8 {
+		t.Errorf("length: got %+v", s.Length)
+	}
+	//长度为12，2，17，1，区间上限为5，10，20
+	if !reflect.DeepEqual(s.Length.Hist, []int{2, 0, 2}) {
+		t.Errorf("length hist: got %v", s.Length.Hist)
+	}
+	if s.EmoteComments != 2 || s.Emotes["[doge]"] != 2 || s.Emotes["😂"] != 1 {
+		t.Errorf("emotes: got %v, %d", s.Emotes, s.EmoteComments)
+	}
+	want := []Ranked{{"上海", 2}, {"北京", 1}}
+	if got := s.TopLocations(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("locations: want %v, got %v", want, got)
+	}
+	if got := s.TopLocations(0); len(got) != 3 || got[2].Name != "未知" {
+		t.Errorf("locations: got %v", got)
+	}
+	//旧版本的数据总结中没有统计数据
+	if got := (CommentStats{}).TopEmotes(3); len(got) != 0 {
+		t.Errorf("emotes: got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+// Package report
+//数据总结的格式，以及根据数据总结生成报告
+package report
+
+// Summary 统计时段内的数据总结，程序运行时定时生成，保存为json文件和数据库中的 summary 表
+type Summary struct {
+	Version string `json:"version"` //对应程序的版本号
+	Start   int64  `json:"start"`   //统计的开始时间
+	End     int64  `json:"end"`     //统计结束时间
+	Window  string `json:"window"`  //对应的统计时段名称，只有一个统计时段时为空
+	Board   struct {
+		Name          string         `json:"name"`          //版聊区名称
+		DynamicId     uint64         `json:"dynamicId"`     //对应的动态id
+		BvID          string         `json:"bvID"`          //如果是视频评论区，则是对应视频的bv号，否则为空
+		Oid           uint64         `json:"oid"`           //oid
+		Hot           []int          `json:"hot"`           //每分钟内的评论数
+		Awl           []int          `json:"awl"`           //每分钟内的最大延迟
+		Latency       []Latency      `json:"latency"`       //每分钟内的延迟统计，旧版本的数据总结中没有该字段
+		TotalLatency  Latency        `json:"totalLatency"`  //统计时段内的延迟统计
+		Stats         CommentStats   `json:"stats"`         //评论的其他统计数据，旧版本的数据总结中没有该字段
+		Spam          SpamStats      `json:"spam"`          //被标记为刷屏的评论，不计入其他字段，旧版本的数据总结中没有该字段
+		People        map[uint64]int `json:"people"`        //参与评论的用户，键为uid, 值为发送的评论数
+		Count         int            `json:"count"`         //记录到的评论数，不含楼中楼
+		StartAllCount int            `json:"startAllCount"` //开始时的总评论数，包含楼中楼
+		StartCount    int            `json:"startCount"`    //开始时的评论数，不含楼中楼
+		EndAllCount   int            `json:"endAllCount"`   //结束时的总评论数，包含楼中楼
+		EndCount      int            `json:"endCount"`      //结束时的评论数，不含楼中楼
+	} `json:"board"`
+	Account struct {
+		Name           string           `json:"name"`           //用户名
+		Alias          string           `json:"alias"`          //别名
+		Uid            uint64           `json:"uid"`            //uid
+		StartFollowers int              `json:"startFollowers"` //粉丝数
+		EndFollowers   int              `json:"endFollowers"`
+		FansCount      []int            `json:"fansCount"`    //粉丝数变化
+		StatInterval   int              `json:"statInterval"` //统计数据的记录间隔，单位：分钟
+		Stats          map[string][]int `json:"stats"`        //各项统计数据的变化，键为数据项名称，第一项为开始时的数据
+		StatTimes      []int64          `json:"statTimes"`    //各项统计数据的记录时间，和 stats 中的每一项对应，旧版本的数据总结中没有该字段
+	} `json:"account"`
+	Video *VideoSummary `json:"video,omitempty"` //视频数据，只有监控视频评论区时才有该字段
+}
+
+// VideoSummary 统计时段内的视频数据
+type VideoSummary struct {
+	BvID     string               `json:"bvID"`     //bv号
+	Aid      uint64               `json:"aid"`      //av号
+	Interval int                  `json:"interval"` //记录间隔，单位：分钟
+	Start    map[string]int       `json:"start"`    //开始时的各项数据
+	End      map[string]int       `json:"end"`      //结束时的各项数据
+	Growth   map[string][]float64 `json:"growth"`   //各项数据每分钟的增长量，一个元素对应一次记录
+	Hot      bool                 `json:"hot"`      //统计时段内是否进入过热门
+	HisRank  int                  `json:"hisRank"`  //历史全站排行榜最高排名
+}
+
+// Add 记录一次视频数据，metrics 为各项数据，minutes 为距离上一次记录的时间，单位：分钟
+func (v *VideoSummary) Add(metrics map[string]int, hisRank int, minutes float64) {
+	for name, value := range metrics {
+		growth := 0.0
+		if minutes > 0 {
+			growth = float64(value-v.End[name]) / minutes
+		}
+		v.Growth[name] = append(v.Growth[name], growth)
+	}
+	v.End = metrics
+	if hisRank != 0 && (v.HisRank == 0 || hisRank < v.HisRank) {
+		v.HisRank = hisRank
+	}
+}
+
+// Next 开始新的统计时段，上一个时段结束时的数据作为新时段开始时的数据
+func (v *VideoSummary) Next() *VideoSummary {
+	return &VideoSummary{
+		BvID:     v.BvID,
+		Aid:      v.Aid,
+		Interval: v.Interval,
+		Start:    v.End,
+		End:      v.End,
+		Growth:   make(map[string][]float64),
+		HisRank:  v.HisRank,
+	}
+}
@@ -0,0 +1,66 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultTemplate 默认的数据总结模板，生成的文字和之前 analyse/main.py 中的相同，
+//有刷屏的评论时增加刷屏的评论数，统计到词语时增加热词和上升最快的词语
+const DefaultTemplate = `【数据总结】{{date .Start "01月02日"}}-{{date .End "01月02日"}}
+【{{.AccountName}}】粉丝数变化：{{change .Followers}}
+【{{.BoardName}}】评论数变化：{{change .AllCount}}
+不含楼中楼评论数：{{change .Count}}
+{{date .PeakTime "01-02 15:04"}} 达到最高同接：{{.PeakHot}}条/分钟
+发送评论人数：{{.People}}
+单个账号最多发送评论：{{.Top.Count}} 条{{with .Spam}}{{if .Count}}
+刷屏的评论：{{.Count}} 条（复读：{{.Duplicate}}，频率异常：{{.Rate}}），未计入以上数据{{end}}{{end}}{{with .Stats.TopWords 10}}
+热词：{{range $i, $w := .}}{{if $i}}，{{end}}{{$w.Name}}{{end}}{{end}}{{with .Stats.Trending}}
+上升最快：{{range $i, $t := .}}{{if $i}}，{{end}}{{$t.Name}}({{$t.Count}}){{end}}{{end}}`
+
+//模板中可以使用的函数
+var funcs = template.FuncMap{
+	//按 layout 格式化时间，例如：{{date .Start "01月02日"}}
+	"date": func(t time.Time, layout string) string {
+		return t.Format(layout)
+	},
+	//带符号的数字，例如：+10，-3
+	"signed": func(n int) string {
+		return fmt.Sprintf("%+d", n)
+	},
+	//数据的变化，例如：100 => 110(+10)
+	"change": func(c Change) string {
+		return fmt.Sprintf("%d => %d(%+d)", c.Start, c.End, c.Delta())
+	},
+	//两个数相加，例如：{{add $i 1}}
+	"add": func(a, b int) int {
+		return a + b
+	},
+	//相对于 prev 的变化率，例如：+12.5%，prev 为0时返回 -
+	"percent": func(cur, prev int) string {
+		if prev == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%+.1f%%", float64(cur-prev)/float64(prev)*100)
+	},
+}
+
+// ParseTemplate 解析数据总结的模板，text 为空时使用默认模板。
+//模板中的数据为 Report，除了 Report 的字段和方法外，还可以使用 date，signed，change，add 和 percent 函数
+func ParseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	return template.New("report").Funcs(funcs).Parse(text)
+}
+
+// Text 使用模板生成数据总结的文字，会去掉末尾的换行
+func (r *Report) Text(tmpl *template.Template) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, r); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
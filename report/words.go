@@ -0,0 +1,127 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/Hami-Lemon/bobo-bot/segment"
+	"github.com/Hami-Lemon/bobo-bot/set"
+)
+
+// WordsLimit 数据总结中最多保存的词语和词组的数量
+const WordsLimit = 500
+
+//上升的词语在统计时段内最少出现的评论数
+const trendMinCount = 5
+
+//上升的词语最少的增长倍数
+const trendMinGrowth = 2
+
+// Trend 和上一个统计时段相比出现次数增长的词语或词组
+type Trend struct {
+	Name   string  `json:"name"`
+	Count  int     `json:"count"`  //统计时段内出现的评论数
+	Prev   int     `json:"prev"`   //上一个统计时段内出现的评论数
+	Growth float64 `json:"growth"` //按两个统计时段的评论数换算后的增长倍数
+}
+
+//连接相邻的两个词语，两边都是字母或数字时使用空格分隔
+func joinWords(a, b string) string {
+	last, _ := utf8.DecodeLastRuneInString(a)
+	first, _ := utf8.DecodeRuneInString(b)
+	if last < utf8.RuneSelf && first < utf8.RuneSelf {
+		return a + " " + b
+	}
+	return a + b
+}
+
+//统计评论中的词语和相邻两个词语组成的词组，表情不参与分词，同一条评论中重复的词语只统计一次
+func (s *CommentStats) addWords(msg string) {
+	text := emoteRegexp.ReplaceAllString(msg, " ")
+	words, bigrams := set.New[string](), set.New[string]()
+	for _, clause := range segment.Default().Clauses(text) {
+		for i, word := range clause {
+			if !words.Contains(word) {
+				words.Add(word)
+				s.Words[word]++
+			}
+			if i == 0 {
+				continue
+			}
+			if bigram := joinWords(clause[i-1], word); !bigrams.Contains(bigram) {
+				bigrams.Add(bigram)
+				s.Bigrams[bigram]++
+			}
+		}
+	}
+}
+
+// TopWords 出现在最多评论中的 n 个词语
+func (s CommentStats) TopWords(n int) []Ranked {
+	return rank(s.Words, n)
+}
+
+// TopBigrams 出现在最多评论中的 n 个词组
+func (s CommentStats) TopBigrams(n int) []Ranked {
+	return rank(s.Bigrams, n)
+}
+
+//只保留出现次数最多的 n 项，不修改原来的 map
+func trimRanked(m map[string]int, n int) map[string]int {
+	if len(m) <= n {
+		return m
+	}
+	trimmed := make(map[string]int, n)
+	for _, r := range rank(m, n) {
+		trimmed[r.Name] = r.Count
+	}
+	return trimmed
+}
+
+// TrimWords 只保留出现次数最多的 n 个词语和词组，用于保存数据总结，避免文件过大
+func (s CommentStats) TrimWords(n int) CommentStats {
+	s.Words = trimRanked(s.Words, n)
+	s.Bigrams = trimRanked(s.Bigrams, n)
+	return s
+}
+
+// Trending 和上一个统计时段 prev 相比出现次数增长最快的 n 个词语和词组。
+//出现次数按两个统计时段的评论数换算，至少出现在5条评论中并且增长到2倍以上时才认为是上升的词语；
+//prev 中只保存了出现次数最多的词语，没有保存的词语按0计算。任意一个统计时段没有评论时返回nil
+func Trending(cur, prev CommentStats, n int) []Trend {
+	if cur.Length.Count == 0 || prev.Length.Count == 0 {
+		return nil
+	}
+	scale := float64(cur.Length.Count) / float64(prev.Length.Count)
+	var trends []Trend
+	seen := make(map[string]bool)
+	for _, m := range [][2]map[string]int{{cur.Words, prev.Words}, {cur.Bigrams, prev.Bigrams}} {
+		for name, count := range m[0] {
+			if count < trendMinCount || seen[name] {
+				continue
+			}
+			seen[name] = true
+			p := m[1][name]
+			growth := float64(count+1) / (float64(p)*scale + 1)
+			if growth < trendMinGrowth {
+				continue
+			}
+			trends = append(trends, Trend{name, count, p, math.Round(growth*100) / 100})
+		}
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		a, b := trends[i], trends[j]
+		if a.Growth != b.Growth {
+			return a.Growth > b.Growth
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	if n > 0 && n < len(trends) {
+		trends = trends[:n]
+	}
+	return trends
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommentStats_Words(t *testing.T) {
+	s := NewCommentStats()
+	for _, msg := range []string{
+		"生日快乐生日快乐[doge]",
+		"啵啵生日快乐！AWSL",
+		"awsl awsl",
+	} {
+		s.AddComment(msg, "")
+	}
+	//同一条评论中重复的词语只统计一次，表情不参与分词
+	want := map[string]int{"生日快乐": 2, "啵啵": 1, "awsl": 2}
+	if !reflect.DeepEqual(s.Words, want) {
+		t.Errorf("words: want %v, got %v", want, s.Words)
+	}
+	want = map[string]int{"生日快乐生日快乐": 1, "啵啵生日快乐": 1, "awsl awsl": 1}
+	if !reflect.DeepEqual(s.Bigrams, want) {
+		t.Errorf("bigrams: want %v, got %v", want, s.Bigrams)
+	}
+	if got := s.TopWords(2); !reflect.DeepEqual(got, []Ranked{{"awsl", 2}, {"生日快乐", 2}}) {
+		t.Errorf("top words: got %v", got)
+	}
+
+	trimmed := s.TrimWords(1)
+	if len(trimmed.Words) != 1 || len(trimmed.Bigrams) != 1 || len(s.Words) != 3 {
+		t.Errorf("trim: got %v, %v, original %v", trimmed.Words, trimmed.Bigrams, s.Words)
+	}
+}
+
+func TestTrending(t *testing.T) {
+	prev := NewCommentStats()
+	prev.Length.Count = 100
+	prev.Words = map[string]int{"晚安": 20, "生日快乐": 2, "新衣服": 5}
+	cur := NewCommentStats()
+	cur.Length.Count = 50
+	cur.Words = map[string]int{"晚安": 12, "生日快乐": 30, "新衣服": 4, "好耶": 4}
+	cur.Bigrams = map[string]int{"新衣服好看": 8}
+	//按评论数换算后：晚安 12 : 10，生日快乐 30 : 1，新衣服 4 : 2.5，好耶出现次数太少
+	got := Trending(cur, prev, 10)
+	want := []Trend{{"生日快乐", 30, 2, 15.5}, {"新衣服好看", 8, 0, 9}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got = Trending(cur, prev, 1); len(got) != 1 {
+		t.Errorf("limit: got %v", got)
+	}
+	if got = Trending(cur, NewCommentStats(), 10); got != nil {
+		t.Errorf("empty prev: got %v", got)
+	}
+}
+
+func TestReport_TextWords(t *testing.T) {
+	tmpl, err := ParseTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testSummary()
+	s.Board.Stats = NewCommentStats()
+	s.Board.Stats.Words = map[string]int{"晚安": 3, "好耶": 5}
+	s.Board.Stats.Trending = []Trend{{"好耶", 5, 0, 6}}
+	text, err := New(s).Text(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(text, "条\n热词：好耶，晚安\n上升最快：好耶(5)") {
+		t.Errorf("got:\n%s", text)
+	}
+}
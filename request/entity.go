@@ -5,7 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 )
 
 // Entity 数据体接口， 表示请求体或响应体
@@ -82,3 +89,189 @@ func (n *NameValueEntity) Reader() io.Reader {
 func (n *NameValueEntity) Add(name string, value interface{}) {
 	n.items[name] = value
 }
+
+// MultipartEntity multipart/form-data 格式的数据体，用于上传文件。
+//文件的内容在发送请求时才会读取，并通过管道边读边发送，不会全部读入内存中
+type MultipartEntity struct {
+	boundary string
+	parts    []multipartPart
+}
+
+//multipart 中的一个部分，字段或者文件
+type multipartPart struct {
+	header textproto.MIMEHeader
+	value  []byte    //字段的值或者文件的内容
+	path   string    //文件路径，不为空时从该文件中读取内容
+	reader io.Reader //读取文件内容的 reader
+}
+
+//读取这部分的内容，读取完成后需要调用 closeFn
+func (p multipartPart) open() (r io.Reader, closeFn func(), err error) {
+	switch {
+	case p.path != "":
+		f, err := os.Open(p.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, func() { _ = f.Close() }, nil
+	case p.reader != nil:
+		return p.reader, func() {}, nil
+	default:
+		return bytes.NewReader(p.value), func() {}, nil
+	}
+}
+
+//可以知道剩余长度的 reader，例如：bytes.Reader，strings.Reader，bytes.Buffer
+func readerLen(r io.Reader) int64 {
+	if l, ok := r.(interface{ Len() int }); ok {
+		return int64(l.Len())
+	}
+	return -1
+}
+
+func NewMultipartEntity() *MultipartEntity {
+	return &MultipartEntity{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+}
+
+//转义字段名和文件名中的引号，和 mime/multipart 中的处理方式相同
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+//文件部分的header，contentType 为空时使用 application/octet-stream
+func fileHeader(name, fileName, contentType string) textproto.MIMEHeader {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(name), quoteEscaper.Replace(fileName)))
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+// AddField 添加普通的字段
+func (m *MultipartEntity) AddField(name, value string) {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name)))
+	m.parts = append(m.parts, multipartPart{header: header, value: []byte(value)})
+}
+
+// AddFile 添加文件，fileName 为文件名，contentType 为文件的数据类型，data 为文件的内容
+func (m *MultipartEntity) AddFile(name, fileName, contentType string, data []byte) {
+	m.parts = append(m.parts, multipartPart{header: fileHeader(name, fileName, contentType), value: data})
+}
+
+// AddFileReader 添加文件，文件的内容从 r 中读取，r 只会被读取一次，因此数据体只能用于一次请求
+func (m *MultipartEntity) AddFileReader(name, fileName, contentType string, r io.Reader) {
+	m.parts = append(m.parts, multipartPart{header: fileHeader(name, fileName, contentType), reader: r})
+}
+
+// AddFilePath 添加本地文件，文件名使用 path 中的文件名，数据类型根据扩展名判断，
+//发送请求时才会打开文件，文件不存在时请求会返回错误
+func (m *MultipartEntity) AddFilePath(name, path string) {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	m.parts = append(m.parts, multipartPart{header: fileHeader(name, filepath.Base(path), contentType), path: path})
+}
+
+// ContentType 包含分隔符的数据类型，例如：multipart/form-data; boundary=xxx
+func (m *MultipartEntity) ContentType() string {
+	return mime.FormatMediaType("multipart/form-data", map[string]string{"boundary": m.boundary})
+}
+
+// ContentLength 数据体的长度，有无法知道长度的 reader 时返回-1
+func (m *MultipartEntity) ContentLength() int64 {
+	var counter countWriter
+	w := multipart.NewWriter(&counter)
+	_ = w.SetBoundary(m.boundary)
+	for _, p := range m.parts {
+		size := int64(len(p.value))
+		switch {
+		case p.path != "":
+			info, err := os.Stat(p.path)
+			if err != nil {
+				return -1
+			}
+			size = info.Size()
+		case p.reader != nil:
+			if size = readerLen(p.reader); size < 0 {
+				return -1
+			}
+		}
+		if _, err := w.CreatePart(p.header); err != nil {
+			return -1
+		}
+		counter += countWriter(size)
+	}
+	_ = w.Close()
+	return int64(counter)
+}
+
+// Reader 返回读取数据体的 reader，数据在读取时才会生成，
+//读取文件出现错误时，reader 会返回对应的错误
+func (m *MultipartEntity) Reader() io.Reader {
+	return &multipartReader{entity: m}
+}
+
+//第一次读取时才开始写入数据，没有读取就丢弃时不会留下写入数据的 goroutine 和打开的文件。
+//提前关闭时写入数据的 goroutine 会结束并关闭打开的文件
+type multipartReader struct {
+	entity *MultipartEntity
+	once   sync.Once
+	pr     *io.PipeReader
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.once.Do(r.start)
+	if r.pr == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return r.pr.Read(p)
+}
+
+// Close 关闭 reader，之后的读取会返回错误
+func (r *multipartReader) Close() error {
+	r.once.Do(func() {})
+	if r.pr == nil {
+		return nil
+	}
+	return r.pr.Close()
+}
+
+func (r *multipartReader) start() {
+	pr, pw := io.Pipe()
+	r.pr = pr
+	go func() {
+		w := multipart.NewWriter(pw)
+		_ = w.SetBoundary(r.entity.boundary)
+		for _, p := range r.entity.parts {
+			if err := r.entity.writePart(w, p); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		_ = pw.CloseWithError(w.Close())
+	}()
+}
+
+func (m *MultipartEntity) writePart(w *multipart.Writer, p multipartPart) error {
+	r, closeFn, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	part, err := w.CreatePart(p.header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, r)
+	return err
+}
+
+//只统计写入的字节数
+type countWriter int64
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	*c += countWriter(len(p))
+	return len(p), nil
+}
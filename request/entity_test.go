@@ -0,0 +1,193 @@
+package request
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultipartEntity(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hot.png")
+	if err := os.WriteFile(path, []byte("png data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMultipartEntity()
+	m.AddField("csrf", "abc")
+	m.AddFile("bytes", "a.txt", "text/plain", []byte("hello"))
+	m.AddFileReader("reader", `b"c.txt`, "", strings.NewReader("world"))
+	m.AddFilePath("path", path)
+
+	typ, params, err := mime.ParseMediaType(m.ContentType())
+	if err != nil || typ != "multipart/form-data" || params["boundary"] == "" {
+		t.Fatalf("content type: %s, %v", m.ContentType(), err)
+	}
+	length := m.ContentLength()
+	data, err := io.ReadAll(m.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length != int64(len(data)) {
+		t.Errorf("content length: want %d, got %d", len(data), length)
+	}
+
+	want := []struct {
+		name, fileName, contentType, value string
+	}{
+		{"csrf", "", "", "abc"},
+		{"bytes", "a.txt", "text/plain", "hello"},
+		{"reader", `b"c.txt`, "application/octet-stream", "world"},
+		{"path", "hot.png", "image/png", "png data"},
+	}
+	r := multipart.NewReader(bytes.NewReader(data), params["boundary"])
+	for _, w := range want {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, _ := io.ReadAll(part)
+		if part.FormName() != w.name || part.FileName() != w.fileName || string(value) != w.value {
+			t.Errorf("want %v, got %s, %s, %s", w, part.FormName(), part.FileName(), value)
+		}
+		if w.contentType != "" && part.Header.Get("Content-Type") != w.contentType {
+			t.Errorf("%s: want content type %s, got %s", w.name, w.contentType, part.Header.Get("Content-Type"))
+		}
+	}
+	if _, err = r.NextPart(); err != io.EOF {
+		t.Errorf("want EOF, got %v", err)
+	}
+}
+
+func TestMultipartEntity_Request(t *testing.T) {
+	var (
+		contentLength int64
+		fields        = make(map[string]string)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for name, values := range r.MultipartForm.Value {
+			fields[name] = values[0]
+		}
+		for name, files := range r.MultipartForm.File {
+			f, _ := files[0].Open()
+			data, _ := io.ReadAll(f)
+			fields[name] = string(data)
+		}
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	c := New(map[string]string{}, map[string]string{}, 3)
+	m := NewMultipartEntity()
+	m.AddField("biz", "new_dyn")
+	m.AddFile("file_up", "a.png", "image/png", []byte("image"))
+	if _, err := c.Post(server.URL, nil, m); err != nil {
+		t.Fatal(err)
+	}
+	if fields["biz"] != "new_dyn" || fields["file_up"] != "image" {
+		t.Errorf("got %v", fields)
+	}
+	if contentLength != m.ContentLength() {
+		t.Errorf("content length: want %d, got %d", m.ContentLength(), contentLength)
+	}
+
+	//文件不存在时请求失败
+	m = NewMultipartEntity()
+	m.AddFilePath("file_up", filepath.Join(t.TempDir(), "missing.png"))
+	if _, err := c.Post(server.URL, nil, m); err == nil {
+		t.Error("want error for missing file")
+	}
+}
+
+//等待 goroutine 的数量回到 n 以下
+func waitGoroutines(n int) bool {
+	for i := 0; i < 100; i++ {
+		if runtime.NumGoroutine() <= n {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestMultipartEntity_Reader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hot.png")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("png data"), 1<<14), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMultipartEntity()
+	m.AddFilePath("file_up", path)
+	before := runtime.NumGoroutine()
+
+	//创建请求失败时没有读取数据体
+	c := New(map[string]string{}, map[string]string{}, 3)
+	if _, err := c.request("bad method", "http://127.0.0.1", nil, m); err == nil {
+		t.Fatal("want error for invalid method")
+	}
+	if !waitGoroutines(before) {
+		t.Errorf("goroutine leaked before reading: want %d, got %d", before, runtime.NumGoroutine())
+	}
+
+	//读取一部分后关闭
+	r := m.Reader()
+	buf := make([]byte, 16)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !waitGoroutines(before) {
+		t.Errorf("goroutine leaked after close: want %d, got %d", before, runtime.NumGoroutine())
+	}
+	if _, err := r.Read(buf); err == nil {
+		t.Error("want error after close")
+	}
+}
+
+//记录是否被关闭的数据体
+type closeEntity struct {
+	closed bool
+}
+
+func (e *closeEntity) Reader() io.Reader {
+	return e
+}
+
+func (e *closeEntity) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (e *closeEntity) Close() error {
+	e.closed = true
+	return nil
+}
+
+func (e *closeEntity) ContentType() string {
+	return "text/plain"
+}
+
+func TestClient_requestCloseBody(t *testing.T) {
+	c := New(map[string]string{}, map[string]string{}, 3)
+	//创建请求失败时关闭数据体
+	e := &closeEntity{}
+	if _, err := c.Post("://bad", nil, e); err == nil {
+		t.Fatal("want error")
+	}
+	if !e.closed {
+		t.Error("body not closed")
+	}
+}
@@ -18,6 +18,11 @@ var (
 	ErrRequest = errors.New("request fail")
 )
 
+//可以预先知道长度的数据体，发送请求时会设置 Content-Length，否则使用分块传输
+type lengthEntity interface {
+	ContentLength() int64
+}
+
 type Client struct {
 	header map[string]string
 	cookie map[string]string
@@ -94,16 +99,27 @@ func (c *Client) request(method, urlStr string,
 	for name, value := range params {
 		v.Add(name, fmt.Sprintf("%v", value))
 	}
-	//创建请求
+	//创建请求，需要在读取数据体之前获取数据体的长度
 	var reader io.Reader
+	contentLength := int64(-1)
 	if body != nil {
+		if l, ok := body.(lengthEntity); ok {
+			contentLength = l.ContentLength()
+		}
 		reader = body.Reader()
 	}
 	req, err := http.NewRequest(method,
 		fmt.Sprintf("%s?%s", urlStr, v.Encode()), reader)
 	if err != nil {
+		//请求没有发送，需要关闭数据体，例如打开的文件
+		if closer, ok := reader.(io.Closer); ok {
+			_ = closer.Close()
+		}
 		return nil, err
 	}
+	if contentLength >= 0 {
+		req.ContentLength = contentLength
+	}
 	//设置cookie
 	u := req.URL
 	for name, value := range c.cookie {
@@ -170,6 +186,11 @@ func (c *Client) SetCookie(name, value string) {
 	c.cookie[name] = value
 }
 
+// SetTransport 设置发送请求使用的 http.RoundTripper，例如在测试中将请求转发到本地的服务器
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.client.Transport = rt
+}
+
 // Cookie 获取cookie,返回的 cookie 为 client 持有的 cookie 的副本，
 //对其进行修改不会影响 client 持有的 cookie 的内容
 func (c *Client) Cookie() map[string]string {
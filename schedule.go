@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	//内置时区数据，没有安装时区数据的系统（例如 Windows）也可以使用 timezone 配置
+	_ "time/tzdata"
+)
+
+//cron 表达式的简写
+var scheduleAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+//查找触发时间的范围，超过范围时认为表达式不会触发
+const scheduleSearchYears = 5
+
+// Schedule cron 表达式表示的触发时间，精确到分钟。
+//表达式由 分钟 小时 日 月 星期 五个字段组成，每个字段支持 *，数字，a-b，*/n，a-b/n 和使用逗号分隔的列表，
+//星期的取值为 0-7，0 和 7 都表示星期日。日和星期都不为 * 时，满足其中一个即可
+type Schedule struct {
+	expr    string
+	minute  uint64 //每个字段允许的值，第 i 位为1表示允许 i
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool //日是否为 *
+	dowStar bool //星期是否为 *
+	loc     *time.Location
+}
+
+// ParseSchedule 解析 cron 表达式，按 loc 时区计算触发时间，loc 为nil时使用本地时区
+func ParseSchedule(expr string, loc *time.Location) (*Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	spec := strings.TrimSpace(expr)
+	if alias, ok := scheduleAliases[spec]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式需要5个字段：%q", expr)
+	}
+	s := &Schedule{expr: expr, loc: loc}
+	var err error
+	ranges := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+	for i, r := range ranges {
+		if *r.bits, err = parseCronField(fields[i], r.min, r.max); err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 的第%d个字段错误，%w", expr, i+1, err)
+		}
+	}
+	//7 和 0 都表示星期日
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+	return s, nil
+}
+
+//解析 cron 表达式中的一个字段，返回允许的值
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, step := min, max, 1
+		rng := part
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("错误的间隔：%q", part)
+			}
+			step = n
+			rng = part[:i]
+		}
+		if rng != "*" {
+			var err error
+			if i := strings.IndexByte(rng, '-'); i >= 0 {
+				lo, err = strconv.Atoi(rng[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(rng[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(rng)
+				hi = lo
+				//5/10 表示从5开始每隔10
+				if step > 1 {
+					hi = max
+				}
+			}
+			if err != nil {
+				return 0, fmt.Errorf("错误的取值：%q", part)
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("取值超出范围 %d-%d：%q", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// Location 计算触发时间使用的时区
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+//日期是否满足日和星期的条件
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next 晚于 t 的第一个触发时间，没有时返回零值
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + scheduleSearchYears
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Prev 不晚于 t 的最近一次触发时间，即 t 所在统计时段的开始时间，没有时返回零值
+func (s *Schedule) Prev(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute)
+	limit := t.Year() - scheduleSearchYears
+	for t.Year() >= limit {
+		switch {
+		case s.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.loc).Add(-time.Minute)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc).Add(-time.Minute)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.loc).Add(-time.Minute)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(-time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+//兼容之前的 hour 和 minute 配置，h 为-1时每小时生成一次
+func legacySchedule(h, m int, loc *time.Location) (*Schedule, error) {
+	hour := strconv.Itoa(h)
+	if h == -1 {
+		hour = "*"
+	}
+	return ParseSchedule(fmt.Sprintf("%d %s * * *", m, hour), loc)
+}
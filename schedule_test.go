@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *",
+		"5-1 * * * *", "a * * * *", "@every"} {
+		if _, err := ParseSchedule(expr, nil); err == nil {
+			t.Errorf("%q: want error", expr)
+		}
+	}
+	for _, expr := range []string{"@daily", "*/15 8-18 * * 1-5", "0 0 1,15 * *", "30 7 * * 7", "5/10 * * * *"} {
+		if _, err := ParseSchedule(expr, nil); err != nil {
+			t.Errorf("%q: %v", expr, err)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	at := func(month time.Month, day, h, m int) time.Time {
+		return time.Date(2022, month, day, h, m, 0, 0, loc)
+	}
+	//2022-07-01 为星期五
+	tests := []struct {
+		expr string
+		now  time.Time
+		want time.Time
+	}{
+		{"33 7 * * *", at(7, 1, 7, 32), at(7, 1, 7, 33)},
+		{"33 7 * * *", at(7, 1, 7, 33), at(7, 2, 7, 33)},
+		{"@hourly", at(7, 1, 23, 10), at(7, 2, 0, 0)},
+		{"@weekly", at(7, 1, 12, 0), at(7, 3, 0, 0)},
+		{"@monthly", at(7, 15, 0, 0), at(8, 1, 0, 0)},
+		{"*/20 9-10 * * *", at(7, 1, 10, 41), at(7, 2, 9, 0)},
+		{"0 0 31 * *", at(9, 1, 0, 0), at(10, 31, 0, 0)},
+		{"0 0 29 2 *", at(7, 1, 0, 0), time.Date(2024, 2, 29, 0, 0, 0, 0, loc)},
+		//日和星期都不为 * 时，满足其中一个即可
+		{"0 0 10 * 1", at(7, 1, 0, 0), at(7, 4, 0, 0)},
+		{"0 0 10 * 1", at(7, 5, 0, 0), at(7, 10, 0, 0)},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.now, got, tt.want)
+		}
+		//触发时间的上一次触发时间为自身
+		if got := s.Prev(tt.want); !got.Equal(tt.want) {
+			t.Errorf("%q.Prev(%v) = %v", tt.expr, tt.want, got)
+		}
+		if got := s.Next(s.Prev(tt.now)); tt.now.Before(got) && !got.Equal(tt.want) {
+			t.Errorf("%q: Next(Prev(%v)) = %v, want %v", tt.expr, tt.now, got, tt.want)
+		}
+	}
+	//不会触发的表达式
+	if s, _ := ParseSchedule("0 0 31 2 *", loc); !s.Next(at(7, 1, 0, 0)).IsZero() || !s.Prev(at(7, 1, 0, 0)).IsZero() {
+		t.Error("want zero time")
+	}
+}
+
+func TestSchedule_Location(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := ParseSchedule("33 7 * * *", shanghai)
+	//UTC 的 23:00 为北京时间的 7:00
+	now := time.Date(2022, 7, 1, 23, 0, 0, 0, time.UTC)
+	want := time.Date(2022, 7, 1, 23, 33, 0, 0, time.UTC)
+	if got := s.Next(now); !got.Equal(want) || got.Location() != shanghai {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got := s.Prev(now); !got.Equal(want.AddDate(0, 0, -1)) {
+		t.Errorf("prev: got %v", got)
+	}
+}
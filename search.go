@@ -0,0 +1,381 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrNoFTS 程序编译时没有启用 FTS5
+var ErrNoFTS = errors.New("sqlite 未启用 FTS5，请使用 -tags sqlite_fts5 编译")
+
+//全文索引使用 trigram 分词，支持中文，但每个词至少需要三个字符
+const minTrigram = 3
+
+//全文索引表以及保持索引同步的触发器
+var ftsTriggers = []string{
+	`create trigger if not exists comment_fts_ai after insert on comment begin
+    insert into comment_fts(rowid, msg) values (new.id, new.msg);
+end;`,
+	`create trigger if not exists comment_fts_ad after delete on comment begin
+    insert into comment_fts(comment_fts, rowid, msg) values ('delete', old.id, old.msg);
+end;`,
+	`create trigger if not exists comment_fts_au after update of msg on comment begin
+    insert into comment_fts(comment_fts, rowid, msg) values ('delete', old.id, old.msg);
+    insert into comment_fts(rowid, msg) values (new.id, new.msg);
+end;`,
+}
+
+//判断 sqlite 是否启用了 FTS5
+func hasFTS5(conn *sql.DB) bool {
+	var used int
+	err := conn.QueryRow("select sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+	return err == nil && used == 1
+}
+
+//创建评论的全文索引，并通过触发器和 comment 表保持同步。
+//全文索引依赖编译选项，所以不作为结构变更，未启用 FTS5 时会删除触发器，避免无法写入评论，
+//之后使用启用了 FTS5 的程序打开时会重建索引
+func ensureFTS(conn *sql.DB) (bool, error) {
+	var triggers int
+	err := conn.QueryRow(`select count(*) from sqlite_master
+where type = 'trigger' and name like 'comment_fts_%'`).Scan(&triggers)
+	if err != nil {
+		return false, err
+	}
+	if !hasFTS5(conn) {
+		if triggers > 0 {
+			for _, name := range []string{"comment_fts_ai", "comment_fts_ad", "comment_fts_au"} {
+				if _, err = conn.Exec("drop trigger if exists " + name); err != nil {
+					return false, err
+				}
+			}
+		}
+		return false, nil
+	}
+	if triggers == len(ftsTriggers) {
+		return true, nil
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`create virtual table if not exists comment_fts
+using fts5(msg, content='comment', content_rowid='id', tokenize='trigram')`)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	for _, trigger := range ftsTriggers {
+		if _, err = tx.Exec(trigger); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+	//索引可能和 comment 表不一致，重建索引
+	mainLogger.Info("重建评论的全文索引")
+	if _, err = tx.Exec("insert into comment_fts(comment_fts) values ('rebuild')"); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// SearchQuery 全文搜索的条件
+type SearchQuery struct {
+	CommentQuery        //评论区、用户和时间段的过滤条件，keyword 不生效
+	match        string //搜索语句，支持 FTS5 的查询语法，例如：三三 AND 好耶，"完整短语"，NOT 关键词
+}
+
+//获取搜索语句中的词，不包含运算符
+func searchTerms(match string) []string {
+	var terms []string
+	for _, field := range strings.Fields(match) {
+		switch field {
+		case "AND", "OR", "NOT":
+			continue
+		}
+		term := strings.Trim(field, `"()*^`)
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+//搜索语句中的一项：运算符、括号或者需要匹配的词
+type searchToken struct {
+	op   string //AND，OR，NOT，( 或 )，为空时表示词
+	term string
+}
+
+//切分搜索语句，"" 中的内容作为一个词，词首的 ^ 和词尾的 * 会被去掉
+func searchTokens(match string) ([]searchToken, error) {
+	var (
+		tokens []searchToken
+		runes  = []rune(match)
+	)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, searchToken{op: string(r)})
+			i++
+		case r == '"':
+			//短语中的 "" 表示一个引号
+			var sb strings.Builder
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '"' {
+					if i+1 < len(runes) && runes[i+1] == '"' {
+						sb.WriteRune('"')
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("错误的搜索语句，引号不匹配：%s", match)
+			}
+			if sb.Len() > 0 {
+				tokens = append(tokens, searchToken{term: sb.String()})
+			}
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune(`()"`, runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			i = j
+			switch word {
+			case "AND", "OR", "NOT":
+				tokens = append(tokens, searchToken{op: word})
+			default:
+				if term := strings.TrimSuffix(strings.TrimPrefix(word, "^"), "*"); term != "" {
+					tokens = append(tokens, searchToken{term: term})
+				}
+			}
+		}
+	}
+	return tokens, nil
+}
+
+//按 FTS5 的语法解析切分后的搜索语句，生成子串匹配的条件
+type substringMatcher struct {
+	tokens []searchToken
+	pos    int   //下一个需要解析的位置
+	args   []any //条件中的参数，按占位符的顺序
+}
+
+//将搜索语句转换为对评论内容的子串匹配条件，运算符的优先级和 FTS5 相同：NOT 高于 AND 高于 OR，
+//相邻的词之间为 AND，例如：三三 OR 好耶 NOT 直播 => instr(三三) or (instr(好耶) and not instr(直播))
+func substringMatch(match string) (string, []any, error) {
+	tokens, err := searchTokens(match)
+	if err != nil {
+		return "", nil, err
+	}
+	m := &substringMatcher{tokens: tokens}
+	cond, err := m.or()
+	if err == nil && m.pos < len(m.tokens) {
+		err = errors.New("错误的搜索语句，多余的 )")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return cond, m.args, nil
+}
+
+func (m *substringMatcher) peek() (searchToken, bool) {
+	if m.pos >= len(m.tokens) {
+		return searchToken{}, false
+	}
+	return m.tokens[m.pos], true
+}
+
+func (m *substringMatcher) or() (string, error) {
+	cond, err := m.and()
+	for err == nil {
+		if t, ok := m.peek(); !ok || t.op != "OR" {
+			break
+		}
+		m.pos++
+		var right string
+		if right, err = m.and(); err == nil {
+			cond = "(" + cond + " or " + right + ")"
+		}
+	}
+	return cond, err
+}
+
+func (m *substringMatcher) and() (string, error) {
+	cond, err := m.not()
+	for err == nil {
+		t, ok := m.peek()
+		if !ok || t.op == "OR" || t.op == ")" || t.op == "NOT" {
+			break
+		}
+		if t.op == "AND" {
+			m.pos++
+		}
+		var right string
+		if right, err = m.not(); err == nil {
+			cond = "(" + cond + " and " + right + ")"
+		}
+	}
+	return cond, err
+}
+
+func (m *substringMatcher) not() (string, error) {
+	cond, err := m.primary()
+	for err == nil {
+		if t, ok := m.peek(); !ok || t.op != "NOT" {
+			break
+		}
+		m.pos++
+		var right string
+		if right, err = m.primary(); err == nil {
+			cond = "(" + cond + " and not " + right + ")"
+		}
+	}
+	return cond, err
+}
+
+func (m *substringMatcher) primary() (string, error) {
+	t, ok := m.peek()
+	if !ok {
+		return "", errors.New("错误的搜索语句，运算符后缺少搜索的词")
+	}
+	m.pos++
+	switch t.op {
+	case "":
+		m.args = append(m.args, t.term)
+		return "instr(msg, ?) > 0", nil
+	case "(":
+		cond, err := m.or()
+		if err != nil {
+			return "", err
+		}
+		if t, ok = m.peek(); !ok || t.op != ")" {
+			return "", errors.New("错误的搜索语句，括号不匹配")
+		}
+		m.pos++
+		return cond, nil
+	default:
+		return "", fmt.Errorf("错误的搜索语句，%s 前缺少搜索的词", t.op)
+	}
+}
+
+// SearchComments 全文搜索评论，结果按发布时间降序排列。
+//trigram 分词要求每个词至少三个字符，包含更短的词时，退化为对评论内容的子串匹配，同样支持 AND、OR、NOT、括号和短语
+func (d *DB) SearchComments(q SearchQuery) ([]CommentRecord, error) {
+	terms := searchTerms(q.match)
+	if len(terms) == 0 {
+		return nil, errors.New("搜索内容为空")
+	}
+	short := false
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < minTrigram {
+			short = true
+			break
+		}
+	}
+	q.keyword = ""
+	where, args := q.where()
+	if where == "" {
+		where = " where "
+	} else {
+		where += " and "
+	}
+	if short {
+		cond, condArgs, err := substringMatch(q.match)
+		if err != nil {
+			return nil, err
+		}
+		where += cond
+		args = append(args, condArgs...)
+	} else {
+		if !d.fts {
+			return nil, ErrNoFTS
+		}
+		where += "id in (select rowid from comment_fts where comment_fts match ?)"
+		args = append(args, q.match)
+	}
+	query := "select " + commentColumns + " from comment" + where + " order by ctime desc"
+	if q.limit > 0 {
+		query += " limit ?"
+		args = append(args, q.limit)
+	}
+	rows, err := d.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []CommentRecord
+	for rows.Next() {
+		r, err := scanComment(rows.Scan)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+//全文搜索评论
+func searchCmd(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名")
+	from := fs.String("from", "", "开始时间，例如：2022-07-01 或 2022-07-01 12:00")
+	to := fs.String("to", "", "结束时间，格式同 from")
+	uid := fs.Uint64("uid", 0, "只搜索该用户发送的评论")
+	oid := fs.Uint64("oid", 0, "只搜索该评论区的评论")
+	limit := fs.Int("limit", 50, "最多显示的评论数，为0时不限制")
+	fs.Usage = func() {
+		fmt.Println("usage: bobo-bot search [options] <query>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	q := SearchQuery{match: strings.Join(fs.Args(), " ")}
+	q.uid, q.oid, q.limit = *uid, *oid, *limit
+	var err error
+	if q.from, err = parseTime(*from, false); err != nil {
+		mainLogger.Error("错误的开始时间：%v", err)
+		return 2
+	}
+	if q.to, err = parseTime(*to, true); err != nil {
+		mainLogger.Error("错误的结束时间：%v", err)
+		return 2
+	}
+
+	d := NewDB(*dbname, DBOption{})
+	if d == nil {
+		return 1
+	}
+	defer d.Close()
+	records, err := d.SearchComments(q)
+	if err != nil {
+		mainLogger.Error("搜索失败，%v", err)
+		return 1
+	}
+	for _, r := range records {
+		fmt.Printf("[%s] %s(%d)：%s\n", time.Unix(int64(r.ctime), 0).Format("2006-01-02 15:04:05"),
+			r.uname, r.uid, r.msg)
+	}
+	mainLogger.Info("共找到 %d 条评论", len(records))
+	return 0
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubstringMatch(t *testing.T) {
+	const c = "instr(msg, ?) > 0"
+	tests := []struct {
+		match string
+		cond  string
+		args  []any
+	}{
+		{"三三", c, []any{"三三"}},
+		{"三三 好耶", "(" + c + " and " + c + ")", []any{"三三", "好耶"}},
+		{"三三 OR 好耶 NOT 直播", "(" + c + " or (" + c + " and not " + c + "))", []any{"三三", "好耶", "直播"}},
+		{"(三三 OR 好耶) AND 晚安*", "((" + c + " or " + c + ") and " + c + ")", []any{"三三", "好耶", "晚安"}},
+		{`"晚安 ""啵啵""" OR ^啵`, "(" + c + " or " + c + ")", []any{`晚安 "啵啵"`, "啵"}},
+	}
+	for _, tt := range tests {
+		cond, args, err := substringMatch(tt.match)
+		if err != nil || cond != tt.cond || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: want %s %v, got %s %v, err=%v", tt.match, tt.cond, tt.args, cond, args, err)
+		}
+	}
+	for _, match := range []string{"三三 OR", "NOT 三三", "(三三 好耶", "三三)", `"三三`} {
+		if _, _, err := substringMatch(match); err == nil {
+			t.Errorf("%s: want error", match)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package segment
+//中文分词，使用内置的词典和停用词表
+package segment
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Hami-Lemon/bobo-bot/set"
+)
+
+//内置词典，每行为：词语 词频
+//
+//go:embed dict.txt
+var defaultDict string
+
+//内置停用词表，每行一个停用词
+//
+//go:embed stopwords.txt
+var defaultStopwords string
+
+var (
+	defaultSegmenter *Segmenter
+	defaultOnce      sync.Once
+)
+
+// Segmenter 分词器，汉字按词典计算出概率最大的切分方式，字母和数字按连续的一段切分
+type Segmenter struct {
+	freq      map[string]float64 //词语的词频取对数后减去总词频的对数
+	unknown   float64            //词典中没有的字的对数概率，即词频为1
+	maxLen    int                //词典中最长的词语的字数
+	stopwords *set.HashSet[string]
+}
+
+// New 根据词典和停用词表创建分词器，空行和 # 开头的行会被忽略，
+//词典中每行为词语和词频，使用空白分隔，没有词频时为1
+func New(dict, stopwords io.Reader) (*Segmenter, error) {
+	s := &Segmenter{
+		freq:      make(map[string]float64),
+		stopwords: set.New[string](),
+	}
+	total := 0.0
+	err := eachLine(dict, func(line string) error {
+		fields := strings.Fields(line)
+		freq := 1.0
+		if len(fields) > 1 {
+			f, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil || f <= 0 {
+				return fmt.Errorf("错误的词频：%q", line)
+			}
+			freq = f
+		}
+		s.freq[fields[0]] = freq
+		total += freq
+		if n := utf8.RuneCountInString(fields[0]); n > s.maxLen {
+			s.maxLen = n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	logTotal := math.Log(total + 1)
+	for word, freq := range s.freq {
+		s.freq[word] = math.Log(freq) - logTotal
+	}
+	s.unknown = -logTotal
+	err = eachLine(stopwords, func(line string) error {
+		s.stopwords.Add(line)
+		return nil
+	})
+	return s, err
+}
+
+//读取每一行，去掉首尾的空白，忽略空行和注释
+func eachLine(r io.Reader, f func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if err := f(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Default 使用内置词典和停用词表的分词器
+func Default() *Segmenter {
+	defaultOnce.Do(func() {
+		var err error
+		defaultSegmenter, err = New(strings.NewReader(defaultDict), strings.NewReader(defaultStopwords))
+		if err != nil {
+			panic("segment: 内置词典错误，" + err.Error())
+		}
+	})
+	return defaultSegmenter
+}
+
+// IsStopword 是否为停用词
+func (s *Segmenter) IsStopword(word string) bool {
+	return s.stopwords.Contains(word)
+}
+
+//是否为字母或数字，只判断ASCII
+func isAlnum(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// Cut 切分文字，返回所有的词语，字母转换为小写，空白会被忽略，标点和表情等其他字符单独作为一个词语
+func (s *Segmenter) Cut(text string) []string {
+	var (
+		words []string
+		runes = []rune(text)
+	)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case unicode.Is(unicode.Han, r):
+			for j < len(runes) && unicode.Is(unicode.Han, runes[j]) {
+				j++
+			}
+			words = s.cutHan(words, runes[i:j])
+		case isAlnum(r):
+			for j < len(runes) && isAlnum(runes[j]) {
+				j++
+			}
+			words = append(words, strings.ToLower(string(runes[i:j])))
+		case !unicode.IsSpace(r):
+			words = append(words, string(r))
+		}
+		i = j
+	}
+	return words
+}
+
+//按词典切分连续的汉字，每个位置选择从该位置到末尾概率最大的切分方式，词典中没有的字作为单字切分
+func (s *Segmenter) cutHan(words []string, runes []rune) []string {
+	n := len(runes)
+	//route[i] 为从 i 开始的最大对数概率，next[i] 为对应的下一个词语的开始位置
+	route := make([]float64, n+1)
+	next := make([]int, n+1)
+	for i := n - 1; i >= 0; i-- {
+		route[i] = math.Inf(-1)
+		for j := i + 1; j <= n && j-i <= s.maxLen || j == i+1; j++ {
+			freq, ok := s.freq[string(runes[i:j])]
+			if !ok {
+				if j > i+1 {
+					continue
+				}
+				freq = s.unknown
+			}
+			//概率相同时选择更长的词语
+			if p := freq + route[j]; p >= route[i] {
+				route[i], next[i] = p, j
+			}
+		}
+	}
+	for i := 0; i < n; i = next[i] {
+		words = append(words, string(runes[i:next[i]]))
+	}
+	return words
+}
+
+//是否为可以作为词语的单字或连续的字母、数字
+func isWordRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || isAlnum(r)
+}
+
+// Clauses 切分文字并去掉停用词，返回按标点、表情和停用词分隔的每一段中的词语。
+//词典中没有的连续单字合并为一个词语，例如新的梗或名字；其他单字和单个字母、数字会被忽略
+func (s *Segmenter) Clauses(text string) [][]string {
+	var (
+		clauses [][]string
+		clause  []string
+		single  []string //连续的词典中没有的单字
+	)
+	flushSingle := func() {
+		if len(single) > 1 {
+			clause = append(clause, strings.Join(single, ""))
+		}
+		single = single[:0]
+	}
+	flushClause := func() {
+		flushSingle()
+		if len(clause) > 0 {
+			clauses = append(clauses, clause)
+		}
+		clause = nil
+	}
+	for _, word := range s.Cut(text) {
+		r, size := utf8.DecodeRuneInString(word)
+		switch {
+		case !isWordRune(r) || s.IsStopword(word):
+			flushClause()
+		case size == len(word) && unicode.Is(unicode.Han, r):
+			if _, ok := s.freq[word]; ok {
+				flushSingle()
+				clause = append(clause, word)
+			} else {
+				single = append(single, word)
+			}
+		case size == len(word):
+			//单个字母或数字
+			flushClause()
+		default:
+			flushSingle()
+			clause = append(clause, word)
+		}
+	}
+	flushClause()
+	return clauses
+}
@@ -0,0 +1,62 @@
+package segment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSegmenter_Cut(t *testing.T) {
+	s := Default()
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"晚安啵啵，今天也辛苦了！", []string{"晚安啵啵", "，", "今天", "也", "辛苦了", "！"}},
+		{"我想吃火锅和烧烤", []string{"我", "想", "吃", "火锅", "和", "烧烤"}},
+		{"AWSL 666", []string{"awsl", "666"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := s.Cut(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: want %q, got %q", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestSegmenter_Clauses(t *testing.T) {
+	s := Default()
+	tests := []struct {
+		text string
+		want [][]string
+	}{
+		//停用词和标点分隔
+		{"新衣服太好看了吧", [][]string{{"新衣服"}, {"好看"}}},
+		{"生日快乐啊，awsl！", [][]string{{"生日快乐"}, {"awsl"}}},
+		//词典中没有的连续单字合并，单独的单字和字母被忽略
+		{"灯灯好可爱", [][]string{{"灯灯", "好可爱"}}},
+		{"草 梗 a 吃", [][]string{{"草", "梗"}}},
+	}
+	for _, tt := range tests {
+		if got := s.Clauses(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: want %q, got %q", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	dict := "# 注释\n\n研究 10\n研究生 5\n生命 8\n起源\n"
+	s, err := New(strings.NewReader(dict), strings.NewReader("的\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Cut("研究生命的起源"); !reflect.DeepEqual(got, []string{"研究", "生命", "的", "起源"}) {
+		t.Errorf("got %q", got)
+	}
+	if !s.IsStopword("的") || s.IsStopword("起源") {
+		t.Error("stopwords")
+	}
+	if _, err = New(strings.NewReader("研究 abc"), strings.NewReader("")); err == nil {
+		t.Error("want error for invalid frequency")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+)
+
+//刷屏检测的默认配置
+const (
+	defaultSpamWindow     = 60  //滑动窗口的时长，单位：秒
+	defaultSpamSimilarity = 0.8 //相似度的下限
+)
+
+// SpamOption 刷屏检测的配置
+type SpamOption struct {
+	window     int64   //滑动窗口的时长，按评论的发送时间计算，单位：秒
+	duplicates int     //窗口内已经有 duplicates 条相似的评论时，新的相似评论标记为重复，为0时不检测
+	similarity float64 //两条评论规范化后的相似度达到该值时视为相似，为1时只有规范化后相同才视为相似
+	rate       int     //窗口内同一个用户已经发送了 rate 条评论时，新的评论标记为频率异常，为0时不检测
+	skipLike   bool    //是否不点赞被标记的评论
+}
+
+//窗口内的一条评论
+type spamEntry struct {
+	ctime uint64
+	uid   uint64
+	text  string               //规范化后的评论内容
+	grams map[[2]rune]struct{} //规范化后的内容中相邻两个字组成的片段，用于计算相似度
+}
+
+// SpamDetector 刷屏检测，在滑动窗口内查找大量相似的评论和发送评论过多的用户
+type SpamDetector struct {
+	SpamOption
+	entries []spamEntry //窗口内的评论
+}
+
+// NewSpamDetector 创建 SpamDetector
+func NewSpamDetector(opt SpamOption) *SpamDetector {
+	return &SpamDetector{SpamOption: opt}
+}
+
+//规范化评论内容：全角字符转为半角，字母转为小写，去掉空白和标点，连续相同的字只保留一个。
+//只包含空白和标点的评论，例如：？？？，去掉首尾的空白后不再处理
+func normalizeComment(msg string) string {
+	var (
+		sb   strings.Builder
+		last rune = -1
+	)
+	for _, r := range msg {
+		if r >= 0xff01 && r <= 0xff5e {
+			r -= 0xfee0
+		}
+		r = unicode.ToLower(r)
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || r == last {
+			continue
+		}
+		sb.WriteRune(r)
+		last = r
+	}
+	if sb.Len() == 0 {
+		return strings.TrimSpace(msg)
+	}
+	return sb.String()
+}
+
+//相邻两个字组成的片段，只有一个字时为这个字本身
+func commentGrams(text string) map[[2]rune]struct{} {
+	runes := []rune(text)
+	grams := make(map[[2]rune]struct{}, len(runes))
+	if len(runes) == 1 {
+		grams[[2]rune{runes[0]}] = struct{}{}
+	}
+	for i := 1; i < len(runes); i++ {
+		grams[[2]rune{runes[i-1], runes[i]}] = struct{}{}
+	}
+	return grams
+}
+
+//两条评论的相似度，使用相邻两个字组成的片段计算 Dice 系数，范围为 0-1
+func commentSimilarity(a, b spamEntry) float64 {
+	if a.text == b.text {
+		return 1
+	}
+	if len(a.grams) == 0 || len(b.grams) == 0 {
+		return 0
+	}
+	same := 0
+	for g := range a.grams {
+		if _, ok := b.grams[g]; ok {
+			same++
+		}
+	}
+	return 2 * float64(same) / float64(len(a.grams)+len(b.grams))
+}
+
+// Enabled 是否开启了刷屏检测
+func (s *SpamDetector) Enabled() bool {
+	return s.duplicates > 0 || s.rate > 0
+}
+
+// Check 记录评论并判断是否为刷屏，返回标记的原因，多个原因使用逗号分隔，正常的评论返回空字符串。
+//被标记的评论同样会加入窗口中，持续刷屏时之后的评论也会被标记
+func (s *SpamDetector) Check(comment Comment) string {
+	if !s.Enabled() {
+		return ""
+	}
+	//移除窗口外的评论，评论不一定按发送时间的顺序获取到
+	begin := int64(comment.ctime) - s.window
+	i := 0
+	for _, e := range s.entries {
+		if int64(e.ctime) > begin {
+			s.entries[i] = e
+			i++
+		}
+	}
+	s.entries = s.entries[:i]
+
+	text := normalizeComment(comment.msg)
+	entry := spamEntry{ctime: comment.ctime, uid: comment.uid, text: text}
+	if s.similarity < 1 {
+		entry.grams = commentGrams(text)
+	}
+	similar, sent := 0, 0
+	for _, e := range s.entries {
+		if e.uid == comment.uid {
+			sent++
+		}
+		if s.duplicates > 0 && text != "" && commentSimilarity(entry, e) >= s.similarity {
+			similar++
+		}
+	}
+	s.entries = append(s.entries, entry)
+
+	var reasons []string
+	if s.duplicates > 0 && similar >= s.duplicates {
+		reasons = append(reasons, report.SpamDuplicate)
+	}
+	if s.rate > 0 && sent >= s.rate {
+		reasons = append(reasons, report.SpamRate)
+	}
+	return strings.Join(reasons, ",")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+)
+
+func TestNormalizeComment(t *testing.T) {
+	tests := []struct {
+		msg, want string
+	}{
+		{"晚安啵啵！！！", "晚安啵"},
+		{"ＡＷＳＬ，awsl", "awslawsl"},
+		{"哈哈哈哈哈哈", "哈"},
+		{"？？？ ", "？？？"},
+	}
+	for _, tt := range tests {
+		if got := normalizeComment(tt.msg); got != tt.want {
+			t.Errorf("%q: want %q, got %q", tt.msg, tt.want, got)
+		}
+	}
+}
+
+func TestSpamDetector(t *testing.T) {
+	s := NewSpamDetector(SpamOption{window: 60, duplicates: 2, similarity: 0.8, rate: 3})
+	comment := func(uid, ctime uint64, msg string) Comment {
+		return Comment{Account: Account{uid: uid}, ctime: ctime, msg: msg}
+	}
+	tests := []struct {
+		comment Comment
+		want    string
+	}{
+		{comment(1, 100, "生日快乐啵啵子！"), ""},
+		{comment(2, 101, "生日快乐啵啵子"), ""},
+		//相似的评论，窗口内已经有2条相似的评论
+		{comment(3, 102, "生日快乐啵啵子~~"), report.SpamDuplicate},
+		{comment(4, 103, "生日快乐啵啵"), report.SpamDuplicate},
+		{comment(1, 104, "今天的歌好好听"), ""},
+		{comment(1, 105, "新衣服太可爱了"), ""},
+		{comment(1, 106, "生日快乐啵啵子"), report.SpamDuplicate + "," + report.SpamRate},
+		//之前的评论已经移出窗口
+		{comment(3, 170, "生日快乐啵啵子"), ""},
+		{comment(1, 171, "晚安"), ""},
+	}
+	for i, tt := range tests {
+		if got := s.Check(tt.comment); got != tt.want {
+			t.Errorf("%d %q: want %q, got %q", i, tt.comment.msg, tt.want, got)
+		}
+	}
+
+	s = NewSpamDetector(SpamOption{window: 60, similarity: 0.8})
+	for i := 0; i < 10; i++ {
+		if got := s.Check(comment(1, 100, "复读")); got != "" || s.Enabled() {
+			t.Fatalf("disabled: got %q", got)
+		}
+	}
+}
+
+func TestDB_Rebuild_spam(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	const from = 6000
+	for i, spam := range []string{"", report.SpamDuplicate, report.SpamDuplicate + "," + report.SpamRate, ""} {
+		c := Comment{Account: Account{uid: uint64(i % 2)}, oid: 10, replyId: uint64(i + 1),
+			ctime: uint64(from + i*70), msg: "复读"}
+		d.InsertComment(CommentRecord{Comment: c, likeTime: int64(c.ctime) + 2, spam: spam})
+	}
+	d.Flush()
+	if got, err := d.Comments(CommentQuery{oid: 10}); err != nil || len(got) != 4 || got[1].spam != report.SpamDuplicate {
+		t.Fatalf("comments: got %+v, err=%v", got, err)
+	}
+
+	summary, err := d.Rebuild(10, 1, from, from+3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Board.Count != 2 || !reflect.DeepEqual(summary.Board.Hot, []int{1, 0, 0, 1}) {
+		t.Errorf("board: got count=%d, hot=%v", summary.Board.Count, summary.Board.Hot)
+	}
+	want := report.SpamStats{Count: 2, Duplicate: 2, Rate: 1, Hot: []int{0, 1, 1}, People: map[uint64]int{0: 1, 1: 1}}
+	if !reflect.DeepEqual(summary.Board.Spam, want) {
+		t.Errorf("spam: want %+v, got %+v", want, summary.Board.Spam)
+	}
+}
+
+func TestDB_Commenters_spam(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	comments := []struct {
+		uid  uint64
+		spam string
+	}{
+		{1, ""}, {1, report.SpamDuplicate}, {1, report.SpamDuplicate}, {1, report.SpamRate},
+		{2, ""}, {2, ""},
+		//只发送了刷屏评论的用户
+		{3, report.SpamDuplicate},
+	}
+	for i, c := range comments {
+		comment := Comment{Account: Account{uid: c.uid, uname: "u"}, oid: 10, replyId: uint64(i + 1),
+			ctime: uint64(100 + i), msg: "复读"}
+		d.InsertComment(CommentRecord{Comment: comment, likeTime: int64(comment.ctime), spam: c.spam})
+	}
+	d.Flush()
+	//旧版本的数据库中 spam 列为 null
+	if _, err := d.conn.Exec("insert into comment(oid, rpid, ctime, uid, uname, spam) values (10, 100, 200, 4, 'u', null)"); err != nil {
+		t.Fatal(err)
+	}
+
+	top, err := d.TopCommenters(10, 0, 0, 10)
+	want := []CommenterCount{{uid: 2, uname: "u", count: 2}, {uid: 1, uname: "u", count: 1}, {uid: 4, uname: "u", count: 1}}
+	if err != nil || !reflect.DeepEqual(top, want) {
+		t.Errorf("TopCommenters: want %v, got %v, err=%v", want, top, err)
+	}
+	if count, err := d.CountCommenters(10, 0, 0); err != nil || count != 3 {
+		t.Errorf("CountCommenters: want 3, got %d, err=%v", count, err)
+	}
+}
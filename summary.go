@@ -0,0 +1,347 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+)
+
+// SummaryRecord 数据库中保存的数据总结的主要指标，完整的数据总结见 DB.LoadSummary
+type SummaryRecord struct {
+	id             int64
+	oid            uint64
+	boardName      string
+	uid            uint64
+	start          int64 //统计的开始时间
+	end            int64 //统计的结束时间
+	commentCount   int   //记录到的评论数
+	peopleCount    int   //参与评论的人数
+	peakHot        int   //每分钟评论数的最大值
+	peakTime       int64 //评论数最多的一分钟的开始时间
+	maxDelay       int   //最大延迟，单位：秒
+	startFollowers int
+	endFollowers   int
+	startAllCount  int
+	endAllCount    int
+	interrupted    bool   //是否为程序中断时生成，可以用于恢复
+	file           string //对应的json文件
+	window         string //对应的统计时段名称
+}
+
+//summary 表中查询的列，和 scanSummary 中的顺序一致
+const summaryColumns = `id, oid, board_name, uid, start_time, end_time, comment_count, people_count,
+peak_hot, peak_time, max_delay, start_followers, end_followers, start_all_count, end_all_count,
+interrupted, file, window_name`
+
+func scanSummary(scan func(dest ...any) error) (SummaryRecord, error) {
+	var r SummaryRecord
+	err := scan(&r.id, &r.oid, &r.boardName, &r.uid, &r.start, &r.end, &r.commentCount, &r.peopleCount,
+		&r.peakHot, &r.peakTime, &r.maxDelay, &r.startFollowers, &r.endFollowers, &r.startAllCount,
+		&r.endAllCount, &r.interrupted, &r.file, &r.window)
+	return r, err
+}
+
+//计算数据总结的主要指标
+func newSummaryRecord(s report.Summary, file string, interrupted bool) SummaryRecord {
+	r := SummaryRecord{
+		oid:            s.Board.Oid,
+		boardName:      s.Board.Name,
+		uid:            s.Account.Uid,
+		start:          s.Start,
+		end:            s.End,
+		commentCount:   s.Board.Count,
+		peopleCount:    len(s.Board.People),
+		startFollowers: s.Account.StartFollowers,
+		endFollowers:   s.Account.EndFollowers,
+		startAllCount:  s.Board.StartAllCount,
+		endAllCount:    s.Board.EndAllCount,
+		interrupted:    interrupted,
+		file:           file,
+		window:         s.Window,
+	}
+	for i, hot := range s.Board.Hot {
+		if hot > r.peakHot {
+			r.peakHot = hot
+			r.peakTime = s.Start + int64(i)*60
+		}
+	}
+	for _, delay := range s.Board.Awl {
+		if delay > r.maxDelay {
+			r.maxDelay = delay
+		}
+	}
+	return r
+}
+
+// InsertSummary 保存数据总结，file 为对应的json文件，interrupted 表示是否为程序中断时生成
+func (d *DB) InsertSummary(s report.Summary, file string, interrupted bool) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		d.logger.Error("InsertSummary: marshal, %v", err)
+		return
+	}
+	r := newSummaryRecord(s, file, interrupted)
+	d.writer.add("summary", r.oid, r.boardName, r.uid, r.start, r.end, r.commentCount, r.peopleCount,
+		r.peakHot, r.peakTime, r.maxDelay, r.startFollowers, r.endFollowers, r.startAllCount, r.endAllCount,
+		r.interrupted, r.file, string(data), r.window)
+	d.logger.Debug("InsertSummary，oid=%d, start=%d, end=%d", r.oid, r.start, r.end)
+}
+
+// Summaries 查询结束时间在 [from, to] 内的数据总结，按结束时间降序排列，零值表示不限制该条件
+func (d *DB) Summaries(oid uint64, from, to int64, limit int) ([]SummaryRecord, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if oid != 0 {
+		conds = append(conds, "oid = ?")
+		args = append(args, oid)
+	}
+	if from != 0 {
+		conds = append(conds, "end_time >= ?")
+		args = append(args, from)
+	}
+	if to != 0 {
+		conds = append(conds, "end_time <= ?")
+		args = append(args, to)
+	}
+	query := "select " + summaryColumns + " from summary"
+	if len(conds) > 0 {
+		query += " where " + strings.Join(conds, " and ")
+	}
+	query += " order by end_time desc, id desc"
+	if limit > 0 {
+		query += " limit ?"
+		args = append(args, limit)
+	}
+	rows, err := d.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []SummaryRecord
+	for rows.Next() {
+		r, err := scanSummary(rows.Scan)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// LoadSummary 读取完整的数据总结，id 为0时读取所有评论区和统计时段中最近的一条，
+//恢复时使用 DB.LatestSummary 读取同一个评论区和统计时段中最近的一条
+func (d *DB) LoadSummary(id int64) (report.Summary, error) {
+	var (
+		summary report.Summary
+		data    string
+		err     error
+	)
+	if id == 0 {
+		err = d.conn.QueryRow("select data from summary order by end_time desc, id desc limit 1").Scan(&data)
+	} else {
+		err = d.conn.QueryRow("select data from summary where id = ?", id).Scan(&data)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return summary, fmt.Errorf("数据总结不存在，id=%d", id)
+	}
+	if err != nil {
+		return summary, err
+	}
+	err = json.Unmarshal([]byte(data), &summary)
+	return summary, err
+}
+
+// LatestSummary 读取评论区 oid 的统计时段 window 中最近的一条数据总结
+func (d *DB) LatestSummary(oid uint64, window string) (report.Summary, error) {
+	var (
+		summary report.Summary
+		data    string
+	)
+	err := d.conn.QueryRow(`select data from summary where oid = ? and window_name = ?
+order by end_time desc, id desc limit 1`, oid, window).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return summary, fmt.Errorf("数据总结不存在，oid=%d, window=%q", oid, window)
+	}
+	if err != nil {
+		return summary, err
+	}
+	err = json.Unmarshal([]byte(data), &summary)
+	return summary, err
+}
+
+//数据总结中保存的上升最快的词语数量
+const trendingTop = 10
+
+//同一个评论区和统计时段中，在 before 之前开始的最近一条数据总结
+func (d *DB) previousSummary(oid uint64, window string, before int64) (report.Summary, error) {
+	var (
+		summary report.Summary
+		data    string
+	)
+	err := d.conn.QueryRow(`select data from summary where oid = ? and window_name = ? and start_time < ?
+order by end_time desc, id desc limit 1`, oid, window, before).Scan(&data)
+	if err != nil {
+		return summary, err
+	}
+	err = json.Unmarshal([]byte(data), &summary)
+	return summary, err
+}
+
+//和同一个统计时段的上一条数据总结比较，计算上升最快的词语，没有上一条数据总结时不计算
+func (d *DB) addTrending(s *report.Summary) {
+	prev, err := d.previousSummary(s.Board.Oid, s.Window, s.Start)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			d.logger.Error("addTrending: query, %v", err)
+		}
+		return
+	}
+	s.Board.Stats.Trending = report.Trending(s.Board.Stats, prev.Board.Stats, trendingTop)
+}
+
+//解析 -r 参数中的数据库恢复信息，格式为 db:latest 或 db:<id>，ok 为 false 表示不是从数据库中恢复
+func parseRecoverID(s string) (id int64, ok bool, err error) {
+	if !strings.HasPrefix(s, "db:") {
+		return 0, false, nil
+	}
+	spec := strings.TrimPrefix(s, "db:")
+	if spec == "latest" {
+		return 0, true, nil
+	}
+	id, err = strconv.ParseInt(spec, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, true, fmt.Errorf("错误的数据总结id：%s", spec)
+	}
+	return id, true, nil
+}
+
+//格式化时间段
+func formatPeriod(start, end int64) string {
+	return time.Unix(start, 0).Format("01-02 15:04") + " ~ " + time.Unix(end, 0).Format("01-02 15:04")
+}
+
+//查看和比较保存在数据库中的数据总结
+func summariesCmd(args []string) int {
+	fs := flag.NewFlagSet("summaries", flag.ExitOnError)
+	dbname := fs.String("db", settingDBName(), "数据库文件名")
+	from := fs.String("from", "", "结束时间的起点，例如：2022-07-01 或 2022-07-01 12:00")
+	to := fs.String("to", "", "结束时间的终点，格式同 from")
+	oid := fs.Uint64("board", 0, "只显示该评论区的数据总结")
+	limit := fs.Int("limit", 20, "最多显示的数量，为0时不限制")
+	fs.Usage = func() {
+		fmt.Println("usage: bobo-bot summaries [options]")
+		fmt.Println("       bobo-bot summaries [options] compare <id> <id>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	d := NewDB(*dbname, DBOption{})
+	if d == nil {
+		return 1
+	}
+	defer d.Close()
+	switch fs.Arg(0) {
+	case "":
+		start, err := parseTime(*from, false)
+		if err != nil {
+			mainLogger.Error("错误的开始时间：%v", err)
+			return 2
+		}
+		end, err := parseTime(*to, true)
+		if err != nil {
+			mainLogger.Error("错误的结束时间：%v", err)
+			return 2
+		}
+		records, err := d.Summaries(*oid, start, end, *limit)
+		if err != nil {
+			mainLogger.Error("查询数据总结失败，%v", err)
+			return 1
+		}
+		listSummaries(records)
+		return 0
+	case "compare":
+		if fs.NArg() != 3 {
+			fs.Usage()
+			return 2
+		}
+		var summaries [2]report.Summary
+		for i, arg := range fs.Args()[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				mainLogger.Error("错误的数据总结id：%s", arg)
+				return 2
+			}
+			if summaries[i], err = d.LoadSummary(id); err != nil {
+				mainLogger.Error("读取数据总结失败，%v", err)
+				return 1
+			}
+		}
+		compareSummaries(summaries[0], summaries[1])
+		return 0
+	default:
+		fs.Usage()
+		return 2
+	}
+}
+
+//列出数据总结
+func listSummaries(records []SummaryRecord) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "id\t时间段\t评论区\t评论数\t人数\t峰值\t最大延迟\t粉丝变化\t")
+	for _, r := range records {
+		period := formatPeriod(r.start, r.end)
+		if r.interrupted {
+			period += "(中断)"
+		}
+		board := r.boardName
+		if r.window != "" {
+			board += "(" + r.window + ")"
+		}
+		peak := "-"
+		if r.peakHot > 0 {
+			peak = fmt.Sprintf("%d(%s)", r.peakHot, time.Unix(r.peakTime, 0).Format("15:04"))
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%ds\t%+d\t\n", r.id, period, board,
+			r.commentCount, r.peopleCount, peak, r.maxDelay, r.endFollowers-r.startFollowers)
+	}
+	_ = w.Flush()
+}
+
+//比较两个数据总结的主要指标
+func compareSummaries(a, b report.Summary) {
+	ra, rb := newSummaryRecord(a, "", false), newSummaryRecord(b, "", false)
+	metrics := []struct {
+		name string
+		a, b int
+	}{
+		{"时长(分钟)", int(ra.end-ra.start) / 60, int(rb.end-rb.start) / 60},
+		{"评论数", ra.commentCount, rb.commentCount},
+		{"参与人数", ra.peopleCount, rb.peopleCount},
+		{"每分钟评论峰值", ra.peakHot, rb.peakHot},
+		{"最大延迟(秒)", ra.maxDelay, rb.maxDelay},
+		{"总评论数增长", ra.endAllCount - ra.startAllCount, rb.endAllCount - rb.startAllCount},
+		{"粉丝数变化", ra.endFollowers - ra.startFollowers, rb.endFollowers - rb.startFollowers},
+		{"结束时粉丝数", ra.endFollowers, rb.endFollowers},
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "\t%s\t%s\t变化\t\n", formatPeriod(a.Start, a.End), formatPeriod(b.Start, b.End))
+	for _, m := range metrics {
+		change := fmt.Sprintf("%+d", m.b-m.a)
+		if m.a != 0 {
+			change += fmt.Sprintf("(%+.1f%%)", float64(m.b-m.a)/float64(m.a)*100)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%s\t\n", m.name, m.a, m.b, change)
+	}
+	_ = w.Flush()
+}
@@ -41,3 +41,12 @@ func sliceCheck[T any](s []T, i int) []T {
 	}
 	return s
 }
+
+func Contains[T comparable](s []T, item T) bool {
+	for _, v := range s {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/push"
+	"github.com/Hami-Lemon/bobo-bot/report"
+)
+
+// VideoOption 视频数据监控的配置，只对视频评论区生效
+type VideoOption struct {
+	interval   int                  //记录间隔，单位：分钟，为0时不监控
+	hotPages   int                  //查找热门列表的页数，为0时不检查是否进入热门
+	milestones map[string]Milestone //各项数据的里程碑，键为数据项名称
+}
+
+//视频数据项的中文名称
+var videoMetricNames = map[string]string{
+	"view":     "播放",
+	"danmaku":  "弹幕",
+	"reply":    "评论",
+	"favorite": "收藏",
+	"coin":     "投币",
+	"share":    "分享",
+	"like":     "点赞",
+}
+
+func newVideoSummary(stat VideoStat, interval int) *report.VideoSummary {
+	return &report.VideoSummary{
+		BvID:     stat.bvID,
+		Aid:      stat.aid,
+		Interval: interval,
+		Start:    stat.Metrics(),
+		End:      stat.Metrics(),
+		Growth:   make(map[string][]float64),
+		HisRank:  stat.hisRank,
+	}
+}
+
+// MonitorVideo 监控视频的统计数据，每隔 interval 分钟更新一次，只对视频评论区生效
+func (b *Bot) MonitorVideo() {
+	bvID := b.board.bvID
+	if bvID == "" || b.video.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(b.video.interval) * time.Minute)
+	defer ticker.Stop()
+
+	var (
+		last     VideoStat
+		lastTime time.Time
+		started  bool //是否已经获取到开始时的数据，获取失败时在下一次更新时重试
+		isHot    bool
+		trackers = make(map[string]*MilestoneTracker, len(b.video.milestones))
+	)
+	for name, m := range b.video.milestones {
+		trackers[name] = NewMilestoneTracker(m)
+	}
+	start := func(now time.Time) {
+		stat, ok := b.bili.VideoStat(bvID)
+		if !ok {
+			b.logger.Error("获取视频数据失败，bv=%s", bvID)
+			return
+		}
+		last, lastTime, started = stat, now, true
+		b.eachCounter(func(c *Counter) {
+			if c.video == nil {
+				c.video = newVideoSummary(stat, b.video.interval)
+			}
+		})
+		hot, ok := b.checkPopular(stat.aid)
+		updatePopular(&isHot, hot, ok)
+	}
+	start(time.Now())
+	for {
+		select {
+		case <-b.stop:
+			return
+		case now := <-ticker.C:
+			if !started {
+				start(now)
+				continue
+			}
+			stat, ok := b.bili.VideoStat(bvID)
+			if !ok {
+				b.logger.Error("获取视频数据失败，bv=%s", bvID)
+				continue
+			}
+			b.logger.Info("获取视频数据，bv=%s, view=%d, like=%d, reply=%d",
+				bvID, stat.view, stat.like, stat.reply)
+			db.InsertVideoStat(stat, now.Unix())
+			hot, ok := b.checkPopular(stat.aid)
+			b.eachCounter(func(c *Counter) {
+				c.video.Add(stat.Metrics(), stat.hisRank, now.Sub(lastTime).Minutes())
+				c.video.Hot = c.video.Hot || hot
+			})
+
+			if updatePopular(&isHot, hot, ok) {
+				b.logger.Info("视频进入热门，bv=%s", bvID)
+				pushMessage(b.logger, defaultChannel, push.Message{
+					Title: "视频进入热门",
+					Text: fmt.Sprintf("%s 进入热门\n播放：%d，点赞：%d，评论：%d",
+						bvID, stat.view, stat.like, stat.reply),
+					Link: fmt.Sprintf("https://www.bilibili.com/video/%s", bvID),
+				})
+			}
+			for _, msg := range videoMilestones(trackers, last, stat, lastTime, now) {
+				b.logger.Info("视频数据提醒：bv=%s, %s", bvID, msg.Title)
+				pushMessage(b.logger, defaultChannel, msg)
+			}
+			last, lastTime = stat, now
+		}
+	}
+}
+
+//检查视频是否在热门列表中，ok 为 false 表示获取失败或者不检查
+func (b *Bot) checkPopular(aid uint64) (hot, ok bool) {
+	if b.video.hotPages <= 0 {
+		return false, false
+	}
+	hot, err := b.bili.IsPopular(aid, b.video.hotPages)
+	if err != nil {
+		b.logger.Error("获取热门列表失败，%v", err)
+		return false, false
+	}
+	return hot, true
+}
+
+//根据检查结果更新视频是否在热门中，返回是否刚进入热门，检查失败时保持之前的状态
+func updatePopular(isHot *bool, hot, ok bool) bool {
+	if !ok {
+		return false
+	}
+	entered := hot && !*isHot
+	*isHot = hot
+	return entered
+}
+
+//视频数据的里程碑提醒，trackers 为各项数据已经提醒过的里程碑，按数据项名称排序
+func videoMilestones(trackers map[string]*MilestoneTracker, last, now VideoStat,
+	lastTime, nowTime time.Time) []push.Message {
+	lastMetrics, nowMetrics := last.Metrics(), now.Metrics()
+	names := make([]string, 0, len(trackers))
+	for name := range trackers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var messages []push.Message
+	for _, name := range names {
+		from, to := lastMetrics[name], nowMetrics[name]
+		label := videoMetricNames[name]
+		for _, m := range trackers[name].Check(from, to) {
+			title := fmt.Sprintf("视频%s数突破%d", label, m)
+			if to < from {
+				title = fmt.Sprintf("视频%s数跌破%d", label, m)
+			}
+			messages = append(messages, push.Message{
+				Title: title,
+				Text: fmt.Sprintf("%s - %s\n%s %s数：%d => %d(%+d)",
+					lastTime.Format("01-02 15:04"), nowTime.Format("01-02 15:04"),
+					now.bvID, label, from, to, to-from),
+				Link: fmt.Sprintf("https://www.bilibili.com/video/%s", now.bvID),
+			})
+		}
+	}
+	return messages
+}
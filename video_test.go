@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewVideoSummary(t *testing.T) {
+	start := VideoStat{aid: 170001, bvID: "BV17x411w7KC", view: 1000, like: 100, hisRank: 50}
+	v := newVideoSummary(start, 10)
+	if v.BvID != start.bvID || v.Aid != start.aid || v.Interval != 10 || v.HisRank != 50 ||
+		!reflect.DeepEqual(v.Start, start.Metrics()) || !reflect.DeepEqual(v.End, start.Metrics()) {
+		t.Fatalf("got %+v", v)
+	}
+	now := start
+	now.view, now.like, now.hisRank = 1600, 130, 20
+	v.Add(now.Metrics(), now.hisRank, 10)
+	if v.Growth["view"][0] != 60 || v.Growth["like"][0] != 3 || v.Growth["coin"][0] != 0 {
+		t.Errorf("growth: got %v", v.Growth)
+	}
+	if !reflect.DeepEqual(v.End, now.Metrics()) || v.HisRank != 20 {
+		t.Errorf("end: got %v, hisRank=%d", v.End, v.HisRank)
+	}
+}
+
+func TestVideoMilestones(t *testing.T) {
+	trackers := map[string]*MilestoneTracker{
+		"view": NewMilestoneTracker(Milestone{step: 1000}),
+		"like": NewMilestoneTracker(Milestone{values: []int{100}}),
+	}
+	lastTime := time.Date(2022, 7, 2, 8, 0, 0, 0, time.Local)
+	stat := func(view, like int) VideoStat {
+		return VideoStat{bvID: "BV1", view: view, like: like}
+	}
+	tests := []struct {
+		last, now VideoStat
+		want      []string
+	}{
+		{stat(990, 99), stat(1010, 101), []string{"视频点赞数突破100", "视频播放数突破1000"}},
+		//在里程碑附近波动时不重复提醒
+		{stat(1010, 101), stat(1010, 99), nil},
+		{stat(1010, 99), stat(1010, 100), nil},
+		{stat(1010, 100), stat(2100, 100), []string{"视频播放数突破2000"}},
+		{stat(2100, 100), stat(900, 100), []string{"视频播放数跌破1000"}},
+	}
+	for i, tt := range tests {
+		var got []string
+		for _, msg := range videoMilestones(trackers, tt.last, tt.now, lastTime, lastTime.Add(10*time.Minute)) {
+			got = append(got, msg.Title)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestUpdatePopular(t *testing.T) {
+	isHot := false
+	tests := []struct {
+		hot, ok bool
+		entered bool
+		isHot   bool
+	}{
+		{true, true, true, true},
+		//获取失败时保持之前的状态，再次获取成功时不重复提醒
+		{false, false, false, true},
+		{true, true, false, true},
+		{false, true, false, false},
+		{true, true, true, true},
+	}
+	for i, tt := range tests {
+		if entered := updatePopular(&isHot, tt.hot, tt.ok); entered != tt.entered || isHot != tt.isHot {
+			t.Errorf("%d: want entered=%v, isHot=%v, got %v, %v", i, tt.entered, tt.isHot, entered, isHot)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Watch 关注列表中的用户，该用户发送评论时会推送提醒
+type Watch struct {
+	uid      uint64   //用户的uid
+	alias    string   //别名
+	channel  string   //推送渠道，为空时使用默认的推送渠道
+	keywords []string //关键词，不为空时只有评论中包含任一关键词才会提醒
+}
+
+func (w Watch) String() string {
+	channel := w.channel
+	if channel == "" {
+		channel = defaultChannel
+	}
+	return fmt.Sprintf("uid=%d, alias=%s, channel=%s, keywords=[%s]",
+		w.uid, w.alias, channel, strings.Join(w.keywords, ","))
+}
+
+// Match 判断评论是否满足该用户的提醒条件
+func (w Watch) Match(comment Comment) bool {
+	if comment.uid != w.uid {
+		return false
+	}
+	if len(w.keywords) == 0 {
+		return true
+	}
+	for _, keyword := range w.keywords {
+		if strings.Contains(comment.msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watchlist 关注列表，可以在运行时通过控制台修改
+type Watchlist struct {
+	items map[uint64]Watch
+	lock  sync.RWMutex
+}
+
+func NewWatchlist(items []Watch) *Watchlist {
+	w := &Watchlist{items: make(map[uint64]Watch, len(items))}
+	for _, item := range items {
+		w.items[item.uid] = item
+	}
+	return w
+}
+
+// Get 查找关注的用户，ok 为 false 表示该用户不在关注列表中
+func (w *Watchlist) Get(uid uint64) (watch Watch, ok bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	watch, ok = w.items[uid]
+	return
+}
+
+// Match 查找评论对应的关注用户，ok 为 false 表示该评论不需要提醒
+func (w *Watchlist) Match(comment Comment) (watch Watch, ok bool) {
+	watch, ok = w.Get(comment.uid)
+	if !ok {
+		return
+	}
+	return watch, watch.Match(comment)
+}
+
+// Add 添加或更新关注的用户
+func (w *Watchlist) Add(watch Watch) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.items[watch.uid] = watch
+}
+
+// Remove 移除关注的用户，返回该用户是否在关注列表中
+func (w *Watchlist) Remove(uid uint64) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, ok := w.items[uid]
+	delete(w.items, uid)
+	return ok
+}
+
+// List 获取关注列表，按uid排序
+func (w *Watchlist) List() []Watch {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	list := make([]Watch, 0, len(w.items))
+	for _, item := range w.items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].uid < list[j].uid
+	})
+	return list
+}
+
+//处理控制台中的 watch 命令
+//watch list：查看关注列表
+//watch add <uid> <alias> [channel] [keyword...]：添加关注的用户，channel 为 - 时使用默认渠道
+//watch del <uid>：移除关注的用户
+func watchCmd(w *Watchlist, args []string) {
+	if len(args) == 0 {
+		mainLogger.Warn("usage: watch list|add|del")
+		return
+	}
+	switch args[0] {
+	case "list":
+		list := w.List()
+		mainLogger.Info("关注列表，共%d个用户", len(list))
+		for _, item := range list {
+			mainLogger.Info("%s", item)
+		}
+	case "add":
+		if len(args) < 3 {
+			mainLogger.Warn("usage: watch add <uid> <alias> [channel] [keyword...]")
+			return
+		}
+		uid, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			mainLogger.Warn("错误的uid：%s", args[1])
+			return
+		}
+		watch := Watch{uid: uid, alias: args[2]}
+		if len(args) > 3 && args[3] != "-" {
+			if _, ok := channels[args[3]]; !ok {
+				mainLogger.Warn("推送渠道不存在：%s", args[3])
+				return
+			}
+			watch.channel = args[3]
+		}
+		if len(args) > 4 {
+			watch.keywords = args[4:]
+		}
+		w.Add(watch)
+		mainLogger.Info("添加关注：%s", watch)
+	case "del":
+		if len(args) < 2 {
+			mainLogger.Warn("usage: watch del <uid>")
+			return
+		}
+		uid, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			mainLogger.Warn("错误的uid：%s", args[1])
+			return
+		}
+		if w.Remove(uid) {
+			mainLogger.Info("移除关注：uid=%d", uid)
+		} else {
+			mainLogger.Warn("关注列表中没有该用户：uid=%d", uid)
+		}
+	default:
+		mainLogger.Warn("usage: watch list|add|del")
+	}
+}
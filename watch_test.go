@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/push"
+)
+
+func TestWatchlist_Match(t *testing.T) {
+	w := NewWatchlist([]Watch{
+		{uid: 1, alias: "啵啵"},
+		{uid: 2, alias: "三三", channel: "tg", keywords: []string{"直播", "好耶"}},
+	})
+	comment := func(uid uint64, msg string) Comment {
+		return Comment{Account: Account{uid: uid}, msg: msg}
+	}
+	tests := []struct {
+		name    string
+		comment Comment
+		want    bool
+		alias   string
+	}{
+		{"uid without keywords", comment(1, "晚安"), true, "啵啵"},
+		{"keyword", comment(2, "今天直播吗"), true, "三三"},
+		{"another keyword", comment(2, "好耶！"), true, "三三"},
+		{"no keyword", comment(2, "晚安"), false, "三三"},
+		{"keyword from other uid", comment(3, "直播好耶"), false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			watch, ok := w.Match(test.comment)
+			if ok != test.want || watch.alias != test.alias {
+				t.Errorf("want %v, %s, got %v, %s", test.want, test.alias, ok, watch.alias)
+			}
+		})
+	}
+}
+
+//记录收到的消息
+type recordPusher chan push.Message
+
+func (r recordPusher) Push(msg push.Message) error {
+	r <- msg
+	return nil
+}
+
+func TestPushMessage_channel(t *testing.T) {
+	defaultPusher, tg := make(recordPusher, 1), make(recordPusher, 1)
+	oldPusher, oldChannels := pusher, channels
+	defer func() {
+		pusher, channels = oldPusher, oldChannels
+	}()
+	pusher = defaultPusher
+	channels = map[string]push.Pusher{defaultChannel: defaultPusher, "tg": tg}
+
+	//关注用户的评论推送到对应的渠道，渠道不存在时使用默认渠道
+	tests := []struct {
+		channel string
+		want    recordPusher
+	}{
+		{"tg", tg},
+		{"", defaultPusher},
+		{"missing", defaultPusher},
+	}
+	for _, test := range tests {
+		pushMessage(mainLogger, test.channel, push.NewText("%s", test.channel))
+		select {
+		case msg := <-test.want:
+			if msg.Text != test.channel {
+				t.Errorf("%q: got %q", test.channel, msg.Text)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%q: message not pushed to the expected channel", test.channel)
+		}
+	}
+}
+
+func TestWatchCmd(t *testing.T) {
+	oldChannels := channels
+	defer func() {
+		channels = oldChannels
+	}()
+	channels = map[string]push.Pusher{defaultChannel: nil, "tg": nil}
+
+	w := NewWatchlist(nil)
+	tests := []struct {
+		cmd  string
+		want []Watch
+	}{
+		{"watch add 2 三三 tg 直播 好耶", []Watch{{uid: 2, alias: "三三", channel: "tg", keywords: []string{"直播", "好耶"}}}},
+		{"watch add 1 啵啵", []Watch{{uid: 1, alias: "啵啵"}, {uid: 2, alias: "三三", channel: "tg", keywords: []string{"直播", "好耶"}}}},
+		//已经关注的用户会被更新
+		{"watch add 2 三三子 - 晚安", []Watch{{uid: 1, alias: "啵啵"}, {uid: 2, alias: "三三子", keywords: []string{"晚安"}}}},
+		//错误的参数不修改关注列表
+		{"watch add 3 灯灯 missing", []Watch{{uid: 1, alias: "啵啵"}, {uid: 2, alias: "三三子", keywords: []string{"晚安"}}}},
+		{"watch add abc 灯灯", []Watch{{uid: 1, alias: "啵啵"}, {uid: 2, alias: "三三子", keywords: []string{"晚安"}}}},
+		{"watch add 3", []Watch{{uid: 1, alias: "啵啵"}, {uid: 2, alias: "三三子", keywords: []string{"晚安"}}}},
+		{"watch del 1", []Watch{{uid: 2, alias: "三三子", keywords: []string{"晚安"}}}},
+		//移除不在关注列表中的用户
+		{"watch del 1", []Watch{{uid: 2, alias: "三三子", keywords: []string{"晚安"}}}},
+		{"watch list", []Watch{{uid: 2, alias: "三三子", keywords: []string{"晚安"}}}},
+		{"watch del 2", []Watch{}},
+	}
+	for _, test := range tests {
+		watchCmd(w, strings.Fields(test.cmd)[1:])
+		if got := w.List(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: want %v, got %v", test.cmd, test.want, got)
+		}
+	}
+}
+
+func TestBot_commentAlert(t *testing.T) {
+	b := &Bot{
+		monitor:   MonitorAccount{Account: Account{uid: 1, alias: "啵啵"}},
+		watchlist: NewWatchlist([]Watch{{uid: 2, alias: "三三", channel: "tg"}}),
+	}
+	comment := func(uid uint64, msg string) Comment {
+		return Comment{Account: Account{uid: uid}, msg: msg}
+	}
+	tests := []struct {
+		name           string
+		comment        Comment
+		channel, alias string
+		ok             bool
+	}{
+		{"monitor", comment(1, "晚安"), defaultChannel, "啵啵", true},
+		{"watch", comment(2, "晚安"), "tg", "三三", true},
+		{"other", comment(3, "晚安"), "", "", false},
+	}
+	check := func() {
+		for _, test := range tests {
+			channel, alias, ok := b.commentAlert(test.comment)
+			if channel != test.channel || alias != test.alias || ok != test.ok {
+				t.Errorf("%s: want %s, %s, %v, got %s, %s, %v", test.name,
+					test.channel, test.alias, test.ok, channel, alias, ok)
+			}
+		}
+	}
+	check()
+
+	//监控的账号也在关注列表中时，使用关注列表中的关键词和渠道
+	b.watchlist.Add(Watch{uid: 1, alias: "啵啵子", channel: "tg", keywords: []string{"直播"}})
+	tests = tests[:1]
+	tests[0].comment, tests[0].channel, tests[0].alias, tests[0].ok = comment(1, "晚安"), "tg", "啵啵子", false
+	check()
+	tests[0].comment, tests[0].ok = comment(1, "今天直播吗"), true
+	check()
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+	"github.com/Hami-Lemon/bobo-bot/set"
+	"github.com/tidwall/gjson"
+)
+
+//默认最多补全的数据总结数量
+const defaultCatchUp = 24
+
+//统计时段的名称只能包含字母、数字、下划线和短横线，用于文件名
+var windowNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]*$`)
+
+// WindowOption 数据总结的统计时段，在 schedule 的触发时间生成数据总结，然后重新开始统计
+type WindowOption struct {
+	name     string    //名称，不为空时作为数据总结文件和检查点文件的前缀
+	schedule *Schedule //生成数据总结的时间
+}
+
+//文件名的前缀
+func (w WindowOption) prefix() string {
+	if w.name == "" {
+		return ""
+	}
+	return w.name + "-"
+}
+
+//t 时生成的数据总结文件
+func (w WindowOption) summaryFile(t time.Time) string {
+	return fmt.Sprintf("./report/%s%s.json", w.prefix(), t.Format("200601021504"))
+}
+
+//检查点文件，没有名称时为 checkpointFile
+func (w WindowOption) checkpointFile() string {
+	if w.name == "" {
+		return checkpointFile
+	}
+	return fmt.Sprintf("./report/checkpoint-%s.json", w.name)
+}
+
+// SummaryWindow 运行中的统计时段，每个统计时段有独立的统计器，生成数据总结时只重置自己的统计器
+type SummaryWindow struct {
+	WindowOption
+	counter *Counter  //统计器
+	next    time.Time //下一次生成数据总结的时间
+}
+
+//读取计算触发时间使用的时区，没有配置 timezone 时使用本地时区
+func readLocation(config gjson.Result) (*time.Location, error) {
+	tz := config.Get("timezone").String()
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("错误的时区：%s，%w", tz, err)
+	}
+	return loc, nil
+}
+
+//读取统计时段的配置，没有配置 windows 时使用 hour 和 minute，生成的统计时段没有名称
+func readWindows(config gjson.Result) ([]WindowOption, error) {
+	loc, err := readLocation(config)
+	if err != nil {
+		return nil, err
+	}
+	items := config.Get("windows").Array()
+	if len(items) == 0 {
+		schedule, err := legacySchedule(int(config.Get("hour").Int()), int(config.Get("minute").Int()), loc)
+		if err != nil {
+			return nil, err
+		}
+		return []WindowOption{{schedule: schedule}}, nil
+	}
+	windows := make([]WindowOption, 0, len(items))
+	names := set.New[string]()
+	for _, item := range items {
+		name := item.Get("name").String()
+		if !windowNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("统计时段的名称只能包含字母、数字、下划线和短横线：%q", name)
+		}
+		if names.Contains(name) {
+			return nil, fmt.Errorf("重复的统计时段名称：%q", name)
+		}
+		names.Add(name)
+		schedule, err := ParseSchedule(item.Get("cron").String(), loc)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, WindowOption{name: name, schedule: schedule})
+	}
+	return windows, nil
+}
+
+//创建统计器，统计的开始时间为 start
+func newCounter(window string, start time.Time) *Counter {
+	return &Counter{
+		window:      window,
+		peopleCount: make(map[uint64]int),
+		hotCount:    make([]int, 0, CountCap),
+		awlCount:    make([]int, 0, CountCap),
+		latency:     make([]report.Latency, 0, CountCap),
+		stats:       report.NewCommentStats(),
+		minuteUsers: make(map[int]*set.HashSet[uint64]),
+		hourUsers:   make(map[int]*set.HashSet[uint64]),
+		fansCount:   make([]int, 0),
+		statCount:   make(map[string][]int),
+		startTime:   start,
+	}
+}
+
+//创建所有的统计时段，开始时间为 now，开始时的评论数和粉丝数为评论区和账号当前的数据
+func (b *Bot) initWindows(now time.Time) {
+	b.windows = make([]*SummaryWindow, 0, len(b.BotOption.windows))
+	for _, opt := range b.BotOption.windows {
+		b.windows = append(b.windows, &SummaryWindow{
+			WindowOption: opt,
+			counter:      b.newWindowCounter(opt.name, now),
+			next:         opt.schedule.Next(now),
+		})
+	}
+	b.counter = b.windows[0].counter
+}
+
+//创建统计时段的统计器，开始时的评论数和粉丝数为评论区和账号当前的数据
+func (b *Bot) newWindowCounter(window string, now time.Time) *Counter {
+	counter := newCounter(window, now)
+	counter.startAllCount = b.board.allCount
+	counter.startCount = b.board.count
+	counter.startFollowers = b.monitor.follower
+	counter.fansCount = append(counter.fansCount, b.monitor.follower)
+	return counter
+}
+
+//查找统计时段，不存在时返回nil
+func (b *Bot) window(name string) *SummaryWindow {
+	for _, w := range b.windows {
+		if w.name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+//对每个统计时段的统计器执行 f，调用时持有统计器的锁
+func (b *Bot) eachCounter(f func(c *Counter)) {
+	for _, w := range b.windows {
+		w.counter.lock.Lock()
+		f(w.counter)
+		w.counter.lock.Unlock()
+	}
+}
+
+//生成统计时段的数据总结，新的统计时段开始后保存检查点，避免崩溃后从上一个时段的检查点恢复
+func (b *Bot) summarizeWindow(w *SummaryWindow) {
+	fileName, summary := b.Summarize(w, false)
+	b.checkpointWindow(w)
+	if fileName != "" {
+		b.ReportSummarize(summary)
+	}
+}
+
+// RunWindows 每分钟检查一次，到达统计时段的触发时间时生成数据总结。
+//程序运行期间错过的触发时间（例如系统休眠）不会补全，统计器会包含这段时间的数据
+func (b *Bot) RunWindows() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case now := <-ticker.C:
+			for _, w := range b.windows {
+				if w.next.IsZero() || now.Before(w.next) {
+					continue
+				}
+				b.summarizeWindow(w)
+				w.next = w.schedule.Next(now)
+				b.logger.Info("下一次生成数据总结：%s, %s", w.name, w.next.Format("01-02 15:04"))
+			}
+		}
+	}
+}
+
+//统计时段最近一次数据总结的结束时间，没有数据总结时返回0
+func (d *DB) lastSummaryEnd(oid uint64, window string) (int64, error) {
+	var end int64
+	err := d.conn.QueryRow("select end_time from summary where oid = ? and window_name = ? order by end_time desc limit 1",
+		oid, window).Scan(&end)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return end, err
+}
+
+//是否已经保存了评论区 oid 的统计时段 window 中从 start 开始的数据总结
+func (d *DB) hasSummary(oid uint64, window string, start int64) (bool, error) {
+	var exists bool
+	err := d.conn.QueryRow("select exists(select 1 from summary where oid = ? and window_name = ? and start_time = ?)",
+		oid, window, start).Scan(&exists)
+	return exists, err
+}
+
+//统计时段在 (last, now] 中错过的触发时间，最多返回最近的 n 个
+func missedRuns(schedule *Schedule, last, now time.Time, n int) []time.Time {
+	var runs []time.Time
+	for t := schedule.Next(last); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		runs = append(runs, t)
+	}
+	if len(runs) > n {
+		runs = runs[len(runs)-n:]
+	}
+	return runs
+}
+
+// CatchUp 补全程序停止期间错过的数据总结，每个统计时段最多补全最近的 catchUp 个。
+//错过的数据总结使用数据库中的数据重新生成，统计时段为两次触发时间之间，只保存不发布动态。
+//没有数据总结的统计时段不补全
+func (b *Bot) CatchUp(now time.Time) {
+	if b.catchUp <= 0 {
+		return
+	}
+	//恢复检查点时可能写入了中断时的数据总结
+	if err := db.Flush(); err != nil {
+		b.logger.Error("写入数据失败，%v", err)
+	}
+	for _, w := range b.windows {
+		last, err := db.lastSummaryEnd(b.board.oid, w.name)
+		if err != nil {
+			b.logger.Error("查询数据总结失败，window=%s, %v", w.name, err)
+			continue
+		}
+		if last == 0 {
+			continue
+		}
+		runs := missedRuns(w.schedule, time.Unix(last, 0), now, b.catchUp)
+		for _, t := range runs {
+			from := w.schedule.Prev(t.Add(-time.Minute))
+			summary, err := db.Rebuild(b.board.oid, b.monitor.uid, from.Unix(), t.Unix())
+			if err != nil {
+				b.logger.Error("补全数据总结失败，window=%s, %v", w.name, err)
+				break
+			}
+			summary.Window = w.name
+			//上一个补全的数据总结需要写入后才能比较词语
+			if err = db.Flush(); err != nil {
+				b.logger.Error("写入数据失败，%v", err)
+			}
+			db.addTrending(&summary)
+			fileName := w.summaryFile(t)
+			data, _ := json.Marshal(summary)
+			if err = writeFileAtomic(fileName, data); err != nil {
+				b.logger.Error("保存数据总结失败，%v", err)
+				fileName = ""
+			}
+			db.InsertSummary(summary, fileName, false)
+			b.logger.Info("补全数据总结：%s, %s, 评论数：%d", w.name, formatPeriod(summary.Start, summary.End),
+				summary.Board.Count)
+		}
+		if len(runs) > 0 {
+			pushAndLog(b.logger, "补全了 %d 个错过的数据总结，window=%s", len(runs), w.name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/report"
+	"github.com/tidwall/gjson"
+)
+
+func TestReadWindows(t *testing.T) {
+	windows, err := readWindows(gjson.Parse(`{"hour": -1, "minute": 30}`))
+	if err != nil || len(windows) != 1 || windows[0].name != "" || windows[0].schedule.String() != "30 * * * *" {
+		t.Fatalf("legacy: got %+v, %v", windows, err)
+	}
+	if windows[0].checkpointFile() != checkpointFile {
+		t.Errorf("legacy checkpoint: got %s", windows[0].checkpointFile())
+	}
+	windows, err = readWindows(gjson.Parse(`{"timezone": "Asia/Shanghai", "hour": 7,
+"windows": [{"name": "hourly", "cron": "@hourly"}, {"name": "daily", "cron": "33 7 * * *"}]}`))
+	if err != nil || len(windows) != 2 || windows[1].name != "daily" ||
+		windows[1].schedule.Location().String() != "Asia/Shanghai" {
+		t.Fatalf("windows: got %+v, %v", windows, err)
+	}
+	at := time.Date(2022, 7, 1, 7, 33, 0, 0, time.Local)
+	if got := windows[1].summaryFile(at); got != "./report/daily-202207010733.json" {
+		t.Errorf("summary file: got %s", got)
+	}
+	for _, config := range []string{
+		`{"timezone": "Mars/Base"}`,
+		`{"windows": [{"name": "a/b", "cron": "@daily"}]}`,
+		`{"windows": [{"name": "a", "cron": "@daily"}, {"name": "a", "cron": "@hourly"}]}`,
+		`{"windows": [{"name": "a", "cron": "* *"}]}`,
+	} {
+		if _, err = readWindows(gjson.Parse(config)); err == nil {
+			t.Errorf("%s: want error", config)
+		}
+	}
+}
+
+func TestMissedRuns(t *testing.T) {
+	s, _ := ParseSchedule("@hourly", time.Local)
+	at := func(h, m int) time.Time {
+		return time.Date(2022, 7, 1, h, m, 0, 0, time.Local)
+	}
+	runs := missedRuns(s, at(7, 10), at(10, 0), 24)
+	if len(runs) != 3 || !runs[0].Equal(at(8, 0)) || !runs[2].Equal(at(10, 0)) {
+		t.Errorf("got %v", runs)
+	}
+	//只保留最近的 n 个
+	if runs = missedRuns(s, at(0, 0), at(10, 30), 2); len(runs) != 2 || !runs[0].Equal(at(9, 0)) {
+		t.Errorf("got %v", runs)
+	}
+	if runs = missedRuns(s, at(8, 0), at(8, 59), 24); len(runs) != 0 {
+		t.Errorf("got %v", runs)
+	}
+}
+
+func TestDB_lastSummaryEnd(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), "test.db"), DBOption{})
+	if d == nil {
+		t.Fatal("NewDB fail")
+	}
+	defer d.Close()
+	for i, window := range []string{"", "daily", "", "hourly"} {
+		s := report.Summary{Start: int64(i * 100), End: int64(i*100 + 100), Window: window}
+		s.Board.Oid = 10
+		d.InsertSummary(s, "", false)
+	}
+	d.Flush()
+	want := map[string]int64{"": 300, "daily": 200, "hourly": 400, "weekly": 0}
+	for window, end := range want {
+		if got, err := d.lastSummaryEnd(10, window); err != nil || got != end {
+			t.Errorf("%q: want %d, got %d, %v", window, end, got, err)
+		}
+	}
+	if records, err := d.Summaries(10, 0, 0, 1); err != nil || len(records) != 1 || records[0].window != "hourly" {
+		t.Errorf("Summaries: got %+v, %v", records, err)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Hami-Lemon/bobo-bot/logger"
+)
+
+//写入协程使用的 sql 语句，键为语句名称
+var writeStmts = map[string]string{
+	"comment": `insert into comment
+(oid, type_code, rpid, ctime, msg, like_time, uid, uname, location, watched, like_count, reply_count, spam)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+on conflict (oid, rpid) do update set uname       = excluded.uname,
+                                      like_count  = excluded.like_count,
+                                      reply_count = excluded.reply_count,
+                                      watched     = max(watched, excluded.watched),
+                                      -- 保留第一次运行时获取到时的标记
+                                      spam        = iif(source = 'backfill', excluded.spam, spam),
+                                      -- 补全历史评论时已经保存的评论，之后在运行时获取到，作为运行时获取的评论
+                                      like_time   = iif(source = 'backfill', excluded.like_time, like_time),
+                                      source      = 'live';`,
+	"backfill_comment": `insert into comment
+(oid, type_code, rpid, ctime, msg, like_time, uid, uname, location, like_count, reply_count, source, root)
+values (?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?, 'backfill', ?)
+on conflict (oid, rpid) do update set uname       = excluded.uname,
+                                      like_count  = excluded.like_count,
+                                      reply_count = excluded.reply_count;`,
+	"backfill_cursor": `insert or replace into backfill_cursor(oid, type_code, next, done, count, updated_at)
+values (?, ?, ?, ?, ?, ?)`,
+	"follower": `insert into follower(uid, ctime, fans)
+values (?, ?, ?)`,
+	"account_stat": `insert into account_stat(uid, ctime, metric, value)
+values (?, ?, ?, ?)`,
+	"video_stat": `insert into video_stat
+(aid, bvid, ctime, view, danmaku, reply, favorite, coin, share, like)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	"summary": `insert into summary
+(oid, board_name, uid, start_time, end_time, comment_count, people_count, peak_hot, peak_time, max_delay,
+ start_followers, end_followers, start_all_count, end_all_count, interrupted, file, data, window_name)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+}
+
+// DBOption 数据库写入的配置
+type DBOption struct {
+	batchSize     int           //每个事务最多写入的数据条数
+	flushInterval time.Duration //两次提交事务的最大间隔
+}
+
+//写入任务
+type writeTask struct {
+	stmt     string     //sql 语句名称，为空时表示只等待之前的任务写入完成
+	args     []any      //sql 语句的参数
+	enqueued time.Time  //加入队列的时间，用于统计写入延迟
+	done     chan error //不为 nil 时，写入完成后发送上次等待之后的写入错误
+}
+
+//写入延迟统计
+type writeStat struct {
+	count int           //写入的数据条数
+	total time.Duration //总延迟
+	max   time.Duration //最大延迟
+}
+
+// dbWriter 数据库写入协程，通过 channel 接收写入任务，复用预编译的语句，并在事务中批量提交
+type dbWriter struct {
+	conn   *sql.DB
+	stmts  map[string]*sql.Stmt
+	tasks  chan writeTask
+	done   chan struct{} //写入协程退出时关闭
+	opt    DBOption
+	stat   writeStat
+	err    error //上次等待写入完成之后，提交事务失败的错误
+	closed bool
+	lock   sync.RWMutex
+	logger *logger.Logger
+}
+
+func newDBWriter(conn *sql.DB, opt DBOption, l *logger.Logger) (*dbWriter, error) {
+	if opt.batchSize <= 0 {
+		opt.batchSize = 64
+	}
+	if opt.flushInterval <= 0 {
+		opt.flushInterval = 500 * time.Millisecond
+	}
+	stmts := make(map[string]*sql.Stmt, len(writeStmts))
+	for name, query := range writeStmts {
+		stmt, err := conn.Prepare(query)
+		if err != nil {
+			for _, s := range stmts {
+				_ = s.Close()
+			}
+			return nil, err
+		}
+		stmts[name] = stmt
+	}
+	w := &dbWriter{
+		conn:   conn,
+		stmts:  stmts,
+		tasks:  make(chan writeTask, opt.batchSize*4),
+		done:   make(chan struct{}),
+		opt:    opt,
+		logger: l,
+	}
+	go w.run()
+	return w, nil
+}
+
+//添加写入任务，写入协程关闭后添加的任务会被丢弃
+func (w *dbWriter) add(stmt string, args ...any) {
+	w.send(writeTask{stmt: stmt, args: args, enqueued: time.Now()})
+}
+
+func (w *dbWriter) send(task writeTask) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.closed {
+		w.logger.Warn("写入协程已关闭，丢弃数据：%s, %v", task.stmt, task.args)
+		return false
+	}
+	w.tasks <- task
+	return true
+}
+
+//等待已添加的任务全部写入，返回上次等待之后提交事务失败的错误
+func (w *dbWriter) flush() error {
+	done := make(chan error, 1)
+	if !w.send(writeTask{enqueued: time.Now(), done: done}) {
+		return errors.New("dbWriter: 写入协程已关闭")
+	}
+	return <-done
+}
+
+//关闭写入协程，并等待剩余的任务写入完成
+func (w *dbWriter) close() {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.tasks)
+	w.lock.Unlock()
+	<-w.done
+	for _, stmt := range w.stmts {
+		_ = stmt.Close()
+	}
+	w.report()
+}
+
+func (w *dbWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opt.flushInterval)
+	defer ticker.Stop()
+	//每十分钟报告一次写入延迟
+	reportTicker := time.NewTicker(10 * time.Minute)
+	defer reportTicker.Stop()
+
+	batch := make([]writeTask, 0, w.opt.batchSize)
+	for {
+		select {
+		case task, ok := <-w.tasks:
+			if !ok {
+				w.commit(batch)
+				return
+			}
+			batch = append(batch, task)
+			//需要等待写入完成的任务立即提交
+			if len(batch) >= w.opt.batchSize || task.done != nil {
+				w.commit(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.commit(batch)
+			batch = batch[:0]
+		case <-reportTicker.C:
+			w.report()
+		}
+	}
+}
+
+//在一个事务中写入一批数据，单条数据写入失败不影响其他数据，开始或提交事务失败时重试一次
+func (w *dbWriter) commit(batch []writeTask) {
+	if len(batch) == 0 {
+		return
+	}
+	count, err := w.exec(batch)
+	if err != nil {
+		w.logger.Warn("dbWriter: %v，重试", err)
+		count, err = w.exec(batch)
+	}
+	if err != nil {
+		w.logger.Error("dbWriter: %v，丢弃 %d 条数据", err, len(batch))
+		if w.err == nil {
+			w.err = err
+		}
+	}
+	for _, task := range batch {
+		if task.done != nil {
+			task.done <- w.err
+			close(task.done)
+			w.err = nil
+		}
+	}
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, task := range batch {
+		if task.stmt == "" {
+			continue
+		}
+		latency := now.Sub(task.enqueued)
+		w.stat.count++
+		w.stat.total += latency
+		if latency > w.stat.max {
+			w.stat.max = latency
+		}
+	}
+	w.logger.Debug("dbWriter: 提交事务，写入 %d 条数据", count)
+}
+
+//在事务中执行一批任务，返回写入成功的条数，只有开始或提交事务失败时返回错误
+func (w *dbWriter) exec(batch []writeTask) (int, error) {
+	writes := 0
+	for _, task := range batch {
+		if task.stmt != "" {
+			writes++
+		}
+	}
+	//只等待之前的任务时不需要开始事务
+	if writes == 0 {
+		return 0, nil
+	}
+	tx, err := w.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin, %w", err)
+	}
+	//每个语句在一个事务中只绑定一次
+	stmts := make(map[string]*sql.Stmt, len(w.stmts))
+	count := 0
+	for _, task := range batch {
+		if task.stmt == "" {
+			continue
+		}
+		stmt, ok := stmts[task.stmt]
+		if !ok {
+			stmt = tx.Stmt(w.stmts[task.stmt])
+			stmts[task.stmt] = stmt
+		}
+		if _, err = stmt.Exec(task.args...); err != nil {
+			w.logger.Error("dbWriter: exec %s, %v, args=%v", task.stmt, err, task.args)
+			continue
+		}
+		count++
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit, %w", err)
+	}
+	return count, nil
+}
+
+//报告写入延迟，并重置统计数据
+func (w *dbWriter) report() {
+	stat := w.stat
+	w.stat = writeStat{}
+	if stat.count == 0 {
+		return
+	}
+	w.logger.Info("写入 %d 条数据，平均延迟：%v，最大延迟：%v",
+		stat.count, stat.total/time.Duration(stat.count), stat.max)
+}